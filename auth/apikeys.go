@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// apiKey is an entry in the API key file, keys are stored as the hex
+// encoded SHA-256 of the key so the file does not contain secrets
+//
+//	[{"sha256": "9f86d0...", "caller": "alice", "roles": ["admin"]}]
+type apiKey struct {
+	SHA256 string   `json:"sha256"`
+	Caller string   `json:"caller"`
+	Roles  []string `json:"roles"`
+}
+
+// APIKeys authenticates callers with static API keys
+type APIKeys struct {
+	keys []apiKey
+}
+
+// LoadAPIKeys reads API keys from a JSON key file
+func LoadAPIKeys(file string) (*APIKeys, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []apiKey{}
+	if err := json.Unmarshal(d, &keys); err != nil {
+		return nil, fmt.Errorf("unable to parse key file %s: %s", file, err)
+	}
+
+	for i, k := range keys {
+		keys[i].SHA256 = strings.ToLower(k.SHA256)
+
+		if b, err := hex.DecodeString(k.SHA256); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("key %d in %s is not a hex encoded SHA-256", i, file)
+		}
+
+		if k.Caller == "" {
+			return nil, fmt.Errorf("key %d in %s has no caller", i, file)
+		}
+	}
+
+	return &APIKeys{keys}, nil
+}
+
+// HashAPIKey returns the value stored in the key file for key
+func HashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// Authenticate returns the caller which owns the key
+func (a *APIKeys) Authenticate(key string) (*Caller, error) {
+	h := []byte(HashAPIKey(key))
+
+	// compare every key in constant time so the time taken does not
+	// reveal which key matched
+	var found *apiKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(h, []byte(a.keys[i].SHA256)) == 1 {
+			found = &a.keys[i]
+		}
+	}
+
+	if found == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Caller{ID: found.Caller, Roles: found.Roles}, nil
+}
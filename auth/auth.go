@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// RoleAdmin is the role which grants access to all jobs and admin calls
+const RoleAdmin = "admin"
+
+// ErrInvalidCredentials is returned when a credential can not be verified
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Caller is the authenticated identity making a request
+type Caller struct {
+	ID    string
+	Roles []string
+}
+
+// HasRole returns true if the caller has been granted the role
+func (c *Caller) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAdmin returns true if the caller has the admin role
+func (c *Caller) IsAdmin() bool {
+	return c.HasRole(RoleAdmin)
+}
+
+// Authenticator verifies a credential and returns the identity of the caller
+type Authenticator interface {
+	Authenticate(credential string) (*Caller, error)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the caller
+func NewContext(ctx context.Context, c *Caller) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the caller stored in ctx, ok is false when the
+// request has not been authenticated
+func FromContext(ctx context.Context) (*Caller, bool) {
+	c, ok := ctx.Value(contextKey{}).(*Caller)
+	return c, ok
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTemp(t *testing.T, data string) string {
+	f, err := ioutil.TempFile("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.WriteString(data)
+	return f.Name()
+}
+
+func TestAPIKeysAuthenticatesKnownKey(t *testing.T) {
+	f := writeTemp(t, `[{"sha256": "`+strings.ToUpper(HashAPIKey("secret"))+`", "caller": "alice", "roles": ["admin"]}]`)
+	defer os.Remove(f)
+
+	k, err := LoadAPIKeys(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := k.Authenticate("secret")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", c.ID)
+	assert.True(t, c.IsAdmin())
+}
+
+func TestAPIKeysRejectsUnknownKey(t *testing.T) {
+	f := writeTemp(t, `[{"sha256": "`+HashAPIKey("secret")+`", "caller": "alice"}]`)
+	defer os.Remove(f)
+
+	k, _ := LoadAPIKeys(f)
+	_, err := k.Authenticate("guess")
+
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestLoadAPIKeysRejectsPlainKeys(t *testing.T) {
+	f := writeTemp(t, `[{"sha256": "secret", "caller": "alice"}]`)
+	defer os.Remove(f)
+
+	_, err := LoadAPIKeys(f)
+
+	assert.Error(t, err)
+}
+
+func segment(v interface{}) string {
+	d, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(d)
+}
+
+func signRS256(k *rsa.PrivateKey, kid string, c map[string]interface{}) string {
+	s := segment(map[string]string{"alg": "RS256", "kid": kid}) + "." + segment(c)
+	h := sha256.Sum256([]byte(s))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:])
+
+	return s + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(k *ecdsa.PrivateKey, kid string, c map[string]interface{}) string {
+	s := segment(map[string]string{"alg": "ES256", "kid": kid}) + "." + segment(c)
+	h := sha256.Sum256([]byte(s))
+	r, ss, _ := ecdsa.Sign(rand.Reader, k, h[:])
+
+	// r and s are padded to 32 bytes each
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), ss.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+
+	return s + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claimsFor(sub string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   sub,
+		"iss":   "emojify-test",
+		"aud":   []string{"emojify"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"user"},
+	}
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestJWTAuthenticatesTokensFromJWKS(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "r1", "n": b64(rk.N), "e": b64(big.NewInt(int64(rk.E)))},
+			{"kty": "EC", "kid": "e1", "crv": "P-256", "x": b64(ek.X), "y": b64(ek.Y)},
+		},
+	}
+	d, _ := json.Marshal(jwks)
+	f := writeTemp(t, string(d))
+	defer os.Remove(f)
+
+	keys, err := LoadJWKS(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := NewJWT(keys, "emojify-test", "emojify")
+
+	c, err := j.Authenticate(signRS256(rk, "r1", claimsFor("bob")))
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", c.ID)
+	assert.True(t, c.HasRole("user"))
+
+	c, err = j.Authenticate(signES256(ek, "e1", claimsFor("carol")))
+	assert.Nil(t, err)
+	assert.Equal(t, "carol", c.ID)
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	j := NewJWT(map[string]crypto.PublicKey{"": &rk.PublicKey}, "emojify-test", "emojify")
+
+	expired := claimsFor("bob")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	audience := claimsFor("bob")
+	audience["aud"] = "someone-else"
+
+	tt := map[string]string{
+		"malformed":      "abc.def",
+		"wrong key":      signRS256(other, "", claimsFor("bob")),
+		"unknown kid":    signRS256(rk, "nope", claimsFor("bob")),
+		"expired":        signRS256(rk, "", expired),
+		"wrong audience": signRS256(rk, "", audience),
+		"no subject":     signRS256(rk, "", claimsFor("")),
+	}
+
+	for name, token := range tt {
+		_, err := j.Authenticate(token)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestJWTRejectsAlgorithmNotMatchingKey(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	j := NewJWT(map[string]crypto.PublicKey{"": &rk.PublicKey}, "", "")
+
+	token := signRS256(rk, "", claimsFor("bob"))
+	parts := strings.Split(token, ".")
+	parts[0] = segment(map[string]string{"alg": "ES256"})
+
+	_, err := j.Authenticate(strings.Join(parts, "."))
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance allowed when checking token expiry
+const clockSkew = time.Minute
+
+// JWT authenticates callers with signed JSON Web Tokens, tokens must be
+// signed with RS256 or ES256 using one of the configured public keys
+type JWT struct {
+	keys     map[string]crypto.PublicKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	Roles     []string        `json:"roles"`
+}
+
+// NewJWT creates a JWT authenticator using keys indexed by key ID, a key
+// with an empty ID is used for tokens without a kid header. When issuer or
+// audience are set the token claims must match
+func NewJWT(keys map[string]crypto.PublicKey, issuer, audience string) *JWT {
+	return &JWT{keys: keys, issuer: issuer, audience: audience, now: time.Now}
+}
+
+// LoadPublicKey reads a PEM encoded RSA or ECDSA public key, the key is
+// returned with an empty key ID
+func LoadPublicKey(file string) (map[string]crypto.PublicKey, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := pem.Decode(d)
+	if b == nil {
+		return nil, fmt.Errorf("no PEM data in %s", file)
+	}
+
+	k, err := x509.ParsePKIXPublicKey(b.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key %s: %s", file, err)
+	}
+
+	return map[string]crypto.PublicKey{"": k}, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads the RSA and P-256 EC signing keys from a JWKS file
+func LoadJWKS(file string) (map[string]crypto.PublicKey, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := json.Unmarshal(d, &set); err != nil {
+		return nil, fmt.Errorf("unable to parse JWKS %s: %s", file, err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		pk, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q in %s: %s", k.Kid, file, err)
+		}
+
+		keys[k.Kid] = pk
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys in %s", file)
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// Authenticate verifies the token signature and claims and returns the
+// caller identified by the subject
+func (j *JWT) Authenticate(token string) (*Caller, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	h := header{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, ok := j.keys[h.Kid]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verify(h.Alg, key, digest[:], sig) {
+		return nil, ErrInvalidCredentials
+	}
+
+	c := claims{}
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := j.validate(c); err != nil {
+		return nil, err
+	}
+
+	return &Caller{ID: c.Subject, Roles: c.Roles}, nil
+}
+
+func (j *JWT) validate(c claims) error {
+	now := j.now()
+
+	if c.Subject == "" {
+		return fmt.Errorf("%s: token has no subject", ErrInvalidCredentials)
+	}
+
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return fmt.Errorf("%s: token has expired", ErrInvalidCredentials)
+	}
+
+	if c.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(c.NotBefore, 0)) {
+		return fmt.Errorf("%s: token is not yet valid", ErrInvalidCredentials)
+	}
+
+	if j.issuer != "" && c.Issuer != j.issuer {
+		return fmt.Errorf("%s: unexpected issuer", ErrInvalidCredentials)
+	}
+
+	if j.audience != "" && !hasAudience(c.Audience, j.audience) {
+		return fmt.Errorf("%s: unexpected audience", ErrInvalidCredentials)
+	}
+
+	return nil
+}
+
+// verify checks the signature using the algorithm from the token header,
+// the algorithm must match the type of the key
+func verify(alg string, key crypto.PublicKey, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+
+	return false
+}
+
+// hasAudience returns true if the aud claim, a string or array of
+// strings, contains audience
+func hasAudience(aud json.RawMessage, audience string) bool {
+	var one string
+	if json.Unmarshal(aud, &one) == nil {
+		return one == audience
+	}
+
+	many := []string{}
+	if json.Unmarshal(aud, &many) == nil {
+		for _, a := range many {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	d, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(d, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	d, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(d), nil
+}
@@ -0,0 +1,257 @@
+package emojify
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// ReasonTooManyFrames is reported when an animation has more frames than allowed
+const ReasonTooManyFrames = "TOO_MANY_FRAMES"
+
+// Animation is an animated GIF where every frame has been composited onto
+// the full canvas, so each frame is the image the viewer sees
+type Animation struct {
+	Frames []image.Image
+	// Delay for each frame in 100ths of a second
+	Delay []int
+	// LoopCount as defined by image/gif, 0 loops forever
+	LoopCount int
+}
+
+// DecodeAnimation decodes an animated GIF, nil is returned when the image
+// is not a GIF or only has a single frame. Animations with more than
+// maxFrames frames, or where the canvas width × height × frames is more
+// than maxPixels, return a PermanentError. The limits are checked before
+// any frame is decoded.
+func DecodeAnimation(r io.ReadSeeker, maxFrames, maxPixels int) (*Animation, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	defer r.Seek(0, os.SEEK_SET)
+
+	if Format(r) != "gif" {
+		return nil, nil
+	}
+
+	c, err := gif.DecodeConfig(r)
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: err}
+	}
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	n, err := countFrames(r, maxFrames)
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: err}
+	}
+
+	if n < 2 {
+		return nil, nil
+	}
+
+	if n > maxFrames {
+		return nil, &PermanentError{
+			Reason: ReasonTooManyFrames,
+			Err:    fmt.Errorf("animation has more than %d frames", maxFrames),
+		}
+	}
+
+	// every frame is composited onto a copy of the full canvas
+	if p := int64(c.Width) * int64(c.Height) * int64(n); p > int64(maxPixels) {
+		return nil, &PermanentError{
+			Reason: ReasonTooLarge,
+			Err:    fmt.Errorf("animation of %d frames at %dx%d has %d pixels, the limit is %d", n, c.Width, c.Height, p, maxPixels),
+		}
+	}
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: err}
+	}
+
+	if len(g.Image) < 2 {
+		return nil, nil
+	}
+
+	a := &Animation{Delay: g.Delay, LoopCount: g.LoopCount}
+
+	// frames only contain the area which changed, render each frame onto
+	// the canvas and apply the disposal method before the next frame
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, f := range g.Image {
+		var previous *image.RGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			previous = copyRGBA(canvas)
+		}
+
+		draw.Draw(canvas, f.Bounds(), f, f.Bounds().Min, draw.Over)
+		a.Frames = append(a.Frames, copyRGBA(canvas))
+
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, f.Bounds(), image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return a, nil
+}
+
+// countFrames returns the number of frames in a GIF by reading the block
+// structure without decoding the pixels, counting stops after limit + 1
+// frames. A GIF which ends without a trailer returns the frames found so
+// far, the decoder reports any error.
+func countFrames(r io.Reader, limit int) (int, error) {
+	br := bufio.NewReader(r)
+
+	// header and logical screen descriptor
+	h := make([]byte, 13)
+	if _, err := io.ReadFull(br, h); err != nil {
+		return 0, err
+	}
+
+	if err := skipColorTable(br, h[10]); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n <= limit {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		switch b {
+		case 0x21: // extension, the label is followed by data sub-blocks
+			if _, err := br.ReadByte(); err != nil {
+				return 0, err
+			}
+		case 0x2c: // image descriptor, then the LZW minimum code size
+			d := make([]byte, 9)
+			if _, err := io.ReadFull(br, d); err != nil {
+				return 0, err
+			}
+			if err := skipColorTable(br, d[8]); err != nil {
+				return 0, err
+			}
+			if _, err := br.ReadByte(); err != nil {
+				return 0, err
+			}
+			n++
+		case 0x3b: // trailer
+			return n, nil
+		default:
+			return 0, fmt.Errorf("gif: unknown block type 0x%02x", b)
+		}
+
+		if err := skipSubBlocks(br); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// skipColorTable skips the colour table described by the packed fields of
+// a screen or image descriptor
+func skipColorTable(br *bufio.Reader, fields byte) error {
+	if fields&0x80 == 0 {
+		return nil
+	}
+
+	_, err := br.Discard(3 * (1 << (fields&0x07 + 1)))
+	return err
+}
+
+// skipSubBlocks skips data sub-blocks up to and including the terminator
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		if size == 0 {
+			return nil
+		}
+
+		if _, err := br.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// EncodeAnimation writes the animation as a GIF, every frame covers the
+// full canvas and is reduced to a 256 colour palette
+func EncodeAnimation(w io.Writer, a *Animation) error {
+	g := &gif.GIF{
+		Delay:     a.Delay,
+		LoopCount: a.LoopCount,
+	}
+
+	// the source disposal methods only apply to partial frames, the full
+	// frames replace the canvas so are not disposed unless the next frame
+	// has transparent pixels which would show this frame through them
+	for i, f := range a.Frames {
+		g.Image = append(g.Image, toPaletted(f))
+
+		d := byte(gif.DisposalNone)
+		if i+1 < len(a.Frames) && !isOpaque(a.Frames[i+1]) {
+			d = gif.DisposalBackground
+		}
+		g.Disposal = append(g.Disposal, d)
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+// opaquePalette is used for frames without transparent pixels
+var opaquePalette = color.Palette(palette.Plan9)
+
+// transparentPalette replaces the last colour with transparent
+var transparentPalette = append(append(color.Palette{}, palette.Plan9[:255]...), color.Transparent)
+
+// opaque is implemented by the image types in the standard library
+type opaque interface {
+	Opaque() bool
+}
+
+func isOpaque(img image.Image) bool {
+	o, ok := img.(opaque)
+	return ok && o.Opaque()
+}
+
+// toPaletted reduces the image to a 256 colour palette
+func toPaletted(img image.Image) *image.Paletted {
+	p := transparentPalette
+	if isOpaque(img) {
+		p = opaquePalette
+	}
+
+	pi := image.NewPaletted(img.Bounds(), p)
+	draw.FloydSteinberg.Draw(pi, img.Bounds(), img, img.Bounds().Min)
+	return pi
+}
+
+func copyRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
@@ -0,0 +1,237 @@
+package emojify
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMaxPixels is larger than any of the test animations
+const testMaxPixels = 1 << 24
+
+func TestDecodeAnimationReturnsFramesDelaysAndLoopCount(t *testing.T) {
+	a, err := DecodeAnimation(readFixture(t, "image.gif"), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, a.Frames, 2)
+	assert.Equal(t, []int{10, 20}, a.Delay)
+	assert.Equal(t, 0, a.LoopCount)
+	assert.True(t, isRed(a.Frames[0].At(4, 12)))
+	assert.False(t, isRed(a.Frames[1].At(4, 12)))
+}
+
+func TestDecodeAnimationReturnsNilForStillImages(t *testing.T) {
+	a, err := DecodeAnimation(readFixture(t, "image.png"), 10, testMaxPixels)
+
+	assert.Nil(t, err)
+	assert.Nil(t, a)
+}
+
+func TestDecodeAnimationWithTooManyFramesReturnsPermanentError(t *testing.T) {
+	_, err := DecodeAnimation(readFixture(t, "image.gif"), 1, testMaxPixels)
+
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, ReasonTooManyFrames, FailureReason(err))
+}
+
+func TestDecodeAnimationLargerThanPixelBudgetReturnsPermanentError(t *testing.T) {
+	_, err := DecodeAnimation(readFixture(t, "image.gif"), 10, 100)
+
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, ReasonTooLarge, FailureReason(err))
+}
+
+func TestCountFramesStopsAfterLimit(t *testing.T) {
+	p := color.Palette{color.Black, color.White}
+	g := &gif.GIF{Config: image.Config{Width: 4, Height: 4}}
+	for i := 0; i < 5; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), p))
+		g.Delay = append(g.Delay, 5)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := countFrames(bytes.NewReader(buf.Bytes()), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+
+	// the data after the limit is not read
+	n, err = countFrames(bytes.NewReader(append(buf.Bytes()[:buf.Len()/2], 0xff)), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestDecodeAnimationCompositesPartialFrames(t *testing.T) {
+	p := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+
+	// red background, then a blue square which is disposed before a
+	// frame which changes nothing
+	bg := image.NewPaletted(image.Rect(0, 0, 8, 8), p)
+	for i := range bg.Pix {
+		bg.Pix[i] = 1
+	}
+
+	sq := image.NewPaletted(image.Rect(2, 2, 4, 4), p)
+	for i := range sq.Pix {
+		sq.Pix[i] = 2
+	}
+
+	empty := image.NewPaletted(image.Rect(0, 0, 1, 1), p)
+
+	g := &gif.GIF{
+		Image:    []*image.Paletted{bg, sq, empty},
+		Delay:    []int{5, 5, 5},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{Width: 8, Height: 8},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := DecodeAnimation(bytes.NewReader(buf.Bytes()), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, image.Rect(0, 0, 8, 8), a.Frames[1].Bounds())
+	assert.True(t, isRed(a.Frames[1].At(0, 0)))
+	assert.False(t, isRed(a.Frames[1].At(2, 2)))
+	assert.True(t, isRed(a.Frames[2].At(2, 2)))
+}
+
+// encodeDisposalGIF returns a red background followed by a blue square
+// which is disposed to the background before the last frame
+func encodeDisposalGIF(t *testing.T, last *image.Paletted) *bytes.Reader {
+	p := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+
+	bg := image.NewPaletted(image.Rect(0, 0, 8, 8), p)
+	for i := range bg.Pix {
+		bg.Pix[i] = 1
+	}
+
+	sq := image.NewPaletted(image.Rect(2, 2, 4, 4), p)
+	for i := range sq.Pix {
+		sq.Pix[i] = 2
+	}
+
+	last.Palette = p
+	g := &gif.GIF{
+		Image:    []*image.Paletted{bg, sq, last},
+		Delay:    []int{5, 5, 5},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 8, Height: 8},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestEncodeAnimationDoesNotDisposeFullFrames(t *testing.T) {
+	// the last frame repaints the disposed square so every frame is opaque
+	last := image.NewPaletted(image.Rect(2, 2, 4, 4), nil)
+	for i := range last.Pix {
+		last.Pix[i] = 1
+	}
+
+	a, err := DecodeAnimation(encodeDisposalGIF(t, last), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeAnimation(buf, a); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalNone}, g.Disposal)
+	for _, f := range g.Image {
+		assert.Equal(t, image.Rect(0, 0, 8, 8), f.Bounds())
+	}
+}
+
+func TestEncodeAnimationRendersLikeTheSource(t *testing.T) {
+	// the last frame changes nothing so the disposed square is transparent
+	last := image.NewPaletted(image.Rect(0, 0, 1, 1), nil)
+	last.Pix[0] = 1
+
+	a, err := DecodeAnimation(encodeDisposalGIF(t, last), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeAnimation(buf, a); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := DecodeAnimation(bytes.NewReader(buf.Bytes()), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range a.Frames {
+		for _, pt := range []image.Point{{0, 0}, {2, 2}, {3, 3}, {7, 7}} {
+			_, _, _, want := a.Frames[i].At(pt.X, pt.Y).RGBA()
+			_, _, _, got := b.Frames[i].At(pt.X, pt.Y).RGBA()
+			assert.Equal(t, want, got, "frame %d at %s", i, pt)
+		}
+	}
+	assert.True(t, isRed(b.Frames[2].At(0, 0)))
+	_, _, _, alpha := b.Frames[2].At(2, 2).RGBA()
+	assert.Zero(t, alpha)
+}
+
+func TestEncodeAnimationPreservesTiming(t *testing.T) {
+	a, err := DecodeAnimation(readFixture(t, "image.gif"), 10, testMaxPixels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.LoopCount = 3
+
+	buf := new(bytes.Buffer)
+	err = EncodeAnimation(buf, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, g.Image, 2)
+	assert.Equal(t, []int{10, 20}, g.Delay)
+	assert.Equal(t, 3, g.LoopCount)
+	assert.True(t, isRed(g.Image[0].At(4, 12)))
+}
+
+func TestTrackFacesKeepsIDsForMovingFaces(t *testing.T) {
+	ids := TrackFaces([][]Face{
+		Rects(image.Rect(0, 0, 10, 10), image.Rect(50, 50, 60, 60)),
+		Rects(image.Rect(52, 51, 62, 61), image.Rect(1, 1, 11, 11)),
+		Rects(),
+		Rects(image.Rect(3, 2, 13, 12), image.Rect(100, 100, 110, 110)),
+	})
+
+	assert.Equal(t, [][]int{{0, 1}, {1, 0}, {}, {0, 2}}, ids)
+}
@@ -0,0 +1,157 @@
+package emojify
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/nfnt/resize"
+)
+
+// Processing options which control how emoji are blended with the image
+const (
+	// OptionFeather softens the edges of the emoji, the width of the soft
+	// edge is a fraction of the emoji size, e.g. 0.05
+	OptionFeather = "feather"
+	// OptionShadow draws a drop shadow below and to the right of the
+	// emoji, the offset is a fraction of the emoji size, e.g. 0.05
+	OptionShadow = "shadow"
+	// OptionOpacity of the emoji from 0 to 1, e.g. 0.8
+	OptionOpacity = "opacity"
+)
+
+// shadowOpacity is the opacity of the drop shadow of an opaque emoji
+const shadowOpacity = 0.5
+
+// maxBlend is the largest feather and shadow relative to the emoji size
+const maxBlend = 0.2
+
+// maxMaskSigma is the largest blur applied to a mask at full size, masks
+// which need more blur are blurred at a smaller size
+const maxMaskSigma = 4.0
+
+// composite draws the emoji m to the area r of dst with the feathering,
+// drop shadow and opacity set in the options
+func composite(dst draw.Image, r image.Rectangle, m image.Image, o Options) {
+	size := r.Dx()
+	if r.Dy() > size {
+		size = r.Dy()
+	}
+
+	opacity := o.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+
+	// masks are in the coordinates of m, offset moves them to dst
+	offset := r.Min.Sub(m.Bounds().Min)
+
+	if d := int(math.Round(o.Shadow * float64(size))); d > 0 {
+		mask := alphaMask(m, float64(d)/2)
+		scaleAlpha(mask, shadowOpacity*opacity)
+
+		draw.DrawMask(
+			dst,
+			mask.Bounds().Add(offset).Add(image.Pt(d, d)),
+			image.NewUniform(color.Black),
+			image.ZP,
+			mask,
+			mask.Bounds().Min,
+			draw.Over)
+	}
+
+	var mask image.Image
+	if o.Feather > 0 {
+		// the blurred alpha is multiplied by the alpha of the emoji so the
+		// edge fades inwards without a halo
+		a := alphaMask(m, o.Feather*float64(size))
+		scaleAlpha(a, opacity)
+		mask = a
+	} else if opacity < 1 {
+		mask = image.NewUniform(color.Alpha{uint8(math.Round(255 * opacity))})
+	}
+
+	// draw clips r to the image and moves the source point to match
+	// so emoji on the edge of the image are cropped not shifted
+	if mask == nil {
+		draw.Draw(dst, r, m, m.Bounds().Min, draw.Over)
+		return
+	}
+
+	draw.DrawMask(dst, r, m, m.Bounds().Min, mask, m.Bounds().Min, draw.Over)
+}
+
+// alphaMask returns a mask from the alpha of m blurred with the standard
+// deviation sigma, the mask is larger than m so the blur can spread
+func alphaMask(m image.Image, sigma float64) *image.Alpha {
+	n := int(math.Ceil(3 * sigma))
+	bounds := m.Bounds().Inset(-n)
+
+	if sigma <= maxMaskSigma {
+		a := image.NewRGBA(bounds)
+		draw.Draw(a, m.Bounds(), m, m.Bounds().Min, draw.Src)
+		if sigma > 0 {
+			blur(a, bounds, sigma)
+		}
+
+		return toAlpha(a, bounds)
+	}
+
+	// a blurred mask has no fine detail so it is blurred at a size where
+	// the blur is small and scaled up without a visible difference, the
+	// blur hides any aliasing from sampling the nearest pixels
+	f := maxMaskSigma / sigma
+	sm := resize.Resize(
+		uint(math.Max(1, math.Round(float64(m.Bounds().Dx())*f))),
+		uint(math.Max(1, math.Round(float64(m.Bounds().Dy())*f))),
+		m, resize.NearestNeighbor)
+
+	sn := int(math.Ceil(3 * maxMaskSigma))
+	s := image.NewRGBA(sm.Bounds().Inset(-sn))
+	draw.Draw(s, sm.Bounds(), sm, sm.Bounds().Min, draw.Src)
+	blur(s, s.Bounds(), maxMaskSigma)
+
+	return toAlpha(resize.Resize(uint(bounds.Dx()), uint(bounds.Dy()), s, resize.Bilinear), bounds)
+}
+
+// toAlpha returns the alpha of img moved to the given bounds, draw has
+// fast paths for alpha masks
+func toAlpha(img image.Image, bounds image.Rectangle) *image.Alpha {
+	a := image.NewAlpha(bounds)
+	draw.Draw(a, bounds, img, img.Bounds().Min, draw.Src)
+
+	return a
+}
+
+// scaleAlpha multiplies the alpha of img by f
+func scaleAlpha(img *image.Alpha, f float64) {
+	if f >= 1 {
+		return
+	}
+
+	for i, v := range img.Pix {
+		img.Pix[i] = uint8(math.Round(float64(v) * f))
+	}
+}
+
+// validateBlend checks the blend options
+func validateBlend(options map[string]string) error {
+	for _, name := range []string{OptionFeather, OptionShadow} {
+		if v, ok := options[name]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err != nil || f < 0 || f > maxBlend {
+				return fmt.Errorf("invalid %s %q, must be between 0 and %v", name, v, maxBlend)
+			}
+		}
+	}
+
+	if v, ok := options[OptionOpacity]; ok {
+		if f, err := strconv.ParseFloat(v, 64); err != nil || f <= 0 || f > 1 {
+			return fmt.Errorf("invalid opacity %q, must be greater than 0 and at most 1", v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package emojify
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blendEmoji composites a white 40x40 emoji onto the middle of a grey
+// 100x100 image
+func blendEmoji(o Options) *image.RGBA {
+	dst := solid(image.Rect(0, 0, 100, 100), color.Gray{128})
+	composite(dst, image.Rect(30, 30, 70, 70), solid(image.Rect(0, 0, 40, 40), color.White), o)
+
+	return dst
+}
+
+func TestCompositeWithoutOptionsPastesEmoji(t *testing.T) {
+	dst := blendEmoji(Options{})
+
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(30, 30))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(69, 69))
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, dst.At(29, 29))
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, dst.At(71, 71))
+}
+
+func TestCompositeAppliesOpacity(t *testing.T) {
+	dst := blendEmoji(Options{Opacity: 0.5})
+
+	assert.InDelta(t, 192, dst.RGBAAt(50, 50).R, 1)
+	assert.Equal(t, uint8(255), dst.RGBAAt(50, 50).A)
+}
+
+func TestCompositeFeathersEdges(t *testing.T) {
+	dst := blendEmoji(Options{Feather: 0.05})
+
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(50, 50), "the middle should be opaque")
+
+	edge := dst.RGBAAt(30, 50).R
+	inside := dst.RGBAAt(33, 50).R
+	assert.True(t, edge > 128 && edge < inside && inside < 255, "the edge should fade in: %d %d", edge, inside)
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, dst.At(29, 50), "there should be no halo")
+}
+
+func TestCompositeFeathersLargeEmojiUsingSmallerMask(t *testing.T) {
+	dst := solid(image.Rect(0, 0, 600, 600), color.Black)
+	composite(dst, image.Rect(100, 100, 500, 500), solid(image.Rect(0, 0, 400, 400), color.White), Options{Feather: 0.1})
+
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(300, 300), "the middle should be opaque")
+
+	edge := dst.RGBAAt(100, 300).R
+	inside := dst.RGBAAt(140, 300).R
+	assert.True(t, edge > 0 && edge < inside && inside < 255, "the edge should fade in: %d %d", edge, inside)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(99, 300), "there should be no halo")
+}
+
+func BenchmarkCompositeFeatherAndShadow(b *testing.B) {
+	for _, size := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			dst := solid(image.Rect(0, 0, size*2, size*2), color.Black)
+			m := solid(image.Rect(0, 0, size, size), color.White)
+			r := image.Rect(size/2, size/2, size/2+size, size/2+size)
+
+			for i := 0; i < b.N; i++ {
+				composite(dst, r, m, Options{Feather: maxBlend, Shadow: maxBlend})
+			}
+		})
+	}
+}
+
+func TestCompositeDrawsDropShadow(t *testing.T) {
+	dst := blendEmoji(Options{Shadow: 0.1})
+
+	assert.True(t, dst.RGBAAt(72, 72).R < 128, "the shadow should darken below and right of the emoji")
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, dst.At(27, 27), "there should be no shadow above and left of the emoji")
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(69, 69), "the emoji should be drawn over the shadow")
+}
+
+func TestCompositeClipsAtImageEdges(t *testing.T) {
+	dst := solid(image.Rect(0, 0, 20, 20), color.Black)
+	composite(dst, image.Rect(-10, -10, 10, 10), solid(image.Rect(0, 0, 20, 20), color.White), Options{Feather: 0.1, Shadow: 0.1})
+
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, dst.At(0, 0))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(19, 0))
+}
+
+func TestValidateOptionsChecksBlendOptions(t *testing.T) {
+	assert.Nil(t, ValidateOptions(map[string]string{"feather": "0.1", "shadow": "0", "opacity": "1"}))
+
+	assert.Error(t, ValidateOptions(map[string]string{"feather": "0.3"}))
+	assert.Error(t, ValidateOptions(map[string]string{"shadow": "-0.1"}))
+	assert.Error(t, ValidateOptions(map[string]string{"opacity": "0"}))
+	assert.Error(t, ValidateOptions(map[string]string{"opacity": "half"}))
+}
+
+func TestNewOptionsParsesBlendOptions(t *testing.T) {
+	o := NewOptions(map[string]string{"feather": "0.05", "shadow": "0.1", "opacity": "0.8"}, "", "")
+
+	assert.Equal(t, 0.05, o.Feather)
+	assert.Equal(t, 0.1, o.Shadow)
+	assert.Equal(t, 0.8, o.Opacity)
+}
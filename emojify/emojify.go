@@ -1,18 +1,18 @@
 package emojify
 
 import (
+	"fmt"
 	"image"
 	"image/draw"
 	_ "image/png"
 	"io"
+	"math"
 	"math/rand"
 	"net/http"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/emojify-app/face-detection/client"
-	"github.com/nfnt/resize"
 )
 
 func init() {
@@ -21,91 +21,456 @@ func init() {
 
 // Emojify defines an interface for emojify operations
 type Emojify interface {
-	GetFaces(f io.ReadSeeker) ([]image.Rectangle, error)
-	Emojimise(image.Image, []image.Rectangle) (image.Image, error)
+	GetFaces(f io.ReadSeeker) ([]Face, error)
+	Emojimise(image.Image, []Face, Options) (image.Image, error)
+	EmojimiseFrames([]image.Image, [][]Face, Options) ([]image.Image, error)
+	Seeded(Options) bool
 	Health() (int, error)
 }
 
 // Impl implements the Emojify interface
 type Impl struct {
-	emojis []image.Image
-	fd     client.Client
+	fd client.Client
+	// packs of emoji, requests use the pack for their tenant or the
+	// default pack when they do not choose one
+	packs       *Packs
+	defaultPack string
+	tenantPacks map[string]string
+	// padding scales the area covered by an emoji relative to the face
+	padding float64
+	// selection is the strategy used when a request does not set one,
+	// selectors holds strategies registered in addition to the built in
+	selection string
+	selectors map[string]Selector
+	// mode is used when a request does not set one
+	mode string
+	// faceFilter removes false positives and duplicates from the faces
+	// found by face detection
+	faceFilter FaceFilter
 }
 
 // NewEmojify creates a new Emojify instance
-func NewEmojify(imagePath string, client client.Client) (Emojify, error) {
-	emojis, err := loadEmojis(imagePath)
+func NewEmojify(imagePath string, client client.Client) (*Impl, error) {
+	pack, err := LoadPack(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load emoji from %s: %s", imagePath, err)
+	}
 
 	return &Impl{
-		emojis: emojis,
-		fd:     client,
-	}, err
+		fd:          client,
+		packs:       StaticPacks(map[string]*Pack{DefaultPack: pack}),
+		defaultPack: DefaultPack,
+		padding:     1,
+		selection:   SelectRandom,
+		selectors:   map[string]Selector{},
+		mode:        ModeEmoji,
+	}, nil
+}
+
+// SetPacks replaces the emoji loaded from the image path with a set of
+// named packs, defaultPack is used when a request does not choose a pack
+func (e *Impl) SetPacks(p *Packs, defaultPack string) {
+	e.packs = p
+	e.defaultPack = defaultPack
+}
+
+// SetTenantPacks sets the pack used for each tenant when a request does
+// not choose one, tenants are the owners of the jobs
+func (e *Impl) SetTenantPacks(packs map[string]string) {
+	e.tenantPacks = packs
+}
+
+// ListPacks returns the details of the loaded packs
+func (e *Impl) ListPacks() []PackInfo {
+	return e.packs.List()
+}
+
+// DefaultPack returns the name of the pack used when a request or tenant
+// does not choose one
+func (e *Impl) DefaultPack() string {
+	if e.defaultPack == "" {
+		return DefaultPack
+	}
+
+	return e.defaultPack
+}
+
+// SetPadding sets the size of the emoji relative to the face, faces found
+// by face detection often exclude the hair and chin, a padding of 1.2
+// draws an emoji 20% larger than the face
+func (e *Impl) SetPadding(p float64) {
+	e.padding = p
+}
+
+// SetSelection sets the selection strategy used when a request does not
+// choose one
+func (e *Impl) SetSelection(name string) {
+	e.selection = name
+}
+
+// SetMode sets the mode used to replace faces when a request does not
+// choose one, e.g. blur to redact faces by default
+func (e *Impl) SetMode(mode string) {
+	e.mode = mode
+}
+
+// SetFaceFilter sets the filter applied to faces before they are replaced,
+// redaction modes merge overlapping faces and keep every face so no face
+// is left unredacted
+func (e *Impl) SetFaceFilter(f FaceFilter) {
+	e.faceFilter = f
 }
 
-// GetFaces finds the faces in an image
-func (e *Impl) GetFaces(r io.ReadSeeker) ([]image.Rectangle, error) {
+// RegisterSelector adds a selection strategy which requests can choose by
+// name, built in strategies can be replaced
+func (e *Impl) RegisterSelector(name string, s Selector) {
+	e.selectors[name] = s
+}
+
+// Seeded returns true when the emoji chosen for the options depend on the
+// seed, the output for these options is different for every job
+func (e *Impl) Seeded(o Options) bool {
+	if e.modeFor(o) != ModeEmoji {
+		return false
+	}
+
+	name := o.Selection
+	if name == "" {
+		name = e.selection
+	}
+
+	return name == SelectSeeded || name == SelectSame
+}
+
+// HasSelector returns true when the selection strategy is built in or has
+// been registered
+func (e *Impl) HasSelector(name string) bool {
+	_, ok := e.selector(name)
+	return ok
+}
+
+// ValidateSelection checks the selection strategy, codepoint and tag in the
+// options exist in the pack used for the tenant's request, the options
+// must have been checked with ValidateOptions. Packs can be reloaded
+// before the request is processed so selection can still fail.
+func (e *Impl) ValidateSelection(options map[string]string, tenant string) error {
+	o := NewOptions(options, "", tenant)
+
+	if o.Selection != "" && !e.HasSelector(o.Selection) {
+		return fmt.Errorf("selection strategy %q does not exist", o.Selection)
+	}
+
+	if o.Codepoint == "" && o.Tag == "" {
+		return nil
+	}
+
+	emojis, err := e.candidates(o)
+	if err != nil {
+		return err
+	}
+
+	if o.Codepoint != "" && findEmoji(emojis, o.Codepoint) < 0 {
+		return fmt.Errorf("emoji %q does not exist", o.Codepoint)
+	}
+
+	return nil
+}
+
+// GetFaces finds the faces in an image, the roll angle of the faces is set
+// when the face detection client returns landmarks or the angle
+func (e *Impl) GetFaces(r io.ReadSeeker) ([]Face, error) {
 	_, err := r.Seek(0, os.SEEK_SET)
 	if err != nil {
 		return nil, err
 	}
 
+	if ld, ok := e.fd.(LandmarkDetector); ok {
+		resp, err := ld.DetectLandmarks(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return resp.faces(), nil
+	}
+
 	resp, err := e.fd.DetectFaces(r)
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.Faces, nil
+	return Rects(resp.Faces...), nil
 }
 
-// Emojimise detects faces in an image and replaces them with emoji
-func (e *Impl) Emojimise(src image.Image, faces []image.Rectangle) (image.Image, error) {
-	dstImage := image.NewRGBA(src.Bounds())
-	draw.Draw(dstImage, src.Bounds(), src, image.ZP, draw.Src)
+// Emojimise detects faces in an image and replaces them with emoji chosen
+// by the selection strategy in the options, or redacts them when the
+// options choose a redaction mode
+func (e *Impl) Emojimise(src image.Image, faces []Face, o Options) (image.Image, error) {
+	m := e.modeFor(o)
+	faces = e.filterFaces(faces, src.Bounds(), m)
 
-	for _, face := range faces {
-		m := resize.Resize(uint(face.Size().Y), uint(face.Size().X), e.randomEmoji(), resize.Lanczos3)
-		sp2 := image.Point{face.Min.X, face.Min.Y}
-		r2 := image.Rectangle{sp2, sp2.Add(m.Bounds().Size())}
+	if m != ModeEmoji {
+		return redact(src, faces, m, o)
+	}
 
-		draw.Draw(
-			dstImage,
-			r2,
-			m,
-			image.ZP,
-			draw.Over)
+	emojis, err := e.selectEmoji(cropFaces(src, faces), o)
+	if err != nil {
+		return nil, err
 	}
-	return dstImage, nil
-}
 
-// Health returns health info about facebox
-func (e *Impl) Health() (int, error) {
-	return http.StatusOK, nil
+	return e.emojimise(src, faces, emojis, o), nil
 }
 
-func (e *Impl) randomEmoji() image.Image {
-	return e.emojis[rand.Intn(len(e.emojis))]
-}
+// EmojimiseFrames replaces the faces in each frame of an animation with
+// emoji, when tracking is enabled faces which move between frames keep
+// the same emoji, otherwise emoji are selected for every frame
+func (e *Impl) EmojimiseFrames(frames []image.Image, faces [][]Face, o Options) ([]image.Image, error) {
+	out := make([]image.Image, len(frames))
+
+	m := e.modeFor(o)
 
-func loadEmojis(path string) ([]image.Image, error) {
-	images := make([]image.Image, 0)
-	root := path
+	filtered := make([][]Face, len(faces))
+	for i, f := range faces {
+		filtered[i] = e.filterFaces(f, frames[i].Bounds(), m)
+	}
+	faces = filtered
 
-	err := filepath.Walk(path, func(path string, f os.FileInfo, err error) error {
-		if !f.IsDir() {
-			reader, err := os.Open(root + f.Name())
+	if m != ModeEmoji {
+		for i, f := range frames {
+			r, err := redact(f, faces[i], m, o)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			defer reader.Close()
 
-			i, _, err := image.Decode(reader)
-			if err == nil {
-				images = append(images, i)
+			out[i] = r
+		}
+
+		return out, nil
+	}
+
+	if !o.Track {
+		for i, f := range frames {
+			emojis, err := e.selectEmoji(cropFaces(f, faces[i]), o)
+			if err != nil {
+				return nil, err
 			}
+
+			out[i] = e.emojimise(f, faces[i], emojis, o)
 		}
 
-		return nil
-	})
+		return out, nil
+	}
+
+	// select an emoji for each track using the face from the frame where
+	// the track starts, track ids are allocated in order
+	tracks := TrackFaces(faces)
+	crops := []image.Image{}
+	for i, t := range tracks {
+		for j, id := range t {
+			if id == len(crops) {
+				crops = append(crops, cropFaces(frames[i], faces[i][j:j+1])[0])
+			}
+		}
+	}
 
-	return images, err
+	chosen, err := e.selectEmoji(crops, o)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range frames {
+		emojis := make([]*Emoji, len(faces[i]))
+		for j, id := range tracks[i] {
+			emojis[j] = chosen[id]
+		}
+
+		out[i] = e.emojimise(f, faces[i], emojis, o)
+	}
+
+	return out, nil
+}
+
+// filterFaces applies the face filter for the mode
+func (e *Impl) filterFaces(faces []Face, bounds image.Rectangle, mode string) []Face {
+	if mode == ModeEmoji {
+		return e.faceFilter.Apply(faces, bounds)
+	}
+
+	return e.faceFilter.Merge(faces, bounds)
+}
+
+// modeFor returns the mode set in the options or the default mode
+func (e *Impl) modeFor(o Options) string {
+	if o.Mode != "" {
+		return o.Mode
+	}
+
+	if e.mode != "" {
+		return e.mode
+	}
+
+	return ModeEmoji
+}
+
+// selectEmoji returns an emoji for each face chosen by the strategy in the
+// options
+func (e *Impl) selectEmoji(faces []image.Image, o Options) ([]*Emoji, error) {
+	if len(faces) == 0 {
+		return nil, nil
+	}
+
+	emojis, err := e.candidates(o)
+	if err != nil {
+		return nil, err
+	}
+
+	name := o.Selection
+	if name == "" {
+		name = e.selection
+	}
+
+	if name == "" {
+		name = SelectRandom
+	}
+
+	s, ok := e.selector(name)
+	if !ok {
+		return nil, &PermanentError{Reason: ReasonUnknownEmoji, Err: fmt.Errorf("selection strategy %q does not exist", name)}
+	}
+
+	return s.Select(faces, emojis, o)
+}
+
+// candidates returns the emoji which can be chosen for the request, the
+// emoji in the pack limited to the tag in the options
+func (e *Impl) candidates(o Options) ([]*Emoji, error) {
+	pack, err := e.pack(o)
+	if err != nil {
+		return nil, err
+	}
+
+	emojis := pack.Emojis()
+	if o.Tag != "" {
+		emojis = pack.Tagged(o.Tag)
+	}
+
+	if len(emojis) == 0 && o.Tag != "" {
+		return nil, &PermanentError{Reason: ReasonUnknownEmoji, Err: fmt.Errorf("no emoji are tagged %q", o.Tag)}
+	}
+
+	if len(emojis) == 0 {
+		return nil, ErrNoEmoji
+	}
+
+	return emojis, nil
+}
+
+// selector returns the registered or built in strategy with the name
+func (e *Impl) selector(name string) (Selector, bool) {
+	s, ok := e.selectors[name]
+	if !ok {
+		s, ok = selectors[name]
+	}
+
+	return s, ok
+}
+
+// pack returns the pack chosen by the request, the tenant's pack or the
+// default pack. Tenants use the default pack if their pack is removed.
+func (e *Impl) pack(o Options) (*Pack, error) {
+	if o.Pack != "" {
+		p, ok := e.packs.Get(o.Pack)
+		if !ok {
+			return nil, &PermanentError{Reason: ReasonUnknownPack, Err: fmt.Errorf("pack %q does not exist", o.Pack)}
+		}
+
+		return p, nil
+	}
+
+	if p, ok := e.packs.Get(e.tenantPacks[o.Tenant]); ok {
+		return p, nil
+	}
+
+	name := e.DefaultPack()
+	p, ok := e.packs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("default pack %q is not loaded", name)
+	}
+
+	return p, nil
+}
+
+// cropFaces returns the area of the image covered by each face
+func cropFaces(src image.Image, faces []Face) []image.Image {
+	crops := make([]image.Image, len(faces))
+	for i, f := range faces {
+		r := f.Rectangle.Intersect(src.Bounds())
+
+		if s, ok := src.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			crops[i] = s.SubImage(r)
+			continue
+		}
+
+		c := image.NewRGBA(r)
+		draw.Draw(c, r, src, r.Min, draw.Src)
+		crops[i] = c
+	}
+
+	return crops
+}
+
+// emojimise draws emojis[i] over faces[i], emoji are rotated to match the
+// tilt of the head and blended using the options
+func (e *Impl) emojimise(src image.Image, faces []Face, emojis []*Emoji, o Options) image.Image {
+	dstImage := image.NewRGBA(src.Bounds())
+	draw.Draw(dstImage, src.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	for i, face := range faces {
+		r := placeEmoji(face.Rectangle, emojis[i].Image.Bounds().Size(), e.padding)
+		if r.Empty() {
+			continue
+		}
+
+		m := emojis[i].scaled(r.Dx(), r.Dy())
+
+		// the rotated emoji is larger than r, with the same centre
+		if face.Roll != 0 {
+			m = rotate(m, face.Roll)
+			r = m.Bounds().Add(r.Min)
+		}
+
+		composite(dstImage, r, m, o)
+	}
+	return dstImage
+}
+
+// placeEmoji returns the area an emoji of the given size is drawn to, the
+// emoji is scaled to fit the padded face preserving its aspect ratio and
+// is centred on the face
+func placeEmoji(face image.Rectangle, size image.Point, padding float64) image.Rectangle {
+	if face.Empty() || size.X == 0 || size.Y == 0 {
+		return image.Rectangle{}
+	}
+
+	w := float64(face.Dx()) * padding
+	h := float64(face.Dy()) * padding
+
+	scale := math.Min(w/float64(size.X), h/float64(size.Y))
+	dx := int(math.Round(float64(size.X) * scale))
+	dy := int(math.Round(float64(size.Y) * scale))
+
+	// padding around faces at the edge of the image can move the emoji
+	// outside of the image, floor so negative coordinates round down
+	min := image.Pt(
+		int(math.Floor(float64(face.Min.X+face.Max.X-dx)/2)),
+		int(math.Floor(float64(face.Min.Y+face.Max.Y-dy)/2)),
+	)
+
+	return image.Rectangle{min, min.Add(image.Pt(dx, dy))}
+}
+
+// Health returns health info about facebox
+func (e *Impl) Health() (int, error) {
+	return http.StatusOK, nil
 }
@@ -0,0 +1,149 @@
+package emojify
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emojify-app/face-detection/client"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func solid(r image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, image.NewUniform(c), image.ZP, draw.Src)
+	return img
+}
+
+// emojiPacks returns a default pack containing the image
+func emojiPacks(img image.Image) *Packs {
+	return StaticPacks(map[string]*Pack{DefaultPack: NewPack([]*Emoji{{Codepoint: "1f600", Image: img}})})
+}
+
+func TestPlaceEmojiPreservesAspectRatioAndCentres(t *testing.T) {
+	// a square emoji on a tall face is limited by the width
+	r := placeEmoji(image.Rect(10, 10, 30, 50), image.Pt(72, 72), 1)
+
+	assert.Equal(t, image.Rect(10, 20, 30, 40), r)
+}
+
+func TestPlaceEmojiAppliesPadding(t *testing.T) {
+	r := placeEmoji(image.Rect(10, 10, 30, 30), image.Pt(72, 72), 1.5)
+
+	assert.Equal(t, image.Rect(5, 5, 35, 35), r)
+}
+
+func TestPlaceEmojiExtendsPastImageEdges(t *testing.T) {
+	r := placeEmoji(image.Rect(0, 0, 21, 21), image.Pt(10, 10), 2)
+
+	assert.Equal(t, image.Rect(-11, -11, 31, 31), r)
+}
+
+func TestEmojimiseClipsEmojiAtImageEdges(t *testing.T) {
+	e := &Impl{packs: emojiPacks(solid(image.Rect(0, 0, 10, 10), color.White)), padding: 1}
+	src := solid(image.Rect(0, 0, 20, 20), color.Black)
+
+	// the face is partly outside of the image
+	out, err := e.Emojimise(src, Rects(image.Rect(-5, -5, 5, 5)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, src.Bounds(), out.Bounds())
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(0, 0))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(4, 4))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(5, 5))
+}
+
+func TestEmojimiseDoesNotStretchEmoji(t *testing.T) {
+	// a wide emoji on a square face leaves the top and bottom uncovered
+	e := &Impl{packs: emojiPacks(solid(image.Rect(0, 0, 20, 10), color.White)), padding: 1}
+	src := solid(image.Rect(0, 0, 20, 20), color.Black)
+
+	out, _ := e.Emojimise(src, Rects(image.Rect(0, 0, 20, 20)), Options{})
+
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(10, 2))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(10, 10))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(10, 17))
+}
+
+func TestGetFacesWithoutLandmarksReturnsUprightFaces(t *testing.T) {
+	fd := &client.MockClient{}
+	fd.On("DetectFaces", mock.Anything).Return(&client.Response{Faces: []image.Rectangle{image.Rect(0, 0, 10, 10)}}, nil)
+	e := &Impl{fd: fd}
+
+	faces, err := e.GetFaces(bytes.NewReader(nil))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Rects(image.Rect(0, 0, 10, 10)), faces)
+}
+
+func TestGetFacesReadsRollFromLandmarks(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(rw, `{
+			"Faces": [{"Min": {"X": 0, "Y": 0}, "Max": {"X": 10, "Y": 10}}, {"Min": {"X": 20, "Y": 0}, "Max": {"X": 30, "Y": 10}}, {"Min": {"X": 40, "Y": 0}, "Max": {"X": 50, "Y": 10}}],
+			"Landmarks": [{"LeftEye": {"X": 2, "Y": 2}, "RightEye": {"X": 8, "Y": 8}}, null],
+			"Roll": []
+		}`)
+	}))
+	defer s.Close()
+
+	e := &Impl{fd: NewFaceDetect(s.URL)}
+
+	faces, err := e.GetFaces(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.InDelta(t, 45, faces[0].Roll, 0.001)
+	assert.Equal(t, 0.0, faces[1].Roll)
+	assert.Equal(t, 0.0, faces[2].Roll)
+}
+
+func TestDetectionResponsePrefersRollAngle(t *testing.T) {
+	d := &DetectionResponse{
+		Faces:     []image.Rectangle{image.Rect(0, 0, 10, 10)},
+		Landmarks: []*Landmarks{{LeftEye: image.Pt(2, 2), RightEye: image.Pt(8, 2)}},
+		Roll:      []float64{-20},
+	}
+
+	assert.Equal(t, -20.0, d.faces()[0].Roll)
+}
+
+func TestRotateTurnsImageClockwise(t *testing.T) {
+	// white on the left, black on the right
+	img := solid(image.Rect(0, 0, 10, 10), color.Black)
+	draw.Draw(img, image.Rect(0, 0, 5, 10), image.White, image.ZP, draw.Src)
+
+	r := rotate(img, 90)
+
+	assert.Equal(t, image.Rect(0, 0, 10, 10), r.Bounds())
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, r.At(5, 1))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, r.At(5, 8))
+}
+
+func TestRotateGrowsToFitAndKeepsCentre(t *testing.T) {
+	r := rotate(solid(image.Rect(0, 0, 10, 10), color.White), 45)
+
+	assert.Equal(t, image.Rect(-3, -3, 13, 13), r.Bounds())
+	assert.Equal(t, uint8(0), r.RGBAAt(-3, -3).A)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, r.RGBAAt(5, -1))
+}
+
+func TestEmojimiseRotatesEmojiForTiltedFaces(t *testing.T) {
+	// a wide emoji rotated by 90 degrees covers a tall area
+	e := &Impl{packs: emojiPacks(solid(image.Rect(0, 0, 20, 10), color.White)), padding: 1}
+	src := solid(image.Rect(0, 0, 40, 40), color.Black)
+
+	out, _ := e.Emojimise(src, []Face{{Rectangle: image.Rect(10, 10, 30, 30), Roll: 90}}, Options{})
+
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(20, 12))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(12, 20))
+}
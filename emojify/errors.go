@@ -0,0 +1,116 @@
+package emojify
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Reasons reported for failed requests
+const (
+	ReasonURLBlocked             = "URL_BLOCKED"
+	ReasonTooManyRedirects       = "TOO_MANY_REDIRECTS"
+	ReasonAddressNotAllowed      = "ADDRESS_NOT_ALLOWED"
+	ReasonTooLarge               = "TOO_LARGE"
+	ReasonHTTPStatus             = "HTTP_STATUS"
+	ReasonUnsupportedContentType = "UNSUPPORTED_CONTENT_TYPE"
+	ReasonInvalidImage           = "INVALID_IMAGE"
+	ReasonNotFound               = "NOT_FOUND"
+)
+
+// PermanentError is returned when an image can never be fetched or
+// processed, retrying the request will fail in the same way
+type PermanentError struct {
+	Reason string
+	Err    error
+}
+
+func (p *PermanentError) Error() string {
+	return p.Err.Error()
+}
+
+// SizeError is returned when an image is larger than the download limit
+type SizeError struct {
+	Limit int64
+	// Size is the Content-Length of the response, or -1 when the limit
+	// was exceeded while reading a response of unknown length
+	Size int64
+}
+
+func (s *SizeError) Error() string {
+	if s.Size < 0 {
+		return fmt.Sprintf("image is larger than the limit of %d bytes", s.Limit)
+	}
+
+	return fmt.Sprintf("image size %d bytes is larger than the limit of %d bytes", s.Size, s.Limit)
+}
+
+// HTTPStatusError is returned when the server responds with a status
+// other than 2xx
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (h *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d %s", h.StatusCode, http.StatusText(h.StatusCode))
+}
+
+// Permanent returns true for client errors which will not succeed when
+// retried, server errors, timeouts and rate limiting may be transient
+func (h *HTTPStatusError) Permanent() bool {
+	return h.StatusCode < 500 && h.StatusCode != http.StatusRequestTimeout && h.StatusCode != http.StatusTooManyRequests
+}
+
+// ContentTypeError is returned when the response is not an image
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (c *ContentTypeError) Error() string {
+	return fmt.Sprintf("content type %q is not an image", c.ContentType)
+}
+
+// IsPermanent returns true if err, or the error it wraps, will not
+// succeed if the request is retried
+func IsPermanent(err error) bool {
+	_, p := classify(err)
+	return p
+}
+
+// FailureReason returns the reason reported for the error, or an empty
+// string if the error is not one of the errors returned by the Fetcher
+func FailureReason(err error) string {
+	r, _ := classify(err)
+	return r
+}
+
+// classify returns the reason for an error and whether it is permanent
+func classify(err error) (string, bool) {
+	switch e := cause(err).(type) {
+	case *PermanentError:
+		return e.Reason, true
+	case *SizeError:
+		return ReasonTooLarge, true
+	case *ContentTypeError:
+		return ReasonUnsupportedContentType, true
+	case *HTTPStatusError:
+		return ReasonHTTPStatus, e.Permanent()
+	}
+
+	return "", false
+}
+
+// cause unwraps the errors returned by the http client and dialer
+func cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
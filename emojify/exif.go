@@ -0,0 +1,189 @@
+package emojify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// exifScanSize is the number of bytes read when looking for EXIF data,
+// the APP1 segment is limited to 64KB and must be near the start
+const exifScanSize = 128 * 1024
+
+// tagOrientation is the EXIF tag for the image orientation
+const tagOrientation = 0x0112
+
+// Orientation returns the EXIF orientation of a JPEG or TIFF image, 1 is
+// returned when the image has no orientation or is another format. The
+// reader is returned to the start.
+//
+//	1 normal           5 transposed
+//	2 flipped x        6 rotated 90 clockwise to display
+//	3 rotated 180      7 transverse
+//	4 flipped y        8 rotated 90 anti-clockwise to display
+func Orientation(r io.ReadSeeker) int {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return 1
+	}
+	defer r.Seek(0, os.SEEK_SET)
+
+	d, err := ioutil.ReadAll(io.LimitReader(r, exifScanSize))
+	if err != nil {
+		return 1
+	}
+
+	o := jpegOrientation(d)
+
+	// the first IFD of a TIFF file can be anywhere in the file
+	if isTIFF(d) {
+		if rest, err := ioutil.ReadAll(r); err == nil {
+			o = tiffOrientation(append(d, rest...))
+		}
+	}
+
+	if o < 1 || o > 8 {
+		return 1
+	}
+
+	return o
+}
+
+// jpegOrientation walks the JPEG markers to find the EXIF APP1 segment
+func jpegOrientation(d []byte) int {
+	if len(d) < 2 || d[0] != 0xFF || d[1] != 0xD8 {
+		return 0
+	}
+
+	for i := 2; i+4 <= len(d); {
+		if d[i] != 0xFF {
+			return 0
+		}
+
+		marker := d[i+1]
+
+		// markers may be preceded by fill bytes
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		// start of scan or end of image, EXIF data comes before the scan
+		if marker == 0xDA || marker == 0xD9 {
+			return 0
+		}
+
+		length := int(binary.BigEndian.Uint16(d[i+2:]))
+		if length < 2 || i+2+length > len(d) {
+			return 0
+		}
+
+		seg := d[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return tiffOrientation(seg[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 0
+}
+
+func isTIFF(d []byte) bool {
+	return bytes.HasPrefix(d, []byte("II*\x00")) || bytes.HasPrefix(d, []byte("MM\x00*"))
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of TIFF
+// formatted data, as used by EXIF
+func tiffOrientation(d []byte) int {
+	if len(d) < 8 {
+		return 0
+	}
+
+	var bo binary.ByteOrder
+	switch string(d[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0
+	}
+
+	if bo.Uint16(d[2:]) != 42 {
+		return 0
+	}
+
+	ifd := int(bo.Uint32(d[4:]))
+	if ifd < 8 || ifd+2 > len(d) {
+		return 0
+	}
+
+	n := int(bo.Uint16(d[ifd:]))
+	for i := 0; i < n; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(d) {
+			return 0
+		}
+
+		// orientation is a single SHORT stored in the value field
+		if bo.Uint16(d[e:]) == tagOrientation && bo.Uint16(d[e+2:]) == 3 {
+			return int(bo.Uint16(d[e+8:]))
+		}
+	}
+
+	return 0
+}
+
+// ApplyOrientation returns the image transformed so that it displays
+// correctly for the EXIF orientation o
+func ApplyOrientation(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// orientations 5 to 8 swap the width and height
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	// copy to RGBA first so pixels can be read without conversion
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,131 @@
+package emojify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var red = color.RGBA{255, 0, 0, 255}
+var blue = color.RGBA{0, 0, 255, 255}
+
+// exifSegment builds an APP1 segment containing only the orientation tag
+func exifSegment(bo binary.ByteOrder, o uint16) []byte {
+	tiff := new(bytes.Buffer)
+	if bo == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+
+	binary.Write(tiff, bo, uint16(42))
+	binary.Write(tiff, bo, uint32(8))
+	binary.Write(tiff, bo, uint16(1))
+	binary.Write(tiff, bo, uint16(tagOrientation))
+	binary.Write(tiff, bo, uint16(3))
+	binary.Write(tiff, bo, uint32(1))
+	binary.Write(tiff, bo, o)
+	binary.Write(tiff, bo, uint16(0))
+	binary.Write(tiff, bo, uint32(0))
+
+	data := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(data)+2))
+	return append(seg, data...)
+}
+
+// jpegWithOrientation encodes a 16x8 image, red on the left and blue on
+// the right, with the EXIF orientation o
+func jpegWithOrientation(t *testing.T, bo binary.ByteOrder, o uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	out := new(bytes.Buffer)
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// insert the segment after the start of image marker
+	d := out.Bytes()
+	return append(append(append([]byte{}, d[:2]...), exifSegment(bo, o)...), d[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func TestOrientationReadsEXIFInBothByteOrders(t *testing.T) {
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		r := bytes.NewReader(jpegWithOrientation(t, bo, 6))
+		assert.Equal(t, 6, Orientation(r))
+	}
+}
+
+func TestOrientationDefaultsWithoutEXIF(t *testing.T) {
+	out := new(bytes.Buffer)
+	jpeg.Encode(out, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil)
+
+	assert.Equal(t, 1, Orientation(bytes.NewReader(out.Bytes())))
+	assert.Equal(t, 1, Orientation(bytes.NewReader([]byte("not an image"))))
+	assert.Equal(t, 1, Orientation(bytes.NewReader(jpegWithOrientation(t, binary.BigEndian, 9))))
+}
+
+func TestReaderToImageAppliesOrientation(t *testing.T) {
+	f := NewFetcher(DefaultURLPolicy(), MaxFileSize)
+
+	img, err := f.ReaderToImage(bytes.NewReader(jpegWithOrientation(t, binary.LittleEndian, 6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotating clockwise moves the left of the image to the top
+	assert.Equal(t, image.Rect(0, 0, 8, 16), img.Bounds())
+	assert.True(t, isRed(img.At(4, 2)))
+	assert.False(t, isRed(img.At(4, 13)))
+}
+
+func TestApplyOrientationTransformsPixels(t *testing.T) {
+	// 2x3 image with distinct values, the expected output is listed row by row
+	src := image.NewGray(image.Rect(0, 0, 2, 3))
+	copy(src.Pix, []uint8{1, 2, 3, 4, 5, 6})
+
+	tt := map[int][]uint8{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {2, 1, 4, 3, 6, 5},
+		3: {6, 5, 4, 3, 2, 1},
+		4: {5, 6, 3, 4, 1, 2},
+		5: {1, 3, 5, 2, 4, 6},
+		6: {5, 3, 1, 6, 4, 2},
+		7: {6, 4, 2, 5, 3, 1},
+		8: {2, 4, 6, 1, 3, 5},
+	}
+
+	for o, want := range tt {
+		img := ApplyOrientation(src, o)
+		b := img.Bounds()
+
+		got := []uint8{}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				got = append(got, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			}
+		}
+
+		assert.Equal(t, want, got, "orientation %d", o)
+	}
+}
@@ -0,0 +1,250 @@
+package emojify
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+// Expression labels returned by classifiers
+const (
+	ExpressionHappy     = "happy"
+	ExpressionSad       = "sad"
+	ExpressionSurprised = "surprised"
+	ExpressionAngry     = "angry"
+	ExpressionNeutral   = "neutral"
+)
+
+// SelectExpression chooses emoji which match the expression of each face
+const SelectExpression = "expression"
+
+// DefaultMinConfidence is the confidence below which the expression
+// strategy falls back to a random emoji
+const DefaultMinConfidence = 0.5
+
+// Expression is the facial expression of a face
+type Expression struct {
+	Label string
+	// Confidence from 0 to 1 that the label is correct
+	Confidence float64
+}
+
+// Classifier finds the expression of a face
+type Classifier interface {
+	// Classify returns the expression of the face, the image is the area
+	// of the source image covered by the face
+	Classify(face image.Image) (Expression, error)
+}
+
+// ExpressionTable maps expression labels to the codepoints of emoji which
+// can be used for the expression
+type ExpressionTable map[string][]string
+
+// DefaultExpressionTable returns a table using the emoji in the images folder
+func DefaultExpressionTable() ExpressionTable {
+	return ExpressionTable{
+		ExpressionHappy:     {"1f600", "1f601", "1f603", "1f604", "1f60a", "1f642"},
+		ExpressionSad:       {"1f61e", "1f614", "1f61f", "1f622", "1f625", "1f641"},
+		ExpressionSurprised: {"1f62e", "1f62f", "1f631", "1f632", "1f633"},
+		ExpressionAngry:     {"1f620", "1f621", "1f624"},
+		ExpressionNeutral:   {"1f610", "1f611", "1f636"},
+	}
+}
+
+// LoadExpressionTable reads a table from a JSON file in the form
+// {"happy": ["1f600", "1f603"], "sad": ["1f61e"]}
+func LoadExpressionTable(file string) (ExpressionTable, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	t := ExpressionTable{}
+	err = json.Unmarshal(d, &t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse expression table %s: %s", file, err)
+	}
+
+	return t, nil
+}
+
+// ExpressionSelector is a selection strategy which chooses an emoji which
+// matches the expression of the face, faces are given a random emoji when
+// the classifier is not confident or the table has no emoji for the label
+type ExpressionSelector struct {
+	classifier    Classifier
+	table         ExpressionTable
+	minConfidence float64
+}
+
+// NewExpressionSelector creates an ExpressionSelector
+func NewExpressionSelector(c Classifier, t ExpressionTable, minConfidence float64) *ExpressionSelector {
+	return &ExpressionSelector{c, t, minConfidence}
+}
+
+// Select implements the Selector interface, random choices use the seed in
+// the options
+func (s *ExpressionSelector) Select(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+	r := seeded(o.Seed)
+	chosen := make([]*Emoji, len(faces))
+
+	for i, f := range faces {
+		exp, err := s.classifier.Classify(f)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := []int{}
+		if exp.Confidence >= s.minConfidence {
+			for _, c := range s.table[exp.Label] {
+				if j := findEmoji(emojis, c); j >= 0 {
+					candidates = append(candidates, j)
+				}
+			}
+		}
+
+		if len(candidates) == 0 {
+			chosen[i] = emojis[r.Intn(len(emojis))]
+			continue
+		}
+
+		chosen[i] = emojis[candidates[r.Intn(len(candidates))]]
+	}
+
+	return chosen, nil
+}
+
+// MouthClassifier is a Classifier which finds the expression from the shape
+// of the mouth, it runs on the CPU and only needs the face crop. The mouth
+// is found as the darkest line across the lower part of the face, a line
+// which curves up at the corners is a smile, one which curves down is a
+// frown and a tall dark area is an open mouth.
+type MouthClassifier struct{}
+
+// NewMouthClassifier creates a MouthClassifier
+func NewMouthClassifier() *MouthClassifier {
+	return &MouthClassifier{}
+}
+
+const (
+	// faces are scaled to a square of this size before classification
+	classifySize = 48
+	// minimum difference in luminance between the mouth and the skin
+	minMouthContrast = 20
+)
+
+// Classify implements the Classifier interface
+func (m *MouthClassifier) Classify(face image.Image) (Expression, error) {
+	if face.Bounds().Empty() {
+		return Expression{Label: ExpressionNeutral}, nil
+	}
+
+	img := resize.Resize(classifySize, classifySize, face, resize.Bilinear)
+	b := img.Bounds()
+
+	// the mouth is in the lower middle of the face
+	top, bottom := b.Min.Y+classifySize*62/100, b.Min.Y+classifySize*92/100
+	left, right := b.Min.X+classifySize/4, b.Min.X+classifySize*3/4
+
+	var us, ys, ws []float64
+	open := 0.0
+
+	for x := left; x < right; x++ {
+		darkest, brightest, row := 255.0, 0.0, top
+		for y := top; y < bottom; y++ {
+			l := float64(luminance(img.At(x, y)))
+			if l < darkest {
+				darkest, row = l, y
+			}
+			brightest = math.Max(brightest, l)
+		}
+
+		depth := brightest - darkest
+		if depth < minMouthContrast {
+			continue
+		}
+
+		// u is -1 at the left corner of the mouth and 1 at the right
+		u := (float64(x-left) + 0.5 - float64(right-left)/2) / (float64(right-left) / 2)
+		us = append(us, u)
+		ys = append(ys, float64(row-top))
+		ws = append(ws, depth)
+
+		// an open mouth is dark for much of the height in the centre
+		if math.Abs(u) < 0.3 {
+			dark := 0
+			for y := top; y < bottom; y++ {
+				if float64(luminance(img.At(x, y))) < darkest+depth/3 {
+					dark++
+				}
+			}
+			open = math.Max(open, float64(dark)/float64(bottom-top))
+		}
+	}
+
+	// confidence is reduced when the mouth can not be seen across the face
+	coverage := float64(len(us)) / float64(right-left)
+	if coverage < 0.5 {
+		return Expression{Label: ExpressionNeutral}, nil
+	}
+
+	if open > 0.4 {
+		return Expression{Label: ExpressionSurprised, Confidence: math.Min(1, open*1.5) * coverage}, nil
+	}
+
+	// rows increase down the face so a negative curve is a smile
+	a := curvature(us, ys, ws)
+	switch {
+	case a <= -1:
+		return Expression{Label: ExpressionHappy, Confidence: math.Min(1, -a/3) * coverage}, nil
+	case a >= 1:
+		return Expression{Label: ExpressionSad, Confidence: math.Min(1, a/3) * coverage}, nil
+	}
+
+	return Expression{Label: ExpressionNeutral, Confidence: (1 - math.Abs(a)) * coverage}, nil
+}
+
+// curvature fits y = a*u^2 + b*u + c with weighted least squares and
+// returns a
+func curvature(us, ys, ws []float64) float64 {
+	// sums of w*u^k for k = 0..4 and w*y*u^k for k = 0..2
+	var s [5]float64
+	var t [3]float64
+	for i, u := range us {
+		p := ws[i]
+		for k := 0; k < 5; k++ {
+			s[k] += p
+			if k < 3 {
+				t[k] += p * ys[i]
+			}
+			p *= u
+		}
+	}
+
+	// solve the normal equations with Cramer's rule
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+
+	n := [3][3]float64{
+		{s[4], s[3], s[2]},
+		{s[3], s[2], s[1]},
+		{s[2], s[1], s[0]},
+	}
+
+	d := det(n)
+	if d == 0 {
+		return 0
+	}
+
+	na := n
+	na[0][0], na[1][0], na[2][0] = t[2], t[1], t[0]
+
+	return det(na) / d
+}
@@ -0,0 +1,101 @@
+package emojify
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drawFace returns a 96x96 face with a mouth following y = centre + a*u^2
+// across the lower part of the face, height sets the height of the mouth
+func drawFace(a float64, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 96, 96))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{230, 190, 160, 255}), image.ZP, draw.Src)
+
+	for x := 24; x < 72; x++ {
+		u := float64(x-48) / 24
+		y := 72 + int(a*u*u)
+		draw.Draw(img, image.Rect(x, y-1, x+1, y+height), image.NewUniform(color.RGBA{90, 30, 30, 255}), image.ZP, draw.Src)
+	}
+
+	return img
+}
+
+func TestMouthClassifierFindsSmile(t *testing.T) {
+	e, _ := NewMouthClassifier().Classify(drawFace(-10, 2))
+
+	assert.Equal(t, ExpressionHappy, e.Label)
+	assert.True(t, e.Confidence > DefaultMinConfidence)
+}
+
+func TestMouthClassifierFindsFrown(t *testing.T) {
+	e, _ := NewMouthClassifier().Classify(drawFace(10, 2))
+
+	assert.Equal(t, ExpressionSad, e.Label)
+	assert.True(t, e.Confidence > DefaultMinConfidence)
+}
+
+func TestMouthClassifierFindsOpenMouth(t *testing.T) {
+	e, _ := NewMouthClassifier().Classify(drawFace(0, 16))
+
+	assert.Equal(t, ExpressionSurprised, e.Label)
+}
+
+func TestMouthClassifierIsNotConfidentWithoutMouth(t *testing.T) {
+	e, _ := NewMouthClassifier().Classify(solid(image.Rect(0, 0, 96, 96), color.White))
+
+	assert.True(t, e.Confidence < DefaultMinConfidence)
+}
+
+type testClassifier Expression
+
+func (c testClassifier) Classify(face image.Image) (Expression, error) {
+	return Expression(c), nil
+}
+
+func TestExpressionSelectorUsesTable(t *testing.T) {
+	e := setupSelection(t)
+	s := NewExpressionSelector(testClassifier{ExpressionSad, 0.9}, ExpressionTable{ExpressionSad: {"1f622", "1f4a9"}}, 0.5)
+
+	chosen, err := s.Select(make([]image.Image, 3), testEmojis(t, e), Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1f622", "1f622", "1f622"}, codepoints(chosen))
+}
+
+func TestExpressionSelectorFallsBackToRandomWhenNotConfident(t *testing.T) {
+	e := setupSelection(t)
+	s := NewExpressionSelector(testClassifier{ExpressionSad, 0.2}, ExpressionTable{ExpressionSad: {"1f622"}}, 0.5)
+
+	chosen, err := s.Select(make([]image.Image, 50), testEmojis(t, e), Options{Seed: "job-1"})
+
+	assert.Nil(t, err)
+	assert.Contains(t, codepoints(chosen), "1f600")
+}
+
+func TestExpressionStrategyChoosesSmilingEmojiForSmile(t *testing.T) {
+	e := setupSelection(t)
+	src := drawFace(-10, 2)
+
+	emojis, err := e.selectEmoji(cropFaces(src, Rects(src.Bounds())), Options{Selection: SelectExpression})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1f600"}, codepoints(emojis))
+}
+
+func TestLoadExpressionTable(t *testing.T) {
+	f, _ := ioutil.TempFile("", "table")
+	defer os.Remove(f.Name())
+	f.WriteString(`{"happy": ["1f600"]}`)
+	f.Close()
+
+	table, err := LoadExpressionTable(f.Name())
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExpressionTable{ExpressionHappy: {"1f600"}}, table)
+}
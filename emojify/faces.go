@@ -0,0 +1,124 @@
+package emojify
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/emojify-app/face-detection/client"
+)
+
+// Face is a face found by face detection
+type Face struct {
+	image.Rectangle
+	// Roll is the angle in degrees the head is tilted clockwise, it is zero
+	// when face detection does not return landmarks or the roll angle
+	Roll float64 `json:",omitempty"`
+}
+
+// Rects returns faces for rectangles found without landmarks
+func Rects(rects ...image.Rectangle) []Face {
+	faces := make([]Face, len(rects))
+	for i, r := range rects {
+		faces[i] = Face{Rectangle: r}
+	}
+
+	return faces
+}
+
+// Landmarks are points on a face, LeftEye is the eye nearest to the left
+// edge of the image
+type Landmarks struct {
+	LeftEye  image.Point
+	RightEye image.Point
+}
+
+// DetectionResponse is the face detection response including the optional
+// landmarks and roll angle, when set they have an entry for every face
+type DetectionResponse struct {
+	Faces     []image.Rectangle
+	Bounds    image.Rectangle
+	Landmarks []*Landmarks
+	Roll      []float64
+}
+
+// LandmarkDetector is implemented by face detection clients which return
+// landmarks or the roll angle of the faces
+type LandmarkDetector interface {
+	DetectLandmarks(r io.Reader) (*DetectionResponse, error)
+}
+
+// FaceDetect is a face detection client which decodes the landmarks and
+// roll angle returned by face detection services which support them
+type FaceDetect struct {
+	httpClient *http.Client
+	location   string
+}
+
+// NewFaceDetect creates a face detection client for the service at location
+func NewFaceDetect(location string) *FaceDetect {
+	return &FaceDetect{&http.Client{Timeout: 60 * time.Second}, location}
+}
+
+// DetectFaces implements the face detection client interface
+func (f *FaceDetect) DetectFaces(r io.Reader) (*client.Response, error) {
+	resp, err := f.DetectLandmarks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.Response{Faces: resp.Faces, Bounds: resp.Bounds}, nil
+}
+
+// DetectLandmarks finds the faces in an image along with any landmarks
+func (f *FaceDetect) DetectLandmarks(r io.Reader) (*DetectionResponse, error) {
+	resp, err := f.httpClient.Post(f.location, "application/octet-stream", r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("face detection returned status %d", resp.StatusCode)
+	}
+
+	dr := &DetectionResponse{}
+	err = json.NewDecoder(resp.Body).Decode(dr)
+	if err != nil {
+		return nil, err
+	}
+
+	return dr, nil
+}
+
+// faces returns the faces in the response, the roll angle is used when
+// set, otherwise it is calculated from the eyes
+func (d *DetectionResponse) faces() []Face {
+	faces := Rects(d.Faces...)
+
+	for i := range faces {
+		switch {
+		case i < len(d.Roll):
+			faces[i].Roll = d.Roll[i]
+		case i < len(d.Landmarks) && d.Landmarks[i] != nil:
+			faces[i].Roll = d.Landmarks[i].roll()
+		}
+	}
+
+	return faces
+}
+
+// roll returns the angle of the line between the eyes, y increases down
+// the image so a positive angle is a clockwise tilt
+func (l *Landmarks) roll() float64 {
+	d := l.RightEye.Sub(l.LeftEye)
+	if d == image.ZP {
+		return 0
+	}
+
+	return math.Atan2(float64(d.Y), float64(d.X)) * 180 / math.Pi
+}
@@ -0,0 +1,251 @@
+package emojify
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry holds the validators for a cached download
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Stored       time.Time `json:"stored"`
+}
+
+// DiskCache stores downloaded files on disk keyed by URL, entries are
+// revalidated with the server using conditional requests. When the files
+// are larger than the maximum size the least recently used are removed.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	size int64
+	// lru holds a *diskFile for each cached URL, most recently used first
+	lru   *list.List
+	files map[string]*list.Element
+}
+
+// diskFile is the size of the files stored for a URL
+type diskFile struct {
+	base string
+	size int64
+}
+
+// NewDiskCache creates a DiskCache in dir which holds at most maxSize
+// bytes, 0 is unlimited. The directory is created if it does not exist and
+// files already in it are counted towards the size.
+func NewDiskCache(dir string, maxSize int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	d := &DiskCache{dir: dir, maxSize: maxSize, lru: list.New(), files: map[string]*list.Element{}}
+	if err := d.scan(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// scan adds the files in the directory to the index, the modification time
+// of the data is the time it was last used
+func (d *DiskCache) scan() error {
+	infos, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	sizes := map[string]int64{}
+	used := map[string]time.Time{}
+	for _, fi := range infos {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || (ext != ".data" && ext != ".json") {
+			continue
+		}
+
+		base := filepath.Join(d.dir, strings.TrimSuffix(fi.Name(), ext))
+		sizes[base] += fi.Size()
+		if ext == ".data" {
+			used[base] = fi.ModTime()
+		}
+	}
+
+	bases := []string{}
+	for b := range sizes {
+		bases = append(bases, b)
+	}
+	sort.Slice(bases, func(i, j int) bool { return used[bases[i]].After(used[bases[j]]) })
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, b := range bases {
+		d.files[b] = d.lru.PushBack(&diskFile{base: b, size: sizes[b]})
+		d.size += sizes[b]
+	}
+	d.evict()
+
+	return nil
+}
+
+// get returns the entry and data for the URL, ok is false when the URL is
+// not cached or the entry can not be read
+func (d *DiskCache) get(uri string) (*cacheEntry, []byte, bool) {
+	base := d.path(uri)
+	defer d.used(base)
+
+	m, err := ioutil.ReadFile(base + ".json")
+	if err != nil {
+		return nil, nil, false
+	}
+
+	e := &cacheEntry{}
+	if err := json.Unmarshal(m, e); err != nil || e.URL != uri {
+		return nil, nil, false
+	}
+
+	data, err := ioutil.ReadFile(base + ".data")
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return e, data, true
+}
+
+// put stores the data for the URL when the response has a validator,
+// responses without a validator can not be revalidated and are not stored
+func (d *DiskCache) put(uri string, h http.Header, data []byte) error {
+	e := &cacheEntry{
+		URL:          uri,
+		ETag:         h.Get("ETag"),
+		LastModified: h.Get("Last-Modified"),
+		Stored:       time.Now(),
+	}
+
+	if e.ETag == "" && e.LastModified == "" {
+		return nil
+	}
+
+	m, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	size := int64(len(data) + len(m))
+	if d.maxSize > 0 && size > d.maxSize {
+		return nil
+	}
+
+	// write the data before the entry so a reader never finds an entry
+	// without data
+	base := d.path(uri)
+	if err := writeFileAtomic(base+".data", data); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(base+".json", m); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if el, ok := d.files[base]; ok {
+		f := el.Value.(*diskFile)
+		d.size += size - f.size
+		f.size = size
+		d.lru.MoveToFront(el)
+	} else {
+		d.files[base] = d.lru.PushFront(&diskFile{base: base, size: size})
+		d.size += size
+	}
+	d.evict()
+
+	return nil
+}
+
+// used marks the files for base as the most recently used, the
+// modification time is updated so the order is kept after a restart
+func (d *DiskCache) used(base string) {
+	d.mu.Lock()
+	el, ok := d.files[base]
+	if ok {
+		d.lru.MoveToFront(el)
+	}
+	d.mu.Unlock()
+
+	if ok {
+		now := time.Now()
+		os.Chtimes(base+".data", now, now)
+	}
+}
+
+// evict removes the least recently used files until the cache is no larger
+// than the maximum size, the lock must be held
+func (d *DiskCache) evict() {
+	for d.maxSize > 0 && d.size > d.maxSize {
+		el := d.lru.Back()
+		if el == nil {
+			return
+		}
+
+		// remove the entry before the data so a reader never finds an
+		// entry without data
+		f := el.Value.(*diskFile)
+		os.Remove(f.base + ".json")
+		os.Remove(f.base + ".data")
+
+		d.lru.Remove(el)
+		delete(d.files, f.base)
+		d.size -= f.size
+	}
+}
+
+// conditional adds the validators for the entry to the request
+func (e *cacheEntry) conditional(r *http.Request) {
+	if e.ETag != "" {
+		r.Header.Set("If-None-Match", e.ETag)
+	}
+
+	if e.LastModified != "" {
+		r.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+func (d *DiskCache) path(uri string) string {
+	h := sha256.Sum256([]byte(uri))
+	return filepath.Join(d.dir, hex.EncodeToString(h[:]))
+}
+
+// writeFileAtomic writes to a temporary file and renames it so concurrent
+// readers never see a partially written file
+func writeFileAtomic(name string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
@@ -2,15 +2,29 @@ package emojify
 
 import (
 	"bytes"
+	"fmt"
 	"image"
+	// decoders for the supported input formats, GIFs decode to the first frame
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"syscall"
 	"time"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
-// MaxFileSize is the maximum size of a file which can be downloaded
+// MaxFileSize is the default maximum size of a file which can be downloaded
 const MaxFileSize = 10000000 // 10MB
 
 // Fetcher defines an interface for downloading files
@@ -22,32 +36,215 @@ type Fetcher interface {
 // FetcherImpl is the concrete implementation of the Fetcher
 type FetcherImpl struct {
 	httpClient *http.Client
+	policy     URLPolicy
+	maxSize    int64
+	cache      *DiskCache
+	limiter    *hostLimiter
+	handlers   map[string]SchemeHandler
+	// failed requests are retried up to attempts times, the delay before
+	// each retry starts at backoff and doubles
+	attempts int
+	backoff  time.Duration
 }
 
-// NewFetcher creates a new fetcher
-func NewFetcher() Fetcher {
-	c := &http.Client{
+// NewFetcher creates a new fetcher which only downloads URLs allowed by
+// the policy, files larger than maxSize bytes are rejected
+func NewFetcher(p URLPolicy, maxSize int64) *FetcherImpl {
+	f := &FetcherImpl{policy: p, maxSize: maxSize, limiter: newHostLimiter(0, 0), attempts: 1}
+	f.handlers = map[string]SchemeHandler{
+		"http":  SchemeHandlerFunc(f.fetchHTTP),
+		"https": SchemeHandlerFunc(f.fetchHTTP),
+	}
+
+	// addresses are checked after DNS resolution when connecting, this
+	// also covers redirects and hosts which resolve to a different address
+	// after the URL has been checked
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   f.checkAddress,
+	}
+
+	f.httpClient = &http.Client{
 		Timeout: 60 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         d.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: f.checkRedirect,
 	}
 
-	return &FetcherImpl{c}
+	return f
+}
+
+// SetRetry retries requests which fail with network errors or a status
+// which may be transient, such as 503, up to attempts times in total
+func (f *FetcherImpl) SetRetry(attempts int, backoff time.Duration) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	f.attempts = attempts
+	f.backoff = backoff
+}
+
+// SetCache stores downloads in c, cached files are revalidated with a
+// conditional request and only downloaded again when they have changed
+func (f *FetcherImpl) SetCache(c *DiskCache) {
+	f.cache = c
+}
+
+// SetHostLimits limits the number of concurrent requests and the requests
+// per second made to each host, zero disables a limit
+func (f *FetcherImpl) SetHostLimits(concurrency int, rate float64) {
+	f.limiter = newHostLimiter(concurrency, rate)
+}
+
+// RegisterScheme sets the handler used to fetch URLs with the scheme,
+// the scheme must also be allowed by the URL policy
+func (f *FetcherImpl) RegisterScheme(scheme string, h SchemeHandler) {
+	f.handlers[strings.ToLower(scheme)] = h
 }
 
 // FetchImage does what it says on the tin
 func (f *FetcherImpl) FetchImage(uri string) (io.ReadSeeker, error) {
-	resp, err := f.httpClient.Get(uri)
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonURLBlocked, Err: err}
+	}
+
+	if err := f.policy.CheckScheme(u.Scheme); err != nil {
+		return nil, err
+	}
+
+	h, ok := f.handlers[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, blocked(ReasonURLBlocked, "scheme %q is not supported", u.Scheme)
+	}
+
+	delay := f.backoff
+	for attempt := 1; ; attempt++ {
+		buf, err := h.Fetch(u, f.maxSize)
+		if err == nil {
+			return bytes.NewReader(buf), nil
+		}
+
+		// permanent errors will fail in the same way
+		if IsPermanent(err) || attempt >= f.attempts {
+			return nil, err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// fetchHTTP makes a single request for the URL, using the cached copy when
+// the server reports it has not been modified
+func (f *FetcherImpl) fetchHTTP(u *url.URL, maxSize int64) ([]byte, error) {
+	if err := f.policy.CheckURL(u); err != nil {
+		return nil, err
+	}
+
+	done := f.limiter.acquire(u.Host)
+	defer done()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonURLBlocked, Err: err}
+	}
+
+	var entry *cacheEntry
+	var cached []byte
+	if f.cache != nil {
+		var ok bool
+		if entry, cached, ok = f.cache.get(u.String()); ok {
+			entry.conditional(req)
+		}
+	}
+
+	resp, err := f.httpClient.Do(req)
 	if err != nil {
+		// return the policy error rather than the error from the client
+		if IsPermanent(err) {
+			return nil, cause(err)
+		}
+
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	r := io.LimitReader(resp.Body, MaxFileSize)
-	buf, err := ioutil.ReadAll(r)
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		return cached, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	// reject early when the server reports the size
+	if resp.ContentLength > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: resp.ContentLength}
+	}
+
+	// read one byte more than the limit to detect bodies which are
+	// larger than the limit or their Content-Length
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
 		return nil, err
 	}
 
-	return bytes.NewReader(buf), nil
+	if int64(len(buf)) > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: -1}
+	}
+
+	// a failure to cache the file does not fail the download
+	if f.cache != nil {
+		f.cache.put(u.String(), resp.Header, buf)
+	}
+
+	return buf, nil
+}
+
+// checkContentType returns an error if the Content-Type is set and is not
+// an image, servers which do not know the type often send octet-stream
+func checkContentType(ct string) error {
+	if ct == "" {
+		return nil
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return &ContentTypeError{ContentType: ct}
+	}
+
+	if strings.HasPrefix(mt, "image/") || mt == "application/octet-stream" || mt == "binary/octet-stream" {
+		return nil
+	}
+
+	return &ContentTypeError{ContentType: mt}
+}
+
+func (f *FetcherImpl) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.policy.MaxRedirects {
+		return &PermanentError{
+			Reason: ReasonTooManyRedirects,
+			Err:    fmt.Errorf("stopped after %d redirects", f.policy.MaxRedirects),
+		}
+	}
+
+	return f.policy.CheckURL(req.URL)
+}
+
+// checkAddress is called by the dialer before connecting to the resolved address
+func (f *FetcherImpl) checkAddress(network, address string, c syscall.RawConn) error {
+	return f.policy.CheckAddress(network, address, c)
 }
 
 // ReaderToImage convert a io Reader to an image
@@ -57,10 +254,30 @@ func (f *FetcherImpl) ReaderToImage(r io.ReadSeeker) (image.Image, error) {
 		return nil, err
 	}
 
+	// the same data will always fail to decode
 	in, _, err := image.Decode(r)
 	if err != nil {
-		return nil, err
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: err}
+	}
+
+	// the pixels are stored as captured, rotate them to the orientation
+	// the image is displayed in
+	return ApplyOrientation(in, Orientation(r)), nil
+}
+
+// Format returns the name of the image format, e.g. jpeg or webp, or an
+// empty string if the format is not supported. The reader is returned to
+// the start.
+func Format(r io.ReadSeeker) string {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return ""
+	}
+	defer r.Seek(0, os.SEEK_SET)
+
+	_, f, err := image.DecodeConfig(r)
+	if err != nil {
+		return ""
 	}
 
-	return in, nil
+	return f
 }
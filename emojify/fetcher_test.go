@@ -0,0 +1,425 @@
+package emojify
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/image", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Write([]byte("image data"))
+	})
+	mux.HandleFunc("/large", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Length", "100")
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Write(make([]byte, 100))
+	})
+	mux.HandleFunc("/stream", func(rw http.ResponseWriter, r *http.Request) {
+		// flushing before writing the body sends a chunked response
+		rw.Header().Set("Content-Type", "image/png")
+		rw.(http.Flusher).Flush()
+		rw.Write(make([]byte, 100))
+	})
+	mux.HandleFunc("/html", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/unavailable", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/metadata", func(rw http.ResponseWriter, r *http.Request) {
+		http.Redirect(rw, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	})
+	mux.HandleFunc("/denied", func(rw http.ResponseWriter, r *http.Request) {
+		http.Redirect(rw, r, "http://internal.example.com/", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(rw http.ResponseWriter, r *http.Request) {
+		http.Redirect(rw, r, "/loop", http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func privatePolicy() URLPolicy {
+	p := DefaultURLPolicy()
+	p.AllowPrivate = true
+	return p
+}
+
+func TestFetchImageReturnsBody(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	r, err := NewFetcher(privatePolicy(), MaxFileSize).FetchImage(s.URL + "/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "image data", string(d))
+}
+
+func TestFetchImageBlocksLoopbackAddress(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	_, err := NewFetcher(DefaultURLPolicy(), MaxFileSize).FetchImage(s.URL + "/image")
+
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, ReasonAddressNotAllowed, FailureReason(err))
+}
+
+func TestFetchImageBlocksRedirectToPrivateAddress(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	// allow the test server but block link local addresses
+	p := privatePolicy()
+	f := NewFetcher(p, MaxFileSize)
+	f.policy.AllowPrivate = false
+	f.httpClient.Transport.(*http.Transport).DialContext = (&net.Dialer{Control: func(n, a string, c syscall.RawConn) error {
+		if a == s.Listener.Addr().String() {
+			return nil
+		}
+		return f.checkAddress(n, a, c)
+	}}).DialContext
+
+	_, err := f.FetchImage(s.URL + "/metadata")
+
+	assert.Equal(t, ReasonAddressNotAllowed, FailureReason(err))
+}
+
+func TestFetchImageBlocksRedirectToDeniedDomain(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	p := privatePolicy()
+	p.DenyDomains = []string{"example.com"}
+
+	_, err := NewFetcher(p, MaxFileSize).FetchImage(s.URL + "/denied")
+
+	assert.Equal(t, ReasonURLBlocked, FailureReason(err))
+}
+
+func TestFetchImageStopsAfterMaxRedirects(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	p := privatePolicy()
+	p.MaxRedirects = 2
+
+	_, err := NewFetcher(p, MaxFileSize).FetchImage(s.URL + "/loop")
+
+	assert.Equal(t, ReasonTooManyRedirects, FailureReason(err))
+}
+
+func TestCheckURLAppliesSchemeAndDomainLists(t *testing.T) {
+	p := DefaultURLPolicy()
+	p.AllowDomains = []string{"images.example.com"}
+	p.DenyDomains = []string{"private.images.example.com"}
+
+	tt := map[string]bool{
+		"https://images.example.com/a.jpg":         true,
+		"https://cdn.images.example.com/a.jpg":     true,
+		"https://IMAGES.example.com./a.jpg":        true,
+		"https://private.images.example.com/a.jpg": false,
+		"https://example.com/a.jpg":                false,
+		"https://badimages.example.com/a.jpg":      false,
+		"ftp://images.example.com/a.jpg":           false,
+		"file:///etc/passwd":                       false,
+	}
+
+	for uri, allowed := range tt {
+		u, _ := url.Parse(uri)
+		err := p.CheckURL(u)
+		assert.Equal(t, allowed, err == nil, uri)
+	}
+}
+
+func TestCheckIPBlocksInternalAddresses(t *testing.T) {
+	p := DefaultURLPolicy()
+
+	blocked := []string{"127.0.0.1", "10.1.2.3", "172.20.0.1", "192.168.1.1", "169.254.169.254", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "0.0.0.0"}
+	for _, ip := range blocked {
+		assert.Error(t, p.CheckIP(net.ParseIP(ip)), ip)
+	}
+
+	allowed := []string{"8.8.8.8", "151.101.1.69", "2606:4700::1111"}
+	for _, ip := range allowed {
+		assert.Nil(t, p.CheckIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestFetchImageRejectsLargeContentLength(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	_, err := NewFetcher(privatePolicy(), 50).FetchImage(s.URL + "/large")
+
+	assert.Equal(t, &SizeError{Limit: 50, Size: 100}, err)
+	assert.Equal(t, ReasonTooLarge, FailureReason(err))
+	assert.True(t, IsPermanent(err))
+}
+
+func TestFetchImageRejectsLargeStreamedBody(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	_, err := NewFetcher(privatePolicy(), 50).FetchImage(s.URL + "/stream")
+
+	assert.Equal(t, &SizeError{Limit: 50, Size: -1}, err)
+}
+
+func TestFetchImageAllowsBodyAtLimit(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	r, err := NewFetcher(privatePolicy(), 100).FetchImage(s.URL + "/stream")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, r)
+}
+
+func TestFetchImageReturnsHTTPStatusError(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+	f := NewFetcher(privatePolicy(), MaxFileSize)
+
+	_, err := f.FetchImage(s.URL + "/missing")
+	assert.Equal(t, &HTTPStatusError{StatusCode: http.StatusNotFound}, err)
+	assert.Equal(t, ReasonHTTPStatus, FailureReason(err))
+	assert.True(t, IsPermanent(err))
+
+	_, err = f.FetchImage(s.URL + "/unavailable")
+	assert.Equal(t, ReasonHTTPStatus, FailureReason(err))
+	assert.False(t, IsPermanent(err))
+}
+
+func TestFetchImageRejectsNonImageContentType(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	_, err := NewFetcher(privatePolicy(), MaxFileSize).FetchImage(s.URL + "/html")
+
+	assert.Equal(t, &ContentTypeError{ContentType: "text/html"}, err)
+	assert.Equal(t, ReasonUnsupportedContentType, FailureReason(err))
+}
+
+func TestReaderToImageReturnsInvalidImageError(t *testing.T) {
+	_, err := NewFetcher(DefaultURLPolicy(), MaxFileSize).ReaderToImage(bytes.NewReader([]byte("not an image")))
+
+	assert.Equal(t, ReasonInvalidImage, FailureReason(err))
+}
+
+func TestFetchImageRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Write([]byte("image data"))
+	}))
+	defer s.Close()
+
+	f := NewFetcher(privatePolicy(), MaxFileSize)
+	f.SetRetry(3, time.Millisecond)
+
+	_, err := f.FetchImage(s.URL)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestFetchImageDoesNotRetryPermanentErrors(t *testing.T) {
+	calls := 0
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	f := NewFetcher(privatePolicy(), MaxFileSize)
+	f.SetRetry(3, time.Millisecond)
+
+	_, err := f.FetchImage(s.URL)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestFetchImageRevalidatesCachedFile(t *testing.T) {
+	conditional := 0
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		rw.Header().Set("ETag", `"v1"`)
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Write([]byte("image data"))
+	}))
+	defer s.Close()
+
+	dir, _ := ioutil.TempDir("", "fetchcache")
+	defer os.RemoveAll(dir)
+
+	dc, _ := NewDiskCache(dir, 0)
+	f := NewFetcher(privatePolicy(), MaxFileSize)
+	f.SetCache(dc)
+
+	f.FetchImage(s.URL)
+	r, err := f.FetchImage(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "image data", string(d))
+	assert.Equal(t, 1, conditional)
+}
+
+func TestDiskCacheRemovesLeastRecentlyUsedFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "fetchcache")
+	defer os.RemoveAll(dir)
+
+	dc, _ := NewDiskCache(dir, 0)
+	h := http.Header{"Etag": []string{`"v1"`}}
+
+	dc.put("http://abc.com/a", h, []byte("image data"))
+	size := dc.size
+
+	// room for two files
+	dc.maxSize = 2*size + size/2
+	dc.put("http://abc.com/b", h, []byte("image data"))
+	dc.get("http://abc.com/a")
+	dc.put("http://abc.com/c", h, []byte("image data"))
+
+	_, _, ok := dc.get("http://abc.com/b")
+	assert.False(t, ok)
+
+	for _, u := range []string{"http://abc.com/a", "http://abc.com/c"} {
+		_, d, ok := dc.get(u)
+		assert.True(t, ok, u)
+		assert.Equal(t, "image data", string(d))
+	}
+
+	// files already in the directory are counted when the cache is
+	// created, entries differ in size by the length of the stored time
+	dc, _ = NewDiskCache(dir, size+size/2)
+	assert.InDelta(t, size, dc.size, float64(size/2))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 2)
+}
+
+func TestFetchImageLimitsConcurrentRequestsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	f := NewFetcher(privatePolicy(), MaxFileSize)
+	f.SetHostLimits(2, 0)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			f.FetchImage(s.URL)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, peak)
+}
+
+func TestHostLimiterSpacesRequests(t *testing.T) {
+	l := newHostLimiter(0, 100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		l.acquire("a")()
+	}
+
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestHostLimiterRemovesIdleHosts(t *testing.T) {
+	l := newHostLimiter(1, 0)
+	l.acquire("a")()
+	assert.Len(t, l.hosts, 0)
+
+	// the host is kept until the rate no longer delays the next request
+	l = newHostLimiter(1, 20)
+	l.acquire("a")()
+
+	l.mu.Lock()
+	assert.Len(t, l.hosts, 1)
+	l.mu.Unlock()
+
+	for i := 0; i < 100; i++ {
+		l.mu.Lock()
+		n := len(l.hosts)
+		l.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected the idle host to be removed")
+}
+
+func TestPolicyDialerBlocksPrivateAddresses(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	_, err := DefaultURLPolicy().Dialer().Dial("tcp", s.Listener.Addr().String())
+	assert.Equal(t, ReasonAddressNotAllowed, FailureReason(err))
+
+	p := DefaultURLPolicy()
+	p.AllowAddresses = []string{s.Listener.Addr().String()}
+	if c, err := p.Dialer().Dial("tcp", s.Listener.Addr().String()); assert.Nil(t, err) {
+		c.Close()
+	}
+
+	c, err := privatePolicy().Dialer().Dial("tcp", s.Listener.Addr().String())
+	if assert.Nil(t, err) {
+		c.Close()
+	}
+}
@@ -0,0 +1,136 @@
+package emojify
+
+import (
+	"image"
+	"sort"
+)
+
+// FaceFilter removes false positives and duplicates from the faces
+// returned by face detection, the zero value keeps every face
+type FaceFilter struct {
+	// MinSize is the smallest width and height in pixels of a face
+	MinSize int
+	// MinRelativeSize is the smallest width and height of a face as a
+	// fraction of the shorter side of the image, e.g. 0.02
+	MinRelativeSize float64
+	// MaxOverlap is the largest intersection over union of two faces
+	// before the smaller face is removed as a duplicate, 0 keeps
+	// overlapping faces
+	MaxOverlap float64
+	// MaxFaces is the largest number of faces kept, the largest faces are
+	// kept, 0 is unlimited
+	MaxFaces int
+	// Clamp limits faces to the bounds of the image, faces which are
+	// outside of the image are removed
+	Clamp bool
+}
+
+// Apply returns the faces in the image with the given bounds which pass
+// the filter, faces keep their order
+func (f FaceFilter) Apply(faces []Face, bounds image.Rectangle) []Face {
+	short := bounds.Dx()
+	if bounds.Dy() < short {
+		short = bounds.Dy()
+	}
+
+	min := float64(f.MinSize)
+	if rel := f.MinRelativeSize * float64(short); rel > min {
+		min = rel
+	}
+
+	candidates := []Face{}
+	for _, face := range faces {
+		if f.Clamp {
+			face.Rectangle = face.Rectangle.Intersect(bounds)
+		}
+
+		if face.Empty() || float64(face.Dx()) < min || float64(face.Dy()) < min {
+			continue
+		}
+
+		candidates = append(candidates, face)
+	}
+
+	// larger faces are more likely to be real so they are considered
+	// first, the index restores the order afterwards
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return area(candidates[order[i]].Rectangle) > area(candidates[order[j]].Rectangle)
+	})
+
+	kept := []int{}
+	for _, i := range order {
+		if f.MaxFaces > 0 && len(kept) == f.MaxFaces {
+			break
+		}
+
+		if f.MaxOverlap > 0 && f.overlaps(candidates[i], candidates, kept) {
+			continue
+		}
+
+		kept = append(kept, i)
+	}
+	sort.Ints(kept)
+
+	out := make([]Face, len(kept))
+	for i, k := range kept {
+		out[i] = candidates[k]
+	}
+
+	return out
+}
+
+// overlaps returns true when face overlaps one of the kept faces by more
+// than MaxOverlap
+func (f FaceFilter) overlaps(face Face, faces []Face, kept []int) bool {
+	for _, k := range kept {
+		if overlap(face.Rectangle, faces[k].Rectangle) > f.MaxOverlap {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Merge returns the faces in the image with the given bounds with faces
+// which overlap by more than MaxOverlap replaced by a face covering both.
+// Faces are not removed for their size or number, redaction uses Merge so
+// every pixel of every face is redacted.
+func (f FaceFilter) Merge(faces []Face, bounds image.Rectangle) []Face {
+	out := []Face{}
+	for _, face := range faces {
+		if f.Clamp {
+			face.Rectangle = face.Rectangle.Intersect(bounds)
+		}
+
+		if !face.Empty() {
+			out = append(out, face)
+		}
+	}
+
+	if f.MaxOverlap <= 0 {
+		return out
+	}
+
+	// a merged face can overlap faces which were checked before the
+	// merge, repeat until no faces overlap
+	for merged := true; merged; {
+		merged = false
+
+		for i := 0; i < len(out) && !merged; i++ {
+			for j := i + 1; j < len(out); j++ {
+				if overlap(out[i].Rectangle, out[j].Rectangle) > f.MaxOverlap {
+					out[i] = Face{Rectangle: out[i].Union(out[j].Rectangle)}
+					out = append(out[:j], out[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,124 @@
+package emojify
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var filterBounds = image.Rect(0, 0, 400, 200)
+
+func TestZeroFaceFilterKeepsEveryFace(t *testing.T) {
+	faces := Rects(image.Rect(0, 0, 2, 2), image.Rect(10, 10, 50, 50), image.Rect(12, 12, 52, 52), image.Rect(380, 180, 420, 220))
+
+	assert.Equal(t, faces, FaceFilter{}.Apply(faces, filterBounds))
+}
+
+func TestFaceFilterRemovesSmallFaces(t *testing.T) {
+	faces := Rects(image.Rect(0, 0, 10, 40), image.Rect(50, 50, 70, 70), image.Rect(100, 100, 119, 140))
+
+	assert.Equal(t, faces[1:], FaceFilter{MinSize: 11}.Apply(faces, filterBounds))
+
+	// 10% of the shorter side of the image is 20 pixels
+	assert.Equal(t, faces[1:2], FaceFilter{MinSize: 11, MinRelativeSize: 0.1}.Apply(faces, filterBounds))
+}
+
+func TestFaceFilterSuppressesOverlappingFaces(t *testing.T) {
+	faces := Rects(
+		image.Rect(10, 10, 50, 50),
+		image.Rect(200, 20, 260, 80),
+		image.Rect(12, 12, 54, 54),
+		image.Rect(205, 25, 255, 75),
+		image.Rect(40, 40, 80, 80),
+	)
+
+	// the larger of each duplicate is kept, the face which only overlaps
+	// slightly is not a duplicate
+	assert.Equal(t, []Face{faces[1], faces[2], faces[4]}, FaceFilter{MaxOverlap: 0.5}.Apply(faces, filterBounds))
+}
+
+func TestFaceFilterKeepsLargestFacesInOrder(t *testing.T) {
+	faces := Rects(image.Rect(0, 0, 20, 20), image.Rect(30, 0, 90, 60), image.Rect(100, 0, 130, 30), image.Rect(140, 0, 180, 40))
+
+	assert.Equal(t, []Face{faces[1], faces[3]}, FaceFilter{MaxFaces: 2}.Apply(faces, filterBounds))
+}
+
+func TestFaceFilterClampsFacesToImage(t *testing.T) {
+	faces := []Face{
+		{Rectangle: image.Rect(-10, -10, 30, 30), Roll: 10},
+		{Rectangle: image.Rect(390, 150, 430, 190)},
+		{Rectangle: image.Rect(500, 500, 540, 540)},
+	}
+
+	f := FaceFilter{Clamp: true}.Apply(faces, filterBounds)
+
+	assert.Equal(t, []Face{
+		{Rectangle: image.Rect(0, 0, 30, 30), Roll: 10},
+		{Rectangle: image.Rect(390, 150, 400, 190)},
+	}, f)
+}
+
+func TestFaceFilterAppliesMinimumSizeAfterClamping(t *testing.T) {
+	faces := Rects(image.Rect(390, 150, 430, 190))
+
+	assert.Empty(t, FaceFilter{Clamp: true, MinSize: 20}.Apply(faces, filterBounds))
+}
+
+func TestFaceFilterMergeKeepsSmallAndExcessFaces(t *testing.T) {
+	faces := Rects(image.Rect(0, 0, 2, 2), image.Rect(10, 10, 50, 50), image.Rect(100, 100, 110, 110))
+
+	f := FaceFilter{MinSize: 20, MinRelativeSize: 0.5, MaxFaces: 1}.Merge(faces, filterBounds)
+
+	assert.Equal(t, faces, f)
+}
+
+func TestFaceFilterMergeCombinesOverlappingFaces(t *testing.T) {
+	faces := []Face{
+		{Rectangle: image.Rect(10, 10, 50, 50), Roll: 20},
+		{Rectangle: image.Rect(200, 20, 260, 80)},
+		{Rectangle: image.Rect(12, 12, 54, 54)},
+		{Rectangle: image.Rect(10, 14, 54, 60)},
+	}
+
+	f := FaceFilter{MaxOverlap: 0.5}.Merge(faces, filterBounds)
+
+	assert.Equal(t, Rects(image.Rect(10, 10, 54, 60), image.Rect(200, 20, 260, 80)), f)
+}
+
+func TestEmojimiseRedactsFacesRemovedByFilter(t *testing.T) {
+	e := setupSelection(t)
+	e.SetFaceFilter(FaceFilter{MinSize: 20, MaxOverlap: 0.3, MaxFaces: 1})
+	src := solid(image.Rect(0, 0, 100, 100), color.White)
+
+	// a small face, and a duplicate which extends past the larger face
+	faces := Rects(image.Rect(70, 70, 80, 80), image.Rect(10, 10, 40, 40), image.Rect(14, 14, 44, 44))
+
+	dst, err := e.Emojimise(src, faces, Options{Mode: ModeFill})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range faces {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(x, y), "pixel %d,%d should be redacted", x, y)
+			}
+		}
+	}
+}
+
+func TestEmojimiseFiltersFacesForEmoji(t *testing.T) {
+	e := setupSelection(t)
+	e.SetFaceFilter(FaceFilter{MinSize: 20})
+	src := solid(image.Rect(0, 0, 100, 100), color.Black)
+
+	dst, err := e.Emojimise(src, Rects(image.Rect(70, 70, 80, 80), image.Rect(10, 10, 40, 40)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(75, 75), "the small face should not be replaced")
+	assert.NotEqual(t, color.RGBA{0, 0, 0, 255}, dst.At(25, 25))
+}
@@ -0,0 +1,81 @@
+package emojify
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readFixture(t *testing.T, name string) *bytes.Reader {
+	d, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(d)
+}
+
+func TestReaderToImageDecodesSupportedFormats(t *testing.T) {
+	tt := []struct {
+		file   string
+		format string
+		bounds image.Rectangle
+	}{
+		{"image.png", "png", image.Rect(0, 0, 32, 24)},
+		{"image.jpg", "jpeg", image.Rect(0, 0, 32, 24)},
+		{"image.gif", "gif", image.Rect(0, 0, 32, 24)},
+		{"image.bmp", "bmp", image.Rect(0, 0, 32, 24)},
+		{"image.tiff", "tiff", image.Rect(0, 0, 32, 24)},
+		{"lossy.webp", "webp", image.Rect(0, 0, 1, 1)},
+		{"lossless.webp", "webp", image.Rect(0, 0, 1, 1)},
+		{"alpha.webp", "webp", image.Rect(0, 0, 1, 1)},
+	}
+
+	f := NewFetcher(DefaultURLPolicy(), MaxFileSize)
+
+	for _, tc := range tt {
+		r := readFixture(t, tc.file)
+
+		img, err := f.ReaderToImage(r)
+		if !assert.Nil(t, err, tc.file) {
+			continue
+		}
+
+		assert.Equal(t, tc.bounds, img.Bounds(), tc.file)
+		assert.Equal(t, tc.format, Format(r), tc.file)
+	}
+}
+
+func TestReaderToImageDecodesPixels(t *testing.T) {
+	f := NewFetcher(DefaultURLPolicy(), MaxFileSize)
+
+	// the fixtures are red on the left and blue on the right
+	for _, file := range []string{"image.png", "image.jpg", "image.bmp", "image.tiff"} {
+		img, err := f.ReaderToImage(readFixture(t, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, isRed(img.At(4, 12)), file)
+		assert.False(t, isRed(img.At(28, 12)), file)
+	}
+}
+
+func TestReaderToImageUsesFirstFrameOfGIF(t *testing.T) {
+	f := NewFetcher(DefaultURLPolicy(), MaxFileSize)
+
+	// the second frame is green
+	img, err := f.ReaderToImage(readFixture(t, "image.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, isRed(img.At(4, 12)))
+}
+
+func TestFormatReturnsEmptyForUnknownData(t *testing.T) {
+	assert.Equal(t, "", Format(bytes.NewReader([]byte("not an image"))))
+}
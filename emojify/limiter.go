@@ -0,0 +1,102 @@
+package emojify
+
+import (
+	"sync"
+	"time"
+)
+
+// hostLimiter limits the number of concurrent requests and the request
+// rate for each host, hosts are forgotten once they have no requests and
+// the rate no longer delays the next request
+type hostLimiter struct {
+	concurrency int
+	interval    time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+	next  time.Time
+	// active is the number of requests waiting or in flight
+	active int
+}
+
+// newHostLimiter creates a limiter allowing concurrency requests in flight
+// and rate requests per second to each host, zero disables a limit
+func newHostLimiter(concurrency int, rate float64) *hostLimiter {
+	l := &hostLimiter{concurrency: concurrency, hosts: map[string]*hostState{}}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return l
+}
+
+// acquire blocks until a request to host is allowed, the returned
+// function must be called when the request completes
+func (l *hostLimiter) acquire(host string) func() {
+	l.mu.Lock()
+	s, ok := l.hosts[host]
+	if !ok {
+		s = &hostState{}
+		if l.concurrency > 0 {
+			s.slots = make(chan struct{}, l.concurrency)
+		}
+
+		l.hosts[host] = s
+	}
+	s.active++
+
+	// reserve the next start time so waiting requests are spaced by
+	// the interval
+	wait := time.Duration(0)
+	if l.interval > 0 {
+		now := time.Now()
+		if s.next.Before(now) {
+			s.next = now
+		}
+
+		wait = s.next.Sub(now)
+		s.next = s.next.Add(l.interval)
+	}
+	l.mu.Unlock()
+
+	if s.slots != nil {
+		s.slots <- struct{}{}
+	}
+
+	time.Sleep(wait)
+
+	return func() {
+		if s.slots != nil {
+			<-s.slots
+		}
+
+		l.mu.Lock()
+		s.active--
+		l.remove(host, s)
+		l.mu.Unlock()
+	}
+}
+
+// remove deletes the state for an idle host, when the next request would
+// still be delayed removal waits until the delay has passed. The lock must
+// be held.
+func (l *hostLimiter) remove(host string, s *hostState) {
+	if s.active > 0 || l.hosts[host] != s {
+		return
+	}
+
+	if d := time.Until(s.next); d > 0 {
+		time.AfterFunc(d, func() {
+			l.mu.Lock()
+			l.remove(host, s)
+			l.mu.Unlock()
+		})
+		return
+	}
+
+	delete(l.hosts, host)
+}
@@ -0,0 +1,48 @@
+package emojify
+
+import (
+	"image"
+
+	"github.com/nfnt/resize"
+)
+
+// minVariantSize is the size below which no more pre-scaled variants of
+// an emoji are made
+const minVariantSize = 16
+
+// variants returns pre-scaled copies of img, each half the size of the
+// previous one, largest first. Each variant is resized from the original
+// so errors do not accumulate between the levels.
+func variants(img image.Image) []image.Image {
+	v := []image.Image{}
+
+	s := img.Bounds().Size()
+	for w, h := s.X/2, s.Y/2; w >= minVariantSize && h >= minVariantSize; w, h = w/2, h/2 {
+		v = append(v, resize.Resize(uint(w), uint(h), img, resize.Lanczos3))
+	}
+
+	return v
+}
+
+// scaled returns the emoji resized to w x h. The resize starts from the
+// smallest pre-scaled variant which is at least as large as the result, the
+// cost of a Lanczos resize grows with the size of the source so this is
+// much cheaper than resizing the original for small faces.
+func (e *Emoji) scaled(w, h int) image.Image {
+	return resize.Resize(uint(w), uint(h), e.nearest(w, h), resize.Lanczos3)
+}
+
+// nearest returns the smallest image of the emoji which is at least w x h
+func (e *Emoji) nearest(w, h int) image.Image {
+	src := e.Image
+	for _, v := range e.variants {
+		s := v.Bounds().Size()
+		if s.X < w || s.Y < h {
+			break
+		}
+
+		src = v
+	}
+
+	return src
+}
@@ -0,0 +1,84 @@
+package emojify
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sizes(images []image.Image) []image.Point {
+	s := make([]image.Point, len(images))
+	for i, img := range images {
+		s[i] = img.Bounds().Size()
+	}
+
+	return s
+}
+
+func TestVariantsHalveDownToMinimumSize(t *testing.T) {
+	v := variants(solid(image.Rect(0, 0, 128, 96), color.White))
+
+	assert.Equal(t, []image.Point{{64, 48}, {32, 24}}, sizes(v))
+}
+
+func TestNewPackMakesVariants(t *testing.T) {
+	p := NewPack([]*Emoji{{Codepoint: "1f600", Image: solid(image.Rect(0, 0, 128, 128), color.White)}})
+
+	assert.Equal(t, []image.Point{{64, 64}, {32, 32}, {16, 16}}, sizes(p.Emojis()[0].variants))
+}
+
+func TestNearestUsesSmallestVariantLargerThanSize(t *testing.T) {
+	e := NewPack([]*Emoji{{Codepoint: "1f600", Image: solid(image.Rect(0, 0, 128, 128), color.White)}}).Emojis()[0]
+
+	assert.Equal(t, image.Pt(128, 128), e.nearest(100, 100).Bounds().Size())
+	assert.Equal(t, image.Pt(64, 64), e.nearest(64, 64).Bounds().Size())
+	assert.Equal(t, image.Pt(32, 32), e.nearest(30, 20).Bounds().Size())
+	assert.Equal(t, image.Pt(16, 16), e.nearest(8, 8).Bounds().Size())
+	assert.Equal(t, image.Pt(20, 20), e.scaled(20, 20).Bounds().Size())
+}
+
+// benchmarkEmojimise draws emoji over a group photo with n faces of the
+// given size
+func benchmarkEmojimise(b *testing.B, n, size int, prescale bool) {
+	pack, err := LoadPack("../images/")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if !prescale {
+		for _, e := range pack.Emojis() {
+			e.variants = []image.Image{}
+		}
+	}
+
+	e := &Impl{packs: StaticPacks(map[string]*Pack{DefaultPack: pack}), padding: 1.2, selectors: map[string]Selector{}}
+
+	src := solid(image.Rect(0, 0, 1280, 960), color.Gray{128})
+	faces := []Face{}
+	for i := 0; i < n; i++ {
+		x := (i % 16) * 80
+		y := (i / 16) * 80
+		faces = append(faces, Face{Rectangle: image.Rect(x+10, y+10, x+10+size, y+10+size)})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Emojimise(src, faces, Options{Selection: SelectRoundRobin}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEmojimise(b *testing.B) {
+	for _, bc := range []struct{ faces, size int }{{1, 200}, {32, 48}, {128, 24}} {
+		for _, prescale := range []bool{false, true} {
+			name := fmt.Sprintf("faces=%d/size=%d/prescaled=%t", bc.faces, bc.size, prescale)
+			b.Run(name, func(b *testing.B) {
+				benchmarkEmojimise(b, bc.faces, bc.size, prescale)
+			})
+		}
+	}
+}
@@ -14,8 +14,8 @@ type MockEmojify struct {
 }
 
 // Emojimise is a mock implementation of the interface function
-func (m *MockEmojify) Emojimise(src image.Image, faces []image.Rectangle) (image.Image, error) {
-	args := m.Called(src, faces)
+func (m *MockEmojify) Emojimise(src image.Image, faces []Face, o Options) (image.Image, error) {
+	args := m.Called(src, faces, o)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -24,8 +24,25 @@ func (m *MockEmojify) Emojimise(src image.Image, faces []image.Rectangle) (image
 	return args.Get(0).(image.Image), args.Error(1)
 }
 
+// EmojimiseFrames is a mock implementation of the interface function
+func (m *MockEmojify) EmojimiseFrames(frames []image.Image, faces [][]Face, o Options) ([]image.Image, error) {
+	args := m.Called(frames, faces, o)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]image.Image), args.Error(1)
+}
+
+// Seeded is a mock implementation of the interface function
+func (m *MockEmojify) Seeded(o Options) bool {
+	args := m.Called(o)
+	return args.Bool(0)
+}
+
 // GetFaces is a mock implementation of the interface function
-func (m *MockEmojify) GetFaces(r io.ReadSeeker) ([]image.Rectangle, error) {
+func (m *MockEmojify) GetFaces(r io.ReadSeeker) ([]Face, error) {
 	args := m.Called(r)
 
 	// wait for the client to block
@@ -35,7 +52,7 @@ func (m *MockEmojify) GetFaces(r io.ReadSeeker) ([]image.Rectangle, error) {
 		return nil, args.Error(1)
 	}
 
-	return args.Get(0).([]image.Rectangle), args.Error(1)
+	return args.Get(0).([]Face), args.Error(1)
 }
 
 // Health is a mock implementation of the interface function
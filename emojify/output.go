@@ -0,0 +1,137 @@
+package emojify
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Processing options which control the output image
+const (
+	// OptionFormat selects the output format, png, jpeg or gif, when not
+	// set the format of the source image is used
+	OptionFormat = "format"
+	// OptionQuality sets the JPEG quality from 1 to 100
+	OptionQuality = "quality"
+)
+
+// Output formats
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+)
+
+// OutputFormat returns the format an image should be encoded as, the
+// requested format is used when set, otherwise the input format when it
+// can be encoded. Other images are encoded as PNG when they have
+// transparency and JPEG when they do not.
+func OutputFormat(requested, input string, img image.Image) string {
+	if requested != "" {
+		return requested
+	}
+
+	switch input {
+	case FormatPNG, FormatJPEG, FormatGIF:
+		return input
+	}
+
+	if o, ok := img.(opaque); ok && o.Opaque() {
+		return FormatJPEG
+	}
+
+	return FormatPNG
+}
+
+// ContentType returns the MIME type for an output format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// knownOptions are the option keys which are accepted by ValidateOptions
+var knownOptions = map[string]bool{
+	OptionFormat:    true,
+	OptionQuality:   true,
+	OptionSelection: true,
+	OptionCodepoint: true,
+	OptionTag:       true,
+	OptionPack:      true,
+	OptionMode:      true,
+	OptionBlockSize: true,
+	OptionExpand:    true,
+	OptionFill:      true,
+	OptionFeather:   true,
+	OptionShadow:    true,
+	OptionOpacity:   true,
+}
+
+// ValidateOptions checks the output, selection, redaction and blend
+// options, an error is returned for unknown options, unsupported formats
+// and modes or values which are out of range. The emoji chosen by the
+// selection options depend on the loaded packs and are checked with
+// Impl.ValidateSelection.
+func ValidateOptions(options map[string]string) error {
+	unknown := []string{}
+	for k := range options {
+		if !knownOptions[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown option %q", unknown[0])
+	}
+
+	switch f := options[OptionFormat]; f {
+	case "", FormatPNG, FormatJPEG, FormatGIF:
+	default:
+		return fmt.Errorf("unsupported format %q, must be png, jpeg or gif", f)
+	}
+
+	if q, ok := options[OptionQuality]; ok {
+		if _, err := Quality(q); err != nil {
+			return err
+		}
+	}
+
+	if err := validateSelection(options); err != nil {
+		return err
+	}
+
+	if err := validateRedaction(options); err != nil {
+		return err
+	}
+
+	return validateBlend(options)
+}
+
+// Quality parses a JPEG quality option
+func Quality(q string) (int, error) {
+	i, err := strconv.Atoi(q)
+	if err != nil || i < 1 || i > 100 {
+		return 0, fmt.Errorf("invalid quality %q, must be between 1 and 100", q)
+	}
+
+	return i, nil
+}
+
+// Encode writes the image in the given format, quality is only used for
+// JPEG images
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatGIF:
+		return gif.Encode(w, toPaletted(img), nil)
+	}
+
+	return fmt.Errorf("unsupported format %q", format)
+}
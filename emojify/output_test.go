@@ -0,0 +1,54 @@
+package emojify
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputFormatUsesRequestedFormat(t *testing.T) {
+	assert.Equal(t, FormatPNG, OutputFormat(FormatPNG, FormatJPEG, nil))
+}
+
+func TestOutputFormatDefaultsToInputFormat(t *testing.T) {
+	assert.Equal(t, FormatPNG, OutputFormat("", FormatPNG, nil))
+	assert.Equal(t, FormatGIF, OutputFormat("", FormatGIF, nil))
+}
+
+func TestOutputFormatUsesPNGForTransparentImagesInOtherFormats(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	assert.Equal(t, FormatPNG, OutputFormat("", "webp", img))
+
+	img.Set(0, 0, color.White)
+	img.Set(0, 1, color.White)
+	img.Set(1, 0, color.White)
+	img.Set(1, 1, color.White)
+	assert.Equal(t, FormatJPEG, OutputFormat("", "webp", img))
+}
+
+func TestValidateOptions(t *testing.T) {
+	assert.Nil(t, ValidateOptions(nil))
+	assert.Nil(t, ValidateOptions(map[string]string{OptionFormat: "gif", OptionQuality: "90"}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionFormat: "webp"}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionQuality: "0"}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionQuality: "abc"}))
+	assert.Nil(t, ValidateOptions(map[string]string{OptionSelection: SelectFixed, OptionCodepoint: "1f600"}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionSelection: SelectFixed}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionTag: ""}))
+	assert.Error(t, ValidateOptions(map[string]string{OptionFormat: "png", "colour": "red"}))
+}
+
+func TestEncodeWritesFormat(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	for _, f := range []string{FormatPNG, FormatJPEG, FormatGIF} {
+		buf := new(bytes.Buffer)
+		err := Encode(buf, img, f, 90)
+
+		assert.Nil(t, err)
+		assert.Equal(t, f, Format(bytes.NewReader(buf.Bytes())))
+	}
+}
@@ -0,0 +1,252 @@
+package emojify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest in a pack directory
+const ManifestFile = "manifest.json"
+
+// ErrNoEmoji is returned when a pack does not contain any usable images
+var ErrNoEmoji = errors.New("pack does not contain any usable emoji images")
+
+// Emoji is an emoji image and the details from the pack manifest
+type Emoji struct {
+	Codepoint string
+	ShortName string
+	Tags      []string
+	Licence   string
+	Image     image.Image
+
+	// variants are pre-scaled copies of the image, largest first
+	variants []image.Image
+}
+
+// Manifest describes the emoji in a pack, images in the pack which are not
+// listed use their file name as the codepoint
+type Manifest struct {
+	// Licence of the images in the pack, emoji can override it
+	Licence string          `json:"licence"`
+	Emoji   []ManifestEmoji `json:"emoji"`
+}
+
+// ManifestEmoji describes a single emoji in a pack
+type ManifestEmoji struct {
+	// File is the path to the image relative to the pack directory
+	File      string   `json:"file"`
+	Codepoint string   `json:"codepoint"`
+	ShortName string   `json:"shortName"`
+	Tags      []string `json:"tags"`
+	Licence   string   `json:"licence"`
+}
+
+// Pack is a set of emoji indexed by codepoint and tag
+type Pack struct {
+	licence     string
+	emojis      []*Emoji
+	byCodepoint map[string]*Emoji
+	byTag       map[string][]*Emoji
+}
+
+// NewPack creates a pack containing the emoji, pre-scaled variants of
+// each emoji are made so faces can be drawn quickly. Repeated tags are
+// removed so an emoji is only listed once for each tag.
+func NewPack(emojis []*Emoji) *Pack {
+	p := &Pack{
+		emojis:      append([]*Emoji{}, emojis...),
+		byCodepoint: map[string]*Emoji{},
+		byTag:       map[string][]*Emoji{},
+	}
+
+	// selection strategies depend on the emoji being in codepoint order
+	sort.Slice(p.emojis, func(i, j int) bool { return p.emojis[i].Codepoint < p.emojis[j].Codepoint })
+
+	for _, e := range p.emojis {
+		if e.variants == nil {
+			e.variants = variants(e.Image)
+		}
+
+		p.byCodepoint[e.Codepoint] = e
+
+		e.Tags = uniqueTags(e.Tags)
+		for _, t := range e.Tags {
+			p.byTag[t] = append(p.byTag[t], e)
+		}
+	}
+
+	return p
+}
+
+// LoadPack loads the images in the directory and its subdirectories along
+// with the manifest, ErrNoEmoji is returned when there are no usable images
+// and an error is returned when two images have the same codepoint
+func LoadPack(dir string) (*Pack, error) {
+	m := &Manifest{}
+
+	d, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err == nil {
+		err = json.Unmarshal(d, m)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse manifest for pack %s: %s", dir, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries := map[string]ManifestEmoji{}
+	for _, e := range m.Emoji {
+		entries[filepath.Clean(e.File)] = e
+	}
+
+	emojis := []*Emoji{}
+	files := map[string]string{}
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if f.IsDir() || !isImageFile(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		i, err := decodeFile(path)
+		if err != nil {
+			// files which are not valid images are not usable, the pack
+			// fails to load if none of the images can be used
+			return nil
+		}
+
+		e := &Emoji{
+			Codepoint: strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())),
+			Licence:   m.Licence,
+			Image:     i,
+		}
+
+		if me, ok := entries[rel]; ok {
+			delete(entries, rel)
+
+			e.ShortName = me.ShortName
+			e.Tags = me.Tags
+			if me.Codepoint != "" {
+				e.Codepoint = me.Codepoint
+			}
+			if me.Licence != "" {
+				e.Licence = me.Licence
+			}
+		}
+
+		if other, ok := files[e.Codepoint]; ok {
+			return fmt.Errorf("pack %s has more than one emoji with codepoint %s: %s and %s", dir, e.Codepoint, other, rel)
+		}
+		files[e.Codepoint] = rel
+
+		emojis = append(emojis, e)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) > 0 {
+		missing := []string{}
+		for f := range entries {
+			missing = append(missing, f)
+		}
+		sort.Strings(missing)
+
+		return nil, fmt.Errorf("manifest for pack %s lists files which are not usable images: %s", dir, strings.Join(missing, ", "))
+	}
+
+	if len(emojis) == 0 {
+		return nil, ErrNoEmoji
+	}
+
+	p := NewPack(emojis)
+	p.licence = m.Licence
+
+	return p, nil
+}
+
+// Emojis returns all of the emoji in codepoint order
+func (p *Pack) Emojis() []*Emoji {
+	return p.emojis
+}
+
+// Emoji returns the emoji with the codepoint
+func (p *Pack) Emoji(codepoint string) (*Emoji, bool) {
+	e, ok := p.byCodepoint[codepoint]
+	return e, ok
+}
+
+// Licence returns the licence of the pack from the manifest
+func (p *Pack) Licence() string {
+	return p.licence
+}
+
+// Tags returns the tags used by emoji in the pack in order
+func (p *Pack) Tags() []string {
+	tags := []string{}
+	for t := range p.byTag {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// Tagged returns the emoji with the tag in codepoint order
+func (p *Pack) Tagged(tag string) []*Emoji {
+	return p.byTag[tag]
+}
+
+// uniqueTags returns the tags without repeats, tags keep their order
+func uniqueTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func isImageFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".gif", ".jpg", ".jpeg":
+		return true
+	}
+
+	return false
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	i, _, err := image.Decode(f)
+	return i, err
+}
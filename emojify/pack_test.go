@@ -0,0 +1,148 @@
+package emojify
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupPack copies the test emoji into a pack with a subdirectory
+func setupPack(t *testing.T, manifest string) string {
+	dir, err := ioutil.TempDir("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "smileys", "hearts"), 0755)
+	copyFile(t, "testdata/emoji/1f600.png", filepath.Join(dir, "smileys", "1f600.png"))
+	copyFile(t, "testdata/emoji/1f60d.png", filepath.Join(dir, "smileys", "hearts", "heart-eyes.png"))
+	copyFile(t, "testdata/emoji/1f622.png", filepath.Join(dir, "1f622.png"))
+	ioutil.WriteFile(filepath.Join(dir, "README.txt"), []byte("not an emoji"), 0644)
+
+	if manifest != "" {
+		ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte(manifest), 0644)
+	}
+
+	return dir
+}
+
+func copyFile(t *testing.T, from, to string) {
+	d, err := ioutil.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(to, d, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPackRecursesDirectories(t *testing.T) {
+	dir := setupPack(t, "")
+	defer os.RemoveAll(dir)
+
+	p, err := LoadPack(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"1f600", "1f622", "heart-eyes"}, codepoints(p.Emojis()))
+}
+
+func TestLoadPackReadsManifest(t *testing.T) {
+	dir := setupPack(t, `{
+		"licence": "CC-BY-4.0",
+		"emoji": [
+			{"file": "smileys/1f600.png", "codepoint": "1f600", "shortName": "grinning_face", "tags": ["happy"]},
+			{"file": "smileys/hearts/heart-eyes.png", "codepoint": "1f60d", "shortName": "smiling_face_with_heart_eyes", "tags": ["happy", "love"], "licence": "MIT"}
+		]
+	}`)
+	defer os.RemoveAll(dir)
+
+	p, err := LoadPack(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := p.Emoji("1f60d")
+	assert.True(t, ok)
+	assert.Equal(t, "smiling_face_with_heart_eyes", e.ShortName)
+	assert.Equal(t, "MIT", e.Licence)
+
+	e, _ = p.Emoji("1f622")
+	assert.Equal(t, "CC-BY-4.0", e.Licence)
+
+	assert.Equal(t, []string{"1f600", "1f60d"}, codepoints(p.Tagged("happy")))
+	assert.Empty(t, p.Tagged("sad"))
+}
+
+func TestNewPackListsEmojiOnceForEachTag(t *testing.T) {
+	p := NewPack([]*Emoji{
+		{Codepoint: "1f610", Tags: []string{"neutral", "neutral"}, Image: image.NewRGBA(image.Rect(0, 0, 8, 8))},
+		{Codepoint: "1f620", Tags: []string{"angry", "neutral"}, Image: image.NewRGBA(image.Rect(0, 0, 8, 8))},
+	})
+
+	assert.Equal(t, []string{"1f610", "1f620"}, codepoints(p.Tagged("neutral")))
+
+	e, _ := p.Emoji("1f610")
+	assert.Equal(t, []string{"neutral"}, e.Tags)
+}
+
+func TestLoadPackFailsWhenManifestListsMissingFile(t *testing.T) {
+	dir := setupPack(t, `{"emoji": [{"file": "1f4a9.png", "codepoint": "1f4a9"}]}`)
+	defer os.RemoveAll(dir)
+
+	_, err := LoadPack(dir)
+
+	assert.Error(t, err)
+}
+
+func TestLoadPackFailsWhenCodepointIsRepeated(t *testing.T) {
+	dir := setupPack(t, `{"emoji": [{"file": "smileys/hearts/heart-eyes.png", "codepoint": "1f600"}]}`)
+	defer os.RemoveAll(dir)
+
+	_, err := LoadPack(dir)
+
+	assert.Error(t, err)
+}
+
+func TestLoadPackWithoutImagesReturnsError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pack")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "broken.png"), []byte("not a png"), 0644)
+
+	_, err := LoadPack(dir)
+
+	assert.Equal(t, ErrNoEmoji, err)
+}
+
+func TestLoadPackLoadsBundledImages(t *testing.T) {
+	p, err := LoadPack("../images")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := p.Emoji("1f600")
+	assert.True(t, ok)
+	assert.Equal(t, "grinning_face", e.ShortName)
+	assert.Contains(t, codepoints(p.Tagged(ExpressionSad)), "1f61e")
+}
+
+func TestTagOptionLimitsSelection(t *testing.T) {
+	dir := setupPack(t, `{"emoji": [{"file": "1f622.png", "tags": ["sad"]}]}`)
+	defer os.RemoveAll(dir)
+
+	p, _ := LoadPack(dir)
+	e := &Impl{packs: StaticPacks(map[string]*Pack{DefaultPack: p}), selectors: map[string]Selector{}}
+
+	s, err := e.selectEmoji(make([]image.Image, 2), Options{Tag: "sad"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1f622", "1f622"}, codepoints(s))
+
+	_, err = e.selectEmoji(make([]image.Image, 2), Options{Tag: "love"})
+	assert.Equal(t, ReasonUnknownEmoji, FailureReason(err))
+}
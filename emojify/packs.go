@@ -0,0 +1,210 @@
+package emojify
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// DefaultPack is the name of the pack used when a request or tenant does
+// not choose one
+const DefaultPack = "default"
+
+// ReasonUnknownPack is reported when a request selects a pack which is not
+// loaded
+const ReasonUnknownPack = "UNKNOWN_PACK"
+
+// PackInfo describes a loaded pack
+type PackInfo struct {
+	Name       string
+	EmojiCount int
+	Licence    string
+	Tags       []string
+}
+
+// Packs is a set of named emoji packs, each subdirectory of the packs
+// directory is a pack named after the directory. Packs are replaced
+// atomically when the directory is reloaded so requests always see a
+// complete set.
+type Packs struct {
+	dir    string
+	logger hclog.Logger
+	// required is the pack which must be in every set of loaded packs
+	required string
+
+	// packs holds a map[string]*Pack
+	packs atomic.Value
+
+	mu          sync.Mutex
+	fingerprint string
+}
+
+// NewPacks loads the packs in dir, an error is returned if any pack can not
+// be loaded or the directory does not contain the required pack. The
+// required pack is usually the default and reloads which remove it fail.
+func NewPacks(dir, required string, l hclog.Logger) (*Packs, error) {
+	p := &Packs{dir: dir, required: required, logger: l}
+
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// StaticPacks returns a set of packs which is not loaded from a directory
+func StaticPacks(packs map[string]*Pack) *Packs {
+	p := &Packs{logger: hclog.NewNullLogger()}
+	p.packs.Store(packs)
+
+	return p
+}
+
+// Reload loads the packs if files in the packs directory have changed
+// since they were last loaded, returns true when the packs were reloaded.
+// The current packs are kept when any pack fails to load or the required
+// pack has been removed.
+func (p *Packs) Reload() (bool, error) {
+	if p.dir == "" {
+		return false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fp, err := fingerprint(p.dir)
+	if err != nil {
+		return false, err
+	}
+
+	if fp == p.fingerprint {
+		return false, nil
+	}
+
+	dirs, err := ioutil.ReadDir(p.dir)
+	if err != nil {
+		return false, err
+	}
+
+	packs := map[string]*Pack{}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		pack, err := LoadPack(filepath.Join(p.dir, d.Name()))
+		if err != nil {
+			return false, fmt.Errorf("unable to load pack %s: %s", d.Name(), err)
+		}
+
+		packs[d.Name()] = pack
+	}
+
+	if len(packs) == 0 {
+		return false, fmt.Errorf("no packs found in %s", p.dir)
+	}
+
+	if _, ok := packs[p.required]; p.required != "" && !ok {
+		return false, fmt.Errorf("pack %s not found in %s", p.required, p.dir)
+	}
+
+	p.packs.Store(packs)
+	p.fingerprint = fp
+
+	return true, nil
+}
+
+// Watch polls the packs directory every interval and reloads the packs
+// when files change, it blocks until stop is closed
+func (p *Packs) Watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ok, err := p.Reload()
+			if err != nil {
+				// keep using the previous packs until the directory is valid
+				p.logger.Error("Unable to reload emoji packs", "error", err)
+				continue
+			}
+
+			if ok {
+				p.logger.Info("Reloaded emoji packs", "dir", p.dir, "packs", p.Names())
+			}
+		}
+	}
+}
+
+// Get returns the named pack
+func (p *Packs) Get(name string) (*Pack, bool) {
+	pack, ok := p.load()[name]
+	return pack, ok
+}
+
+// Names returns the names of the loaded packs in order
+func (p *Packs) Names() []string {
+	names := []string{}
+	for n := range p.load() {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// List returns the details of the loaded packs ordered by name
+func (p *Packs) List() []PackInfo {
+	packs := p.load()
+
+	info := []PackInfo{}
+	for _, n := range p.Names() {
+		pack, ok := packs[n]
+		if !ok {
+			// reloaded since the names were read
+			continue
+		}
+
+		info = append(info, PackInfo{
+			Name:       n,
+			EmojiCount: len(pack.Emojis()),
+			Licence:    pack.Licence(),
+			Tags:       pack.Tags(),
+		})
+	}
+
+	return info
+}
+
+func (p *Packs) load() map[string]*Pack {
+	packs, _ := p.packs.Load().(map[string]*Pack)
+	return packs
+}
+
+// fingerprint returns a hash of the names, sizes and modification times of
+// the files in dir
+func fingerprint(dir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s %d %d\n", path, f.Size(), f.ModTime().UnixNano())
+		return nil
+	})
+
+	return fmt.Sprintf("%x", h.Sum(nil)), err
+}
@@ -0,0 +1,184 @@
+package emojify
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupPacks creates a packs directory with a default pack containing all
+// the test emoji and a winter pack containing one
+func setupPacks(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, DefaultPack), 0755)
+	os.MkdirAll(filepath.Join(dir, "winter"), 0755)
+	for _, cp := range []string{"1f600", "1f60d", "1f622"} {
+		copyFile(t, "testdata/emoji/"+cp+".png", filepath.Join(dir, DefaultPack, cp+".png"))
+	}
+	copyFile(t, "testdata/emoji/1f622.png", filepath.Join(dir, "winter", "1f622.png"))
+	ioutil.WriteFile(filepath.Join(dir, "winter", ManifestFile), []byte(`{"licence": "CC0-1.0"}`), 0644)
+
+	return dir
+}
+
+// touch moves the modification time of a file forward so the packs
+// fingerprint changes regardless of the file system time resolution
+func touch(t *testing.T, file string) {
+	m := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, m, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPacksLoadsEachDirectoryAsAPack(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, err := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{DefaultPack, "winter"}, p.Names())
+	assert.Equal(t, []PackInfo{
+		{Name: DefaultPack, EmojiCount: 3, Tags: []string{}},
+		{Name: "winter", EmojiCount: 1, Licence: "CC0-1.0", Tags: []string{}},
+	}, p.List())
+}
+
+func TestNewPacksReturnsErrorWhenNoPacks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packs")
+	defer os.RemoveAll(dir)
+
+	_, err := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+
+	assert.Error(t, err)
+}
+
+func TestNewPacksReturnsErrorWhenRequiredPackIsMissing(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewPacks(dir, "summer", hclog.NewNullLogger())
+
+	assert.Error(t, err)
+}
+
+func TestReloadLoadsChangedPacks(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, _ := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+
+	ok, err := p.Reload()
+	assert.Nil(t, err)
+	assert.False(t, ok, "unchanged packs should not be reloaded")
+
+	os.MkdirAll(filepath.Join(dir, "spring"), 0755)
+	copyFile(t, "testdata/emoji/1f600.png", filepath.Join(dir, "spring", "1f600.png"))
+
+	ok, err = p.Reload()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{DefaultPack, "spring", "winter"}, p.Names())
+}
+
+func TestReloadKeepsCurrentPacksWhenAPackIsBroken(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, _ := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+
+	manifest := filepath.Join(dir, "winter", ManifestFile)
+	ioutil.WriteFile(manifest, []byte(`{"emoji": [{"file": "missing.png", "codepoint": "2744"}]}`), 0644)
+	touch(t, manifest)
+
+	ok, err := p.Reload()
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	w, found := p.Get("winter")
+	assert.True(t, found)
+	assert.Equal(t, "CC0-1.0", w.Licence())
+}
+
+func TestReloadKeepsCurrentPacksWhenRequiredPackIsRemoved(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, _ := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+
+	os.RemoveAll(filepath.Join(dir, DefaultPack))
+
+	ok, err := p.Reload()
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	_, found := p.Get(DefaultPack)
+	assert.True(t, found)
+}
+
+func TestWatchReloadsPacks(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, _ := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(10*time.Millisecond, stop)
+
+	os.RemoveAll(filepath.Join(dir, "winter"))
+
+	for i := 0; i < 100; i++ {
+		if _, ok := p.Get("winter"); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected the winter pack to be removed")
+}
+
+func TestSelectionUsesRequestThenTenantThenDefaultPack(t *testing.T) {
+	dir := setupPacks(t)
+	defer os.RemoveAll(dir)
+
+	p, _ := NewPacks(dir, DefaultPack, hclog.NewNullLogger())
+	e := setupSelection(t)
+	e.SetPacks(p, DefaultPack)
+	e.SetTenantPacks(map[string]string{"acme": "winter"})
+	e.SetSelection(SelectRoundRobin)
+
+	faces := make([]image.Image, 2)
+
+	s, _ := e.selectEmoji(faces, Options{})
+	assert.Equal(t, []string{"1f600", "1f60d"}, codepoints(s))
+
+	s, _ = e.selectEmoji(faces, Options{Tenant: "acme"})
+	assert.Equal(t, []string{"1f622", "1f622"}, codepoints(s))
+
+	s, _ = e.selectEmoji(faces, Options{Tenant: "acme", Pack: DefaultPack})
+	assert.Equal(t, []string{"1f600", "1f60d"}, codepoints(s))
+
+	s, _ = e.selectEmoji(faces, Options{Tenant: "other"})
+	assert.Equal(t, []string{"1f600", "1f60d"}, codepoints(s))
+}
+
+func TestSelectionWithUnknownPackReturnsPermanentError(t *testing.T) {
+	e := setupSelection(t)
+
+	_, err := e.selectEmoji(make([]image.Image, 1), Options{Pack: "summer"})
+
+	assert.Equal(t, ReasonUnknownPack, FailureReason(err))
+}
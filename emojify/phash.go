@@ -0,0 +1,62 @@
+package emojify
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+
+	"github.com/nfnt/resize"
+)
+
+// PerceptualHash returns a 64 bit difference hash of the image, images
+// which look the same have hashes with a small Hamming distance even when
+// they have been resized or re-encoded
+func PerceptualHash(img image.Image) uint64 {
+	// reduce the image to 9x8 so that each row has 8 horizontal gradients
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luminance(small.At(x, y)) < luminance(small.At(x+1, y)) {
+				h |= 1 << uint(y*8+x)
+			}
+		}
+	}
+
+	return h
+}
+
+// HammingDistance returns the number of bits which differ between two
+// perceptual hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ScaleFaces maps face rectangles found in an image with bounds from to
+// an image with bounds to
+func ScaleFaces(faces []Face, from, to image.Rectangle) []Face {
+	if from == to {
+		return faces
+	}
+
+	sx := float64(to.Dx()) / float64(from.Dx())
+	sy := float64(to.Dy()) / float64(from.Dy())
+
+	scaled := make([]Face, len(faces))
+	for i, f := range faces {
+		scaled[i] = f
+		scaled[i].Rectangle = image.Rect(
+			to.Min.X+int(float64(f.Min.X-from.Min.X)*sx),
+			to.Min.Y+int(float64(f.Min.Y-from.Min.Y)*sy),
+			to.Min.X+int(float64(f.Max.X-from.Min.X)*sx),
+			to.Min.Y+int(float64(f.Max.Y-from.Min.Y)*sy),
+		)
+	}
+
+	return scaled
+}
+
+func luminance(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
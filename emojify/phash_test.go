@@ -0,0 +1,48 @@
+package emojify
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+)
+
+func gradientImage(w, h int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*x + y*3) % 256)
+			if invert {
+				v = 255 - v
+			}
+
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	return img
+}
+
+func TestPerceptualHashIsCloseForResizedImage(t *testing.T) {
+	img := gradientImage(200, 160, false)
+	small := resize.Resize(100, 80, img, resize.Lanczos3)
+
+	assert.True(t, HammingDistance(PerceptualHash(img), PerceptualHash(small)) <= 4)
+}
+
+func TestPerceptualHashIsDifferentForDifferentImage(t *testing.T) {
+	a := PerceptualHash(gradientImage(200, 160, false))
+	b := PerceptualHash(gradientImage(200, 160, true))
+
+	assert.True(t, HammingDistance(a, b) > 16)
+}
+
+func TestScaleFacesMapsToNewBounds(t *testing.T) {
+	faces := []Face{{Rectangle: image.Rect(10, 20, 30, 40), Roll: 15}}
+
+	scaled := ScaleFaces(faces, image.Rect(0, 0, 100, 100), image.Rect(0, 0, 200, 50))
+
+	assert.Equal(t, Face{Rectangle: image.Rect(20, 10, 60, 20), Roll: 15}, scaled[0])
+}
@@ -0,0 +1,193 @@
+package emojify
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedNetworks are not reachable unless private addresses are allowed,
+// they cover loopback, private, link local, carrier grade NAT, multicast
+// and unspecified addresses
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// URLPolicy defines which URLs the Fetcher is allowed to download
+type URLPolicy struct {
+	// Schemes which can be fetched, e.g. http and https
+	Schemes []string
+	// AllowDomains when not empty restricts fetching to these domains
+	// and their sub domains
+	AllowDomains []string
+	// DenyDomains and their sub domains can not be fetched
+	DenyDomains []string
+	// AllowPrivate allows connections to loopback, private and link
+	// local addresses
+	AllowPrivate bool
+	// MaxRedirects is the maximum number of redirects followed
+	MaxRedirects int
+	// AllowAddresses are ip:port addresses which can be connected to even
+	// when they are private, e.g. a local service used for testing
+	AllowAddresses []string
+}
+
+// DefaultURLPolicy returns a policy which allows http and https URLs on
+// public addresses
+func DefaultURLPolicy() URLPolicy {
+	return URLPolicy{
+		Schemes:      []string{"http", "https"},
+		MaxRedirects: 5,
+	}
+}
+
+// CheckURL returns a PermanentError if the URL is not allowed by the policy,
+// addresses are checked when connecting as the host may resolve to
+// a different address at that time
+func (p URLPolicy) CheckURL(u *url.URL) error {
+	if err := p.CheckScheme(u.Scheme); err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return blocked(ReasonURLBlocked, "url has no host")
+	}
+
+	if matchDomain(p.DenyDomains, host) {
+		return blocked(ReasonURLBlocked, "host %s is not allowed", host)
+	}
+
+	if len(p.AllowDomains) > 0 && !matchDomain(p.AllowDomains, host) {
+		return blocked(ReasonURLBlocked, "host %s is not allowed", host)
+	}
+
+	return nil
+}
+
+// CheckScheme returns a PermanentError if the scheme is not allowed
+func (p URLPolicy) CheckScheme(scheme string) error {
+	if !contains(p.Schemes, strings.ToLower(scheme)) {
+		return blocked(ReasonURLBlocked, "scheme %q is not allowed", scheme)
+	}
+
+	return nil
+}
+
+// CheckIP returns a PermanentError if connections to the address are not
+// allowed by the policy
+func (p URLPolicy) CheckIP(ip net.IP) error {
+	if p.AllowPrivate {
+		return nil
+	}
+
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return blocked(ReasonAddressNotAllowed, "address %s is not allowed", ip)
+		}
+	}
+
+	return nil
+}
+
+// CheckAddress returns a PermanentError if connections to the resolved
+// address are not allowed, it is used as the Control function of a dialer
+func (p URLPolicy) CheckAddress(network, address string, _ syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", address)
+	}
+
+	if p.allowedAddress(ip, port) {
+		return nil
+	}
+
+	return p.CheckIP(ip)
+}
+
+// Dialer returns a dialer which only connects to addresses allowed by the
+// policy, addresses are checked after DNS resolution so every connection
+// is checked including redirects and hosts which resolve to a different
+// address later
+func (p URLPolicy) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.CheckAddress,
+	}
+}
+
+// allowedAddress returns true when the address is one of AllowAddresses
+func (p URLPolicy) allowedAddress(ip net.IP, port string) bool {
+	for _, a := range p.AllowAddresses {
+		h, pt, err := net.SplitHostPort(a)
+		if err == nil && pt == port && ip.Equal(net.ParseIP(h)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func blocked(reason, format string, args ...interface{}) error {
+	return &PermanentError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// matchDomain returns true if host is one of the domains or a sub domain
+func matchDomain(domains []string, host string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(d), ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if strings.ToLower(v) == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	n := []*net.IPNet{}
+	for _, c := range cidrs {
+		_, ipn, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+
+		n = append(n, ipn)
+	}
+
+	return n
+}
@@ -0,0 +1,321 @@
+package emojify
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Processing options which control how faces are redacted
+const (
+	// OptionMode is the name of the mode used to replace faces
+	OptionMode = "mode"
+	// OptionBlockSize is the size in pixels of the blocks used by the
+	// pixelate mode, by default faces are eight blocks across
+	OptionBlockSize = "block"
+	// OptionExpand grows the area redacted around each face, 1.5 redacts
+	// an area 50% larger than the detected face
+	OptionExpand = "expand"
+	// OptionFill is the colour used by the fill mode as hex, e.g. 000000
+	OptionFill = "fill"
+)
+
+// Modes which can be used to replace faces
+const (
+	// ModeEmoji draws an emoji over each face
+	ModeEmoji = "emoji"
+	// ModeBlur applies a Gaussian blur to each face
+	ModeBlur = "blur"
+	// ModePixelate replaces each face with blocks of its average colour
+	ModePixelate = "pixelate"
+	// ModeFill replaces each face with a solid colour
+	ModeFill = "fill"
+)
+
+// ReasonUnknownMode is reported when a request selects a mode which does
+// not exist
+const ReasonUnknownMode = "UNKNOWN_MODE"
+
+// ValidMode returns true when mode is one of the supported face modes
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeEmoji, ModeBlur, ModePixelate, ModeFill:
+		return true
+	}
+
+	return false
+}
+
+// redactBlocks is the number of blocks or blur deviations across a face
+// when a request does not set the block size
+const redactBlocks = 8
+
+// redact replaces every pixel in the area covered by each face using the
+// mode, faces are redacted as upright rectangles which include the whole
+// of the detected face so none of the original pixels remain
+func redact(src image.Image, faces []Face, mode string, o Options) (image.Image, error) {
+	switch mode {
+	case ModeBlur, ModePixelate, ModeFill:
+	default:
+		return nil, &PermanentError{Reason: ReasonUnknownMode, Err: fmt.Errorf("mode %q does not exist", mode)}
+	}
+
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	for _, f := range faces {
+		r := redactArea(f.Rectangle, o.Expand, dst.Bounds())
+		if r.Empty() {
+			continue
+		}
+
+		size := r.Dx()
+		if r.Dy() > size {
+			size = r.Dy()
+		}
+
+		switch mode {
+		case ModeBlur:
+			blur(dst, r, math.Max(1, float64(size)/redactBlocks))
+		case ModePixelate:
+			block := o.BlockSize
+			if block <= 0 {
+				block = size / redactBlocks
+			}
+			pixelate(dst, r, block)
+		case ModeFill:
+			fill := o.Fill
+			if fill == nil {
+				fill = color.Black
+			}
+			draw.Draw(dst, r, image.NewUniform(fill), image.ZP, draw.Src)
+		}
+	}
+
+	return dst, nil
+}
+
+// redactArea returns the face grown by the expand factor around its centre
+// and clipped to the image, the area is rounded outwards so it always
+// contains the face
+func redactArea(face image.Rectangle, expand float64, bounds image.Rectangle) image.Rectangle {
+	if expand < 1 {
+		expand = 1
+	}
+
+	dx := float64(face.Dx()) * (expand - 1) / 2
+	dy := float64(face.Dy()) * (expand - 1) / 2
+
+	r := image.Rect(
+		int(math.Floor(float64(face.Min.X)-dx)),
+		int(math.Floor(float64(face.Min.Y)-dy)),
+		int(math.Ceil(float64(face.Max.X)+dx)),
+		int(math.Ceil(float64(face.Max.Y)+dy)),
+	)
+
+	return r.Intersect(bounds)
+}
+
+// blurPasses is the number of box blurs used to approximate a Gaussian
+const blurPasses = 3
+
+// blur approximates a Gaussian blur with the standard deviation sigma over
+// the area r of img using repeated box blurs, pixels at the edge of r are
+// repeated so pixels outside of r are neither read nor changed. The cost
+// of a box blur does not depend on its radius so large faces are blurred
+// in time proportional to their area.
+func blur(img *image.RGBA, r image.Rectangle, sigma float64) {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	line := make([]uint8, 4*max(r.Dx(), r.Dy()))
+
+	for _, radius := range boxRadii(sigma, blurPasses) {
+		if radius == 0 {
+			continue
+		}
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			boxBlur(img.Pix, img.PixOffset(r.Min.X, y), 4, r.Dx(), radius, line)
+		}
+
+		for x := r.Min.X; x < r.Max.X; x++ {
+			boxBlur(img.Pix, img.PixOffset(x, r.Min.Y), img.Stride, r.Dy(), radius, line)
+		}
+	}
+}
+
+// boxRadii returns the radius of each of n box blurs which together
+// approximate a Gaussian blur with the standard deviation sigma
+func boxRadii(sigma float64, n int) []int {
+	ideal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+
+	wl := int(math.Floor(ideal))
+	if wl%2 == 0 {
+		wl--
+	}
+	wu := wl + 2
+
+	// the number of passes which use the smaller width
+	m := int(math.Round((12*sigma*sigma - float64(n*wl*wl+4*n*wl+3*n)) / float64(-4*wl-4)))
+
+	radii := make([]int, n)
+	for i := range radii {
+		if i < m {
+			radii[i] = (wl - 1) / 2
+		} else {
+			radii[i] = (wu - 1) / 2
+		}
+	}
+
+	return radii
+}
+
+// boxBlur blurs the n pixels of pix which start at offset o and are step
+// bytes apart with a box of the given radius, line is used to hold a copy
+// of the pixels
+func boxBlur(pix []uint8, o, step, n, radius int, line []uint8) {
+	for i := 0; i < n; i++ {
+		copy(line[i*4:i*4+4], pix[o+i*step:o+i*step+4])
+	}
+
+	w := 2*radius + 1
+
+	var sum [4]int
+	for k := -radius; k <= radius; k++ {
+		j := clamp(k, 0, n-1) * 4
+		for c := 0; c < 4; c++ {
+			sum[c] += int(line[j+c])
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		p := o + i*step
+		for c := 0; c < 4; c++ {
+			pix[p+c] = uint8((sum[c] + w/2) / w)
+		}
+
+		// slide the window one pixel along the line
+		in := clamp(i+radius+1, 0, n-1) * 4
+		out := clamp(i-radius, 0, n-1) * 4
+		for c := 0; c < 4; c++ {
+			sum[c] += int(line[in+c]) - int(line[out+c])
+		}
+	}
+}
+
+// pixelate replaces the area r of img with blocks of their average colour,
+// r is divided evenly so no block is smaller than the block size
+func pixelate(img *image.RGBA, r image.Rectangle, block int) {
+	if block < 2 {
+		block = 2
+	}
+
+	nx := r.Dx() / block
+	if nx < 1 {
+		nx = 1
+	}
+
+	ny := r.Dy() / block
+	if ny < 1 {
+		ny = 1
+	}
+
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			b := image.Rect(
+				r.Min.X+i*r.Dx()/nx,
+				r.Min.Y+j*r.Dy()/ny,
+				r.Min.X+(i+1)*r.Dx()/nx,
+				r.Min.Y+(j+1)*r.Dy()/ny,
+			)
+
+			draw.Draw(img, b, image.NewUniform(average(img, b)), image.ZP, draw.Src)
+		}
+	}
+}
+
+// average returns the average colour of the area r of img
+func average(img *image.RGBA, r image.Rectangle) color.RGBA {
+	var sum [4]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			o := img.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				sum[c] += int(img.Pix[o+c])
+			}
+		}
+	}
+
+	n := r.Dx() * r.Dy()
+	return color.RGBA{
+		uint8((sum[0] + n/2) / n),
+		uint8((sum[1] + n/2) / n),
+		uint8((sum[2] + n/2) / n),
+		uint8((sum[3] + n/2) / n),
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+
+	if v > max {
+		return max
+	}
+
+	return v
+}
+
+// validateRedaction checks the redaction options
+func validateRedaction(options map[string]string) error {
+	if m := options[OptionMode]; m != "" && !ValidMode(m) {
+		return fmt.Errorf("unsupported mode %q, must be emoji, blur, pixelate or fill", m)
+	}
+
+	if b, ok := options[OptionBlockSize]; ok {
+		if i, err := strconv.Atoi(b); err != nil || i < 2 {
+			return fmt.Errorf("invalid block size %q, must be at least 2", b)
+		}
+	}
+
+	if e, ok := options[OptionExpand]; ok {
+		if f, err := strconv.ParseFloat(e, 64); err != nil || f < 1 || f > 4 {
+			return fmt.Errorf("invalid expand %q, must be between 1 and 4", e)
+		}
+	}
+
+	if f, ok := options[OptionFill]; ok {
+		if _, err := parseColour(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseColour parses a hex colour, e.g. ff0000 or #ff0000
+func parseColour(s string) (color.Color, error) {
+	d, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(d) != 3 {
+		return nil, fmt.Errorf("invalid colour %q, must be hex, e.g. 000000", s)
+	}
+
+	return color.RGBA{d[0], d[1], d[2], 0xff}, nil
+}
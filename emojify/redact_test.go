@@ -0,0 +1,206 @@
+package emojify
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkerboard returns an image of alternating black and white pixels
+func checkerboard(r image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	return img
+}
+
+// assertOutsideUnchanged checks pixels outside of r are the same as src
+func assertOutsideUnchanged(t *testing.T, src, dst image.Image, r image.Rectangle) {
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(r) && src.At(x, y) != dst.At(x, y) {
+				t.Fatalf("pixel %d,%d outside of the redacted area changed", x, y)
+			}
+		}
+	}
+}
+
+var redactFace = Face{Rectangle: image.Rect(20, 20, 40, 40), Roll: 30}
+
+func TestRedactAreaExpandsAndClipsToImage(t *testing.T) {
+	b := image.Rect(0, 0, 100, 100)
+
+	assert.Equal(t, image.Rect(20, 20, 40, 40), redactArea(image.Rect(20, 20, 40, 40), 0, b))
+	assert.Equal(t, image.Rect(15, 15, 45, 45), redactArea(image.Rect(20, 20, 40, 40), 1.5, b))
+	assert.Equal(t, image.Rect(14, 14, 46, 46), redactArea(image.Rect(20, 20, 40, 40), 1.55, b))
+	assert.Equal(t, image.Rect(0, 87, 14, 100), redactArea(image.Rect(-5, 90, 10, 100), 1.5, b))
+}
+
+func TestFillReplacesExpandedFace(t *testing.T) {
+	src := checkerboard(image.Rect(0, 0, 60, 60))
+
+	dst, err := redact(src, []Face{redactFace}, ModeFill, Options{Expand: 1.5, Fill: color.RGBA{255, 0, 0, 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := image.Rect(15, 15, 45, 45)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			assert.Equal(t, color.RGBA{255, 0, 0, 255}, dst.At(x, y))
+		}
+	}
+	assertOutsideUnchanged(t, src, dst, r)
+}
+
+func TestFillDefaultsToBlack(t *testing.T) {
+	dst, _ := redact(checkerboard(image.Rect(0, 0, 60, 60)), []Face{redactFace}, ModeFill, Options{})
+
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(25, 26))
+}
+
+func TestPixelateReplacesFaceWithBlocks(t *testing.T) {
+	src := checkerboard(image.Rect(0, 0, 60, 60))
+
+	// 20 pixels divided into blocks of at least 6 gives three blocks of 6,
+	// 7 and 7 pixels
+	dst, err := redact(src, []Face{redactFace}, ModePixelate, Options{BlockSize: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []image.Rectangle{image.Rect(20, 20, 26, 26), image.Rect(26, 26, 33, 33), image.Rect(33, 33, 40, 40)}
+	for _, b := range blocks {
+		c := dst.At(b.Min.X, b.Min.Y)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				assert.Equal(t, c, dst.At(x, y))
+			}
+		}
+
+		r, _, _, _ := c.RGBA()
+		assert.InDelta(t, 0x7fff, r, 0x0400, "blocks should be grey")
+	}
+	assertOutsideUnchanged(t, src, dst, redactFace.Rectangle)
+}
+
+func TestPixelateNeverUsesSinglePixelBlocks(t *testing.T) {
+	src := checkerboard(image.Rect(0, 0, 60, 60))
+
+	dst, _ := redact(src, []Face{{Rectangle: image.Rect(20, 20, 27, 27)}}, ModePixelate, Options{})
+
+	for y := 20; y < 27; y++ {
+		for x := 20; x < 27; x++ {
+			assert.NotEqual(t, src.At(x, y), dst.At(x, y))
+		}
+	}
+}
+
+func TestBlurRemovesDetailFromFace(t *testing.T) {
+	src := checkerboard(image.Rect(0, 0, 60, 60))
+
+	dst, err := redact(src, []Face{redactFace}, ModeBlur, Options{Expand: 1.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := image.Rect(18, 18, 42, 42)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c, _, _, a := dst.At(x, y).RGBA()
+			assert.InDelta(t, 0x7fff, c, 0x1000, "pixel %d,%d should be grey", x, y)
+			assert.Equal(t, uint32(0xffff), a)
+		}
+	}
+	assertOutsideUnchanged(t, src, dst, r)
+}
+
+func TestEmojimiseUsesModeFromOptionsOrDefault(t *testing.T) {
+	e := setupSelection(t)
+	src := checkerboard(image.Rect(0, 0, 60, 60))
+
+	dst, err := e.Emojimise(src, []Face{redactFace}, Options{Mode: ModeFill})
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, dst.At(30, 31))
+
+	e.SetMode(ModeFill)
+	frames, err := e.EmojimiseFrames([]image.Image{src, src}, [][]Face{{redactFace}, {}}, Options{Fill: color.White})
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, frames[0].At(30, 31))
+	assert.Equal(t, src.At(30, 31), frames[1].At(30, 31))
+}
+
+func TestEmojimiseWithUnknownModeReturnsPermanentError(t *testing.T) {
+	e := setupSelection(t)
+
+	_, err := e.Emojimise(checkerboard(image.Rect(0, 0, 60, 60)), []Face{redactFace}, Options{Mode: "smudge"})
+
+	assert.Equal(t, ReasonUnknownMode, FailureReason(err))
+}
+
+func TestValidModeOnlyAcceptsSupportedModes(t *testing.T) {
+	for _, m := range []string{ModeEmoji, ModeBlur, ModePixelate, ModeFill} {
+		assert.True(t, ValidMode(m), m)
+	}
+
+	assert.False(t, ValidMode("smudge"))
+	assert.False(t, ValidMode(""))
+}
+
+func TestNewOptionsParsesRedactionOptions(t *testing.T) {
+	o := NewOptions(map[string]string{"mode": "pixelate", "block": "12", "expand": "1.25", "fill": "#00ff00"}, "", "")
+
+	assert.Equal(t, ModePixelate, o.Mode)
+	assert.Equal(t, 12, o.BlockSize)
+	assert.Equal(t, 1.25, o.Expand)
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, o.Fill)
+}
+
+func TestValidateOptionsChecksRedactionOptions(t *testing.T) {
+	assert.Nil(t, ValidateOptions(map[string]string{"mode": "blur", "expand": "2"}))
+	assert.Nil(t, ValidateOptions(map[string]string{"mode": "fill", "fill": "ffffff"}))
+
+	assert.Error(t, ValidateOptions(map[string]string{"mode": "smudge"}))
+	assert.Error(t, ValidateOptions(map[string]string{"block": "1"}))
+	assert.Error(t, ValidateOptions(map[string]string{"expand": "0.5"}))
+	assert.Error(t, ValidateOptions(map[string]string{"fill": "red"}))
+}
+
+func TestBoxRadiiApproximateGaussian(t *testing.T) {
+	for _, sigma := range []float64{3, 12.5, 250} {
+		// the variance of a box blur of width w is (w*w-1)/12
+		v := 0.0
+		for _, r := range boxRadii(sigma, blurPasses) {
+			w := float64(2*r + 1)
+			v += (w*w - 1) / 12
+		}
+
+		assert.InEpsilon(t, sigma*sigma, v, 0.2, "sigma %v", sigma)
+	}
+}
+
+func BenchmarkBlur(b *testing.B) {
+	for _, size := range []int{250, 1000, 2000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			src := checkerboard(image.Rect(0, 0, size, size))
+			faces := Rects(src.Bounds())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				redact(src, faces, ModeBlur, Options{})
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package emojify
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// rotate returns the image rotated clockwise by degrees around its centre,
+// the image is translated so its top left is at the origin, the returned
+// image is large enough to hold the rotation and has the same centre.
+// Pixels are sampled bilinearly with premultiplied alpha so edges are
+// smooth and transparent areas do not bleed colour.
+func rotate(src image.Image, degrees float64) *image.RGBA {
+	s := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(s, s.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+
+	w, h := float64(s.Bounds().Dx()), float64(s.Bounds().Dy())
+	dw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+	dh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+
+	// keep the difference in size even so the centre does not move
+	dw += (dw - s.Bounds().Dx()) & 1
+	dh += (dh - s.Bounds().Dy()) & 1
+
+	ox := (dw - s.Bounds().Dx()) / 2
+	oy := (dh - s.Bounds().Dy()) / 2
+	dst := image.NewRGBA(image.Rect(-ox, -oy, dw-ox, dh-oy))
+
+	cx, cy := w/2, h/2
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// map the centre of the destination pixel back to the source
+			dx := float64(x-ox) + 0.5 - cx
+			dy := float64(y-oy) + 0.5 - cy
+			sx := cos*dx + sin*dy + cx - 0.5
+			sy := -sin*dx + cos*dy + cy - 0.5
+
+			dst.SetRGBA(x-ox, y-oy, bilinear(s, sx, sy))
+		}
+	}
+
+	return dst
+}
+
+// bilinear samples the image at a fractional point, points outside of the
+// image are transparent
+func bilinear(img *image.RGBA, x, y float64) color.RGBA {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var r, g, b, a float64
+	for _, p := range []struct {
+		x, y int
+		w    float64
+	}{
+		{x0, y0, (1 - fx) * (1 - fy)},
+		{x0 + 1, y0, fx * (1 - fy)},
+		{x0, y0 + 1, (1 - fx) * fy},
+		{x0 + 1, y0 + 1, fx * fy},
+	} {
+		if !(image.Point{p.x, p.y}.In(img.Bounds())) {
+			continue
+		}
+
+		c := img.RGBAAt(p.x, p.y)
+		r += float64(c.R) * p.w
+		g += float64(c.G) * p.w
+		b += float64(c.B) * p.w
+		a += float64(c.A) * p.w
+	}
+
+	return color.RGBA{uint8(r + 0.5), uint8(g + 0.5), uint8(b + 0.5), uint8(a + 0.5)}
+}
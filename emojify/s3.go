@@ -0,0 +1,221 @@
+package emojify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyHash is the SHA-256 of an empty payload
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Handler fetches objects from an S3 compatible object store using
+// s3://bucket/key URLs, requests are signed with AWS Signature Version 4
+type S3Handler struct {
+	endpoint   *url.URL
+	region     string
+	accessKey  string
+	secretKey  string
+	buckets    []string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Handler creates a handler for the object store at endpoint, e.g.
+// https://s3.eu-west-1.amazonaws.com or http://minio:9000, objects are
+// requested using path style URLs, only objects in the given buckets can
+// be fetched
+func NewS3Handler(endpoint, region, accessKey, secretKey string, buckets []string) (*S3Handler, error) {
+	e, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Scheme == "" || e.Host == "" {
+		return nil, fmt.Errorf("endpoint must be an absolute url")
+	}
+
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("at least one bucket must be allowed")
+	}
+
+	return &S3Handler{
+		endpoint:   e,
+		region:     region,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		buckets:    buckets,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+// Fetch downloads the object identified by the bucket and key in the URL
+func (s *S3Handler) Fetch(u *url.URL, maxSize int64) ([]byte, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, blocked(ReasonURLBlocked, "s3 urls must have a bucket and key")
+	}
+
+	if !s.allowedBucket(u.Host) {
+		return nil, blocked(ReasonURLBlocked, "bucket %s is not allowed", u.Host)
+	}
+
+	o := *s.endpoint
+	o.Path = strings.TrimSuffix(o.Path, "/") + "/" + u.Host + "/" + key
+
+	req, err := http.NewRequest(http.MethodGet, o.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	signV4(req, emptyHash, s.accessKey, s.secretKey, s.region, "s3", s.now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &PermanentError{Reason: ReasonNotFound, Err: fmt.Errorf("object %s not found", u.String())}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if resp.ContentLength > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: resp.ContentLength}
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(buf)) > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: -1}
+	}
+
+	return buf, nil
+}
+
+// allowedBucket returns true when the bucket is in the allow list
+func (s *S3Handler) allowedBucket(bucket string) bool {
+	for _, b := range s.buckets {
+		if b == bucket {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to the
+// request, the host and any x-amz- headers are signed, the path of the
+// request is replaced with its canonical encoding so the signed and sent
+// paths are the same
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region, service string, t time.Time) {
+	t = t.UTC()
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	names := []string{}
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, n := range names {
+		canonicalHeaders += n + ":" + headers[n] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := uriEncode(req.URL.Path, false)
+	if path == "" {
+		path = "/"
+	}
+	req.URL.RawPath = path
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		scope,
+		hex.EncodeToString(crHash[:]),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secretKey, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func signingKey(secretKey, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secretKey), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent encodes every byte of s except the unreserved
+// characters A-Z, a-z, 0-9, '-', '.', '_' and '~' as required by AWS
+// Signature Version 4, '/' is only encoded when encodeSlash is true
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// canonicalQuery encodes the query with keys sorted and spaces as %20
+func canonicalQuery(v url.Values) string {
+	return strings.Replace(v.Encode(), "+", "%20", -1)
+}
@@ -0,0 +1,142 @@
+package emojify
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SchemeHandler downloads the file for a URL with a particular scheme,
+// handlers must return a SizeError for files larger than maxSize
+type SchemeHandler interface {
+	Fetch(u *url.URL, maxSize int64) ([]byte, error)
+}
+
+// SchemeHandlerFunc allows a function to be used as a SchemeHandler
+type SchemeHandlerFunc func(u *url.URL, maxSize int64) ([]byte, error)
+
+// Fetch calls f(u, maxSize)
+func (f SchemeHandlerFunc) Fetch(u *url.URL, maxSize int64) ([]byte, error) {
+	return f(u, maxSize)
+}
+
+// DataHandler decodes images embedded in data: URIs
+type DataHandler struct{}
+
+// Fetch decodes the data in the URI, the media type must be an image
+func (d *DataHandler) Fetch(u *url.URL, maxSize int64) ([]byte, error) {
+	// the data may contain characters parsed as the query
+	opaque := u.Opaque
+	if u.RawQuery != "" {
+		opaque += "?" + u.RawQuery
+	}
+
+	i := strings.Index(opaque, ",")
+	if i < 0 {
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: fmt.Errorf("data uri has no data")}
+	}
+
+	meta, data := opaque[:i], opaque[i+1:]
+
+	encoded := strings.HasSuffix(meta, ";base64")
+	if encoded {
+		meta = strings.TrimSuffix(meta, ";base64")
+	}
+
+	// the media type defaults to text/plain when omitted
+	mt, _, err := mime.ParseMediaType(meta)
+	if err != nil || !strings.HasPrefix(mt, "image/") {
+		return nil, &ContentTypeError{ContentType: meta}
+	}
+
+	var buf []byte
+	if encoded {
+		buf, err = base64.StdEncoding.DecodeString(data)
+	} else {
+		var s string
+		s, err = url.PathUnescape(data)
+		buf = []byte(s)
+	}
+
+	if err != nil {
+		return nil, &PermanentError{Reason: ReasonInvalidImage, Err: fmt.Errorf("unable to decode data uri: %s", err)}
+	}
+
+	if int64(len(buf)) > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: int64(len(buf))}
+	}
+
+	return buf, nil
+}
+
+// FileHandler reads images from the local filesystem, files must be
+// inside the root directory
+type FileHandler struct {
+	root string
+}
+
+// NewFileHandler creates a FileHandler which serves files under root
+func NewFileHandler(root string) (*FileHandler, error) {
+	r, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve links so the root can be compared with resolved file paths
+	r, err = filepath.EvalSymlinks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileHandler{r}, nil
+}
+
+// Fetch reads the file at the path in the URL relative to the root
+func (f *FileHandler) Fetch(u *url.URL, maxSize int64) ([]byte, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, blocked(ReasonURLBlocked, "file urls must not have a host")
+	}
+
+	// links are resolved before checking the path is inside the root so
+	// a link can not point outside it
+	p := filepath.Join(f.root, filepath.FromSlash(u.Path))
+	p, err := filepath.EvalSymlinks(p)
+	if os.IsNotExist(err) {
+		return nil, &PermanentError{Reason: ReasonNotFound, Err: fmt.Errorf("file %s not found", u.Path)}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p != f.root && !strings.HasPrefix(p, f.root+string(filepath.Separator)) {
+		return nil, blocked(ReasonURLBlocked, "file %s is outside the root directory", u.Path)
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return nil, &PermanentError{Reason: ReasonNotFound, Err: fmt.Errorf("%s is a directory", u.Path)}
+	}
+
+	if fi.Size() > maxSize {
+		return nil, &SizeError{Limit: maxSize, Size: fi.Size()}
+	}
+
+	return ioutil.ReadAll(io.LimitReader(file, maxSize))
+}
@@ -0,0 +1,270 @@
+package emojify
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func schemePolicy() URLPolicy {
+	p := DefaultURLPolicy()
+	p.Schemes = append(p.Schemes, "data", "file", "s3")
+	return p
+}
+
+func TestFetchImageDecodesDataURI(t *testing.T) {
+	f := NewFetcher(schemePolicy(), MaxFileSize)
+	f.RegisterScheme("data", &DataHandler{})
+
+	r, err := f.FetchImage("data:image/png;base64,aW1hZ2UgZGF0YQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "image data", string(d))
+}
+
+func TestDataHandlerRejectsInvalidURIs(t *testing.T) {
+	h := &DataHandler{}
+
+	tt := map[string]string{
+		"data:text/plain;base64,aW1hZ2UgZGF0YQ==": ReasonUnsupportedContentType,
+		"data:,image":                            ReasonUnsupportedContentType,
+		"data:image/png;base64,!!!":              ReasonInvalidImage,
+		"data:image/png;base64":                  ReasonInvalidImage,
+		"data:image/png;base64,aW1hZ2UgZGF0YQ==": ReasonTooLarge,
+	}
+
+	for uri, reason := range tt {
+		u, _ := url.Parse(uri)
+		_, err := h.Fetch(u, 5)
+		assert.Equal(t, reason, FailureReason(err), uri)
+	}
+}
+
+func TestFetchImageRejectsSchemesWithoutHandler(t *testing.T) {
+	f := NewFetcher(schemePolicy(), MaxFileSize)
+
+	_, err := f.FetchImage("s3://bucket/key.jpg")
+
+	assert.Equal(t, ReasonURLBlocked, FailureReason(err))
+}
+
+func TestFetchImageRejectsSchemesNotAllowedByPolicy(t *testing.T) {
+	f := NewFetcher(DefaultURLPolicy(), MaxFileSize)
+	f.RegisterScheme("data", &DataHandler{})
+
+	_, err := f.FetchImage("data:image/png;base64,aW1hZ2UgZGF0YQ==")
+
+	assert.Equal(t, ReasonURLBlocked, FailureReason(err))
+}
+
+func setupFileRoot(t *testing.T) (string, *FileHandler) {
+	dir, err := ioutil.TempDir("", "fileroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "root", "images"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "root", "images", "a.jpg"), []byte("image data"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0600)
+	os.Symlink(filepath.Join(dir, "secret"), filepath.Join(dir, "root", "link"))
+
+	h, err := NewFileHandler(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, h
+}
+
+func TestFileHandlerReadsFilesInRoot(t *testing.T) {
+	dir, h := setupFileRoot(t)
+	defer os.RemoveAll(dir)
+
+	f := NewFetcher(schemePolicy(), MaxFileSize)
+	f.RegisterScheme("file", h)
+
+	r, err := f.FetchImage("file:///images/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "image data", string(d))
+}
+
+func TestFileHandlerRejectsPathsOutsideRoot(t *testing.T) {
+	dir, h := setupFileRoot(t)
+	defer os.RemoveAll(dir)
+
+	tt := map[string]string{
+		"file:///../secret":           ReasonURLBlocked,
+		"file:///images/../../secret": ReasonURLBlocked,
+		"file:///link":                ReasonURLBlocked,
+		"file://remote/images/a.jpg":  ReasonURLBlocked,
+		"file:///images/missing.jpg":  ReasonNotFound,
+		"file:///images":              ReasonNotFound,
+	}
+
+	for uri, reason := range tt {
+		u, _ := url.Parse(uri)
+		_, err := h.Fetch(u, MaxFileSize)
+		assert.Equal(t, reason, FailureReason(err), uri)
+	}
+}
+
+func TestFileHandlerRejectsLargeFiles(t *testing.T) {
+	dir, h := setupFileRoot(t)
+	defer os.RemoveAll(dir)
+
+	u, _ := url.Parse("file:///images/a.jpg")
+	_, err := h.Fetch(u, 5)
+
+	assert.Equal(t, &SizeError{Limit: 5, Size: 10}, err)
+}
+
+// s3StandIn is a minimal S3 compatible server which serves objects from
+// memory and checks requests are signed with the expected credentials
+func s3StandIn(t *testing.T, objects map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		a := r.Header.Get("Authorization")
+		if !strings.HasPrefix(a, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(a, "/eu-west-1/s3/aws4_request") ||
+			!strings.Contains(a, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") ||
+			r.Header.Get("X-Amz-Date") == "" {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		o, ok := objects[r.URL.Path]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		rw.Write([]byte(o))
+	}))
+}
+
+func TestS3HandlerFetchesObject(t *testing.T) {
+	s := s3StandIn(t, map[string]string{"/images/faces/a.jpg": "image data"})
+	defer s.Close()
+
+	h, _ := NewS3Handler(s.URL, "eu-west-1", "AKIDEXAMPLE", "secret", []string{"images"})
+	f := NewFetcher(schemePolicy(), MaxFileSize)
+	f.RegisterScheme("s3", h)
+
+	r, err := f.FetchImage("s3://images/faces/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "image data", string(d))
+}
+
+func TestS3HandlerFetchesObjectWithReservedCharactersInKey(t *testing.T) {
+	s := s3StandIn(t, map[string]string{"/images/faces/a b!(1).jpg": "image data"})
+	defer s.Close()
+
+	h, _ := NewS3Handler(s.URL, "eu-west-1", "AKIDEXAMPLE", "secret", []string{"images"})
+	u, _ := url.Parse("s3://images/faces/a%20b!(1).jpg")
+
+	d, err := h.Fetch(u, MaxFileSize)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "image data", string(d))
+}
+
+func TestS3HandlerRejectsBucketNotAllowed(t *testing.T) {
+	s := s3StandIn(t, map[string]string{"/private/a.jpg": "image data"})
+	defer s.Close()
+
+	h, _ := NewS3Handler(s.URL, "eu-west-1", "AKIDEXAMPLE", "secret", []string{"images"})
+	u, _ := url.Parse("s3://private/a.jpg")
+
+	_, err := h.Fetch(u, MaxFileSize)
+
+	assert.Equal(t, ReasonURLBlocked, FailureReason(err))
+}
+
+func TestNewS3HandlerRequiresBuckets(t *testing.T) {
+	_, err := NewS3Handler("http://localhost:9000", "eu-west-1", "AKIDEXAMPLE", "secret", nil)
+
+	assert.Error(t, err)
+}
+
+func TestS3HandlerReturnsNotFound(t *testing.T) {
+	s := s3StandIn(t, map[string]string{})
+	defer s.Close()
+
+	h, _ := NewS3Handler(s.URL, "eu-west-1", "AKIDEXAMPLE", "secret", []string{"images"})
+	u, _ := url.Parse("s3://images/missing.jpg")
+
+	_, err := h.Fetch(u, MaxFileSize)
+
+	assert.Equal(t, ReasonNotFound, FailureReason(err))
+}
+
+func TestS3HandlerReturnsStatusErrorForBadCredentials(t *testing.T) {
+	s := s3StandIn(t, map[string]string{})
+	defer s.Close()
+
+	h, _ := NewS3Handler(s.URL, "us-east-1", "AKIDEXAMPLE", "secret", []string{"images"})
+	u, _ := url.Parse("s3://images/a.jpg")
+
+	_, err := h.Fetch(u, MaxFileSize)
+
+	assert.Equal(t, &HTTPStatusError{StatusCode: http.StatusForbidden}, err)
+}
+
+// the expected values are from the AWS Signature Version 4 documentation
+// and test suite
+func TestSigningKeyMatchesAWSExample(t *testing.T) {
+	k := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(k))
+}
+
+func TestSignV4MatchesAWSTestSuite(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	ts, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+
+	signV4(r, emptyHash, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", ts)
+
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		r.Header.Get("Authorization"),
+	)
+}
+
+// get-utf8 from the AWS test suite, the path must be URI encoded
+func TestSignV4EncodesPathLikeAWSTestSuite(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.amazonaws.com/\u1234", nil)
+	ts, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+
+	signV4(r, emptyHash, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", ts)
+
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85",
+		r.Header.Get("Authorization"),
+	)
+	assert.Equal(t, "/%E1%88%B4", r.URL.EscapedPath())
+}
+
+func TestURIEncodeOnlyKeepsUnreservedCharacters(t *testing.T) {
+	assert.Equal(t, "/a/b-c_d.e~f", uriEncode("/a/b-c_d.e~f", false))
+	assert.Equal(t, "%2Fa%2Fb", uriEncode("/a/b", true))
+	assert.Equal(t, "/a%20b%21%28%29%2A%27%3A%40%2B%24%2C%3B%3D", uriEncode("/a b!()*':@+$,;=", false))
+}
@@ -0,0 +1,198 @@
+package emojify
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Processing options which control how emoji are chosen
+const (
+	// OptionSelection is the name of the selection strategy
+	OptionSelection = "selection"
+	// OptionCodepoint is the emoji used by the fixed strategy, e.g. 1f600
+	OptionCodepoint = "codepoint"
+	// OptionTag limits selection to emoji with a tag, e.g. happy
+	OptionTag = "tag"
+	// OptionPack is the name of the emoji pack to use
+	OptionPack = "pack"
+)
+
+// Names of the built in selection strategies
+const (
+	// SelectRandom chooses a random emoji for each face, reprocessing an
+	// image gives a different result
+	SelectRandom = "random"
+	// SelectSeeded chooses emoji at random using the job ID as the seed so
+	// reprocessing an image gives the same result
+	SelectSeeded = "seeded"
+	// SelectFixed uses the emoji set with the codepoint option for every face
+	SelectFixed = "fixed"
+	// SelectRoundRobin gives each face the next emoji in codepoint order
+	SelectRoundRobin = "round-robin"
+	// SelectSame uses one emoji for every face, chosen using the seed
+	SelectSame = "same"
+)
+
+// ReasonUnknownEmoji is reported when a request selects an emoji or
+// selection strategy which does not exist
+const ReasonUnknownEmoji = "UNKNOWN_EMOJI"
+
+// Options control how the faces in an image are replaced
+type Options struct {
+	// Selection is the name of the selection strategy, the default is used
+	// when empty
+	Selection string
+	// Seed makes seeded selection repeatable, usually the job ID
+	Seed string
+	// Codepoint of the emoji used by the fixed strategy
+	Codepoint string
+	// Tag limits selection to emoji with the tag in the pack manifest
+	Tag string
+	// Pack is the name of the emoji pack, when empty the tenant's pack or
+	// the default pack is used
+	Pack string
+	// Tenant is the owner of the request
+	Tenant string
+	// Track faces between the frames of an animation so each face keeps
+	// the same emoji
+	Track bool
+	// Mode used to replace the faces, emoji are drawn when empty
+	Mode string
+	// BlockSize of the pixelate mode, chosen from the size of the face
+	// when 0
+	BlockSize int
+	// Expand grows the area redacted around each face
+	Expand float64
+	// Fill is the colour of the fill mode, black when nil
+	Fill color.Color
+	// Feather is the width of the soft edge of the emoji relative to its
+	// size, Shadow is the offset of the drop shadow relative to its size
+	Feather float64
+	Shadow  float64
+	// Opacity of the emoji, emoji are opaque when 0
+	Opacity float64
+}
+
+// NewOptions returns the options for a request, the seed is used for
+// seeded selection and the tenant chooses the default pack. Options which
+// are not valid are ignored, requests are checked with ValidateOptions.
+func NewOptions(options map[string]string, seed, tenant string) Options {
+	o := Options{
+		Selection: options[OptionSelection],
+		Codepoint: options[OptionCodepoint],
+		Tag:       options[OptionTag],
+		Pack:      options[OptionPack],
+		Seed:      seed,
+		Tenant:    tenant,
+		Mode:      options[OptionMode],
+	}
+
+	o.BlockSize, _ = strconv.Atoi(options[OptionBlockSize])
+	o.Expand, _ = strconv.ParseFloat(options[OptionExpand], 64)
+	o.Feather, _ = strconv.ParseFloat(options[OptionFeather], 64)
+	o.Shadow, _ = strconv.ParseFloat(options[OptionShadow], 64)
+	o.Opacity, _ = strconv.ParseFloat(options[OptionOpacity], 64)
+	if f, ok := options[OptionFill]; ok {
+		o.Fill, _ = parseColour(f)
+	}
+
+	return o
+}
+
+// Selector chooses an emoji for each face from the available emoji, faces
+// are the areas of the image covered by each face and emojis are sorted by
+// codepoint
+type Selector interface {
+	Select(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error)
+}
+
+// SelectorFunc is a function which implements Selector
+type SelectorFunc func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error)
+
+// Select calls the function
+func (s SelectorFunc) Select(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+	return s(faces, emojis, o)
+}
+
+// selectors are available to every Impl
+var selectors = map[string]Selector{
+	SelectRandom: SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		return pick(len(faces), emojis, rand.Intn), nil
+	}),
+	SelectSeeded: SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		return pick(len(faces), emojis, seeded(o.Seed).Intn), nil
+	}),
+	SelectFixed: SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		i := findEmoji(emojis, o.Codepoint)
+		if i < 0 {
+			return nil, &PermanentError{Reason: ReasonUnknownEmoji, Err: fmt.Errorf("emoji %q does not exist", o.Codepoint)}
+		}
+
+		return pick(len(faces), emojis, func(int) int { return i }), nil
+	}),
+	SelectRoundRobin: SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		next := 0
+		return pick(len(faces), emojis, func(l int) int {
+			next++
+			return (next - 1) % l
+		}), nil
+	}),
+	SelectExpression: NewExpressionSelector(NewMouthClassifier(), DefaultExpressionTable(), DefaultMinConfidence),
+	SelectSame: SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		i := seeded(o.Seed).Intn(len(emojis))
+		return pick(len(faces), emojis, func(int) int { return i }), nil
+	}),
+}
+
+// validateSelection checks the selection options are not empty and the
+// fixed strategy has a codepoint
+func validateSelection(options map[string]string) error {
+	for _, name := range []string{OptionSelection, OptionCodepoint, OptionTag} {
+		if v, ok := options[name]; ok && v == "" {
+			return fmt.Errorf("invalid %s, must not be empty", name)
+		}
+	}
+
+	if options[OptionSelection] == SelectFixed && options[OptionCodepoint] == "" {
+		return fmt.Errorf("selection %s requires the %s option", SelectFixed, OptionCodepoint)
+	}
+
+	return nil
+}
+
+// findEmoji returns the index of the emoji with the codepoint or -1
+func findEmoji(emojis []*Emoji, codepoint string) int {
+	i := sort.Search(len(emojis), func(i int) bool { return emojis[i].Codepoint >= codepoint })
+	if i == len(emojis) || emojis[i].Codepoint != codepoint {
+		return -1
+	}
+
+	return i
+}
+
+// pick returns n emoji using next to choose the index of each
+func pick(n int, emojis []*Emoji, next func(int) int) []*Emoji {
+	chosen := make([]*Emoji, n)
+	for i := range chosen {
+		chosen[i] = emojis[next(len(emojis))]
+	}
+
+	return chosen
+}
+
+// seeded returns a random source seeded by s, a random seed is used when
+// s is empty
+func seeded(s string) *rand.Rand {
+	if s == "" {
+		return rand.New(rand.NewSource(rand.Int63()))
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
@@ -0,0 +1,211 @@
+package emojify
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden images in testdata/golden")
+
+var goldenFaces = Rects(image.Rect(8, 8, 40, 40), image.Rect(56, 8, 88, 40), image.Rect(32, 40, 64, 64))
+
+func setupSelection(t *testing.T) *Impl {
+	pack, err := LoadPack("testdata/emoji/")
+	if err != nil {
+		t.Fatal("unable to load test emoji", err)
+	}
+
+	return &Impl{packs: StaticPacks(map[string]*Pack{DefaultPack: pack}), padding: 1, selectors: map[string]Selector{}}
+}
+
+func goldenSource() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 96; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 2), uint8(y * 3), 128, 255})
+		}
+	}
+
+	return img
+}
+
+// assertGolden compares the image to testdata/golden/name.png, channels may
+// differ by one as fused multiply add changes rounding on some platforms
+func assertGolden(t *testing.T, name string, img image.Image) {
+	file := "testdata/golden/" + name + ".png"
+
+	if *update {
+		f, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Equal(t, golden.Bounds(), img.Bounds(), name) {
+		return
+	}
+
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			if !similar(golden.At(x, y), img.At(x, y)) {
+				t.Fatalf("%s differs from golden image at %d,%d: %v != %v", name, x, y, img.At(x, y), golden.At(x, y))
+			}
+		}
+	}
+}
+
+func similar(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+
+	for _, d := range []int{int(ar) - int(br), int(ag) - int(bg), int(ab) - int(bb), int(aa) - int(ba)} {
+		if d > 0x101 || d < -0x101 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSelectionStrategiesMatchGoldenImages(t *testing.T) {
+	e := setupSelection(t)
+
+	for _, o := range []Options{
+		{Selection: SelectSeeded, Seed: "job-1"},
+		{Selection: SelectFixed, Codepoint: "1f60d"},
+		{Selection: SelectRoundRobin},
+		{Selection: SelectSame, Seed: "job-1"},
+	} {
+		out, err := e.Emojimise(goldenSource(), goldenFaces, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertGolden(t, o.Selection, out)
+	}
+}
+
+func TestSeededSelectionIsRepeatable(t *testing.T) {
+	e := setupSelection(t)
+	o := Options{Selection: SelectSeeded, Seed: "job-1"}
+
+	a, _ := e.selectEmoji(make([]image.Image, 20), o)
+	b, _ := e.selectEmoji(make([]image.Image, 20), o)
+	c, _ := e.selectEmoji(make([]image.Image, 20), Options{Selection: SelectSeeded, Seed: "job-2"})
+
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+}
+
+func TestRoundRobinSelectionUsesEachEmojiInTurn(t *testing.T) {
+	e := setupSelection(t)
+
+	s, _ := e.selectEmoji(make([]image.Image, 4), Options{Selection: SelectRoundRobin})
+
+	assert.Equal(t, []string{"1f600", "1f60d", "1f622", "1f600"}, codepoints(s))
+}
+
+func TestSameSelectionUsesOneEmoji(t *testing.T) {
+	e := setupSelection(t)
+
+	s, _ := e.selectEmoji(make([]image.Image, 3), Options{Selection: SelectSame, Seed: "job-1"})
+
+	assert.Equal(t, s[0], s[1])
+	assert.Equal(t, s[0], s[2])
+}
+
+func TestFixedSelectionWithUnknownCodepointReturnsPermanentError(t *testing.T) {
+	e := setupSelection(t)
+
+	_, err := e.Emojimise(goldenSource(), goldenFaces, Options{Selection: SelectFixed, Codepoint: "1f4a9"})
+
+	assert.Equal(t, ReasonUnknownEmoji, FailureReason(err))
+	assert.True(t, IsPermanent(err))
+}
+
+func TestUnknownSelectionReturnsPermanentError(t *testing.T) {
+	e := setupSelection(t)
+
+	_, err := e.Emojimise(goldenSource(), goldenFaces, Options{Selection: "favourite"})
+
+	assert.Equal(t, ReasonUnknownEmoji, FailureReason(err))
+}
+
+func TestRegisteredSelectorIsUsed(t *testing.T) {
+	e := setupSelection(t)
+	e.RegisterSelector("last", SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		return pick(len(faces), emojis, func(l int) int { return l - 1 }), nil
+	}))
+	e.SetSelection("last")
+
+	s, err := e.selectEmoji(make([]image.Image, 2), Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1f622", "1f622"}, codepoints(s))
+}
+
+func TestValidateSelectionChecksStrategyCodepointAndTag(t *testing.T) {
+	e := setupSelection(t)
+	e.RegisterSelector("last", SelectorFunc(func(faces []image.Image, emojis []*Emoji, o Options) ([]*Emoji, error) {
+		return nil, nil
+	}))
+
+	assert.Nil(t, e.ValidateSelection(nil, ""))
+	assert.Nil(t, e.ValidateSelection(map[string]string{OptionSelection: "last"}, ""))
+	assert.Nil(t, e.ValidateSelection(map[string]string{OptionSelection: SelectFixed, OptionCodepoint: "1f600"}, ""))
+	assert.Error(t, e.ValidateSelection(map[string]string{OptionSelection: "favourite"}, ""))
+	assert.Error(t, e.ValidateSelection(map[string]string{OptionSelection: SelectFixed, OptionCodepoint: "1f4a9"}, ""))
+	assert.Error(t, e.ValidateSelection(map[string]string{OptionTag: "sad"}, ""))
+	assert.Error(t, e.ValidateSelection(map[string]string{OptionCodepoint: "1f600", OptionPack: "summer"}, ""))
+}
+
+func TestSeededIsTrueWhenSelectionUsesTheSeed(t *testing.T) {
+	e := setupSelection(t)
+	e.SetSelection(SelectSeeded)
+
+	assert.True(t, e.Seeded(Options{}))
+	assert.True(t, e.Seeded(Options{Selection: SelectSame}))
+	assert.False(t, e.Seeded(Options{Selection: SelectRoundRobin}))
+	assert.False(t, e.Seeded(Options{Mode: ModeBlur}))
+}
+
+func codepoints(emojis []*Emoji) []string {
+	c := make([]string, len(emojis))
+	for i, e := range emojis {
+		c[i] = e.Codepoint
+	}
+
+	return c
+}
+
+func testEmojis(t *testing.T, e *Impl) []*Emoji {
+	p, err := e.pack(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p.Emojis()
+}
@@ -0,0 +1,70 @@
+package emojify
+
+import "image"
+
+const (
+	// minTrackOverlap is the intersection over union required for a face
+	// to be considered the same face as one in an earlier frame
+	minTrackOverlap = 0.3
+
+	// maxTrackGap is the number of frames a face can be missed by face
+	// detection before its track ends
+	maxTrackGap = 5
+)
+
+type track struct {
+	id   int
+	face image.Rectangle
+	seen int
+}
+
+// TrackFaces follows faces between the frames of an animation, the returned
+// slice holds a track id for every face in every frame, faces with the same
+// id are the same face. Faces are matched greedily to the most overlapping
+// face from recent frames, faces which do not match start a new track.
+func TrackFaces(frames [][]Face) [][]int {
+	ids := make([][]int, len(frames))
+	tracks := []*track{}
+
+	for i, faces := range frames {
+		ids[i] = make([]int, len(faces))
+		used := map[*track]bool{}
+
+		for j, f := range faces {
+			var best *track
+			bestOverlap := minTrackOverlap
+
+			for _, t := range tracks {
+				if o := overlap(f.Rectangle, t.face); !used[t] && i-t.seen <= maxTrackGap && o >= bestOverlap {
+					best, bestOverlap = t, o
+				}
+			}
+
+			if best == nil {
+				best = &track{id: len(tracks)}
+				tracks = append(tracks, best)
+			}
+
+			used[best] = true
+			best.face = f.Rectangle
+			best.seen = i
+			ids[i][j] = best.id
+		}
+	}
+
+	return ids
+}
+
+// overlap returns the intersection over union of two rectangles
+func overlap(a, b image.Rectangle) float64 {
+	in := area(a.Intersect(b))
+	if in == 0 {
+		return 0
+	}
+
+	return float64(in) / float64(area(a)+area(b)-in)
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
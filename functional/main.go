@@ -41,7 +41,7 @@ func main() {
 
 func doWork(url string, wg *sync.WaitGroup) {
 	// post an image to the server
-	postresp, err := emojifyClient.Create(context.Background(), &wrappers.StringValue{Value: url})
+	postresp, err := emojifyClient.Create(context.Background(), &emojify.CreateRequest{Uri: url})
 	if err != nil {
 		log.Println(err)
 		os.Exit(1)
@@ -59,6 +59,11 @@ func doWork(url string, wg *sync.WaitGroup) {
 				if getresp.GetStatus().GetStatus() == emojify.QueryStatus_FINISHED {
 					break
 				}
+
+				if getresp.GetStatus().GetStatus() == emojify.QueryStatus_FAILED {
+					log.Println("Failed", getresp.GetError())
+					break
+				}
 			}
 
 			time.Sleep(5 * time.Second)
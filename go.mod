@@ -4,6 +4,7 @@ go 1.12
 
 require (
 	github.com/DataDog/datadog-go v0.0.0-20190409101831-be7ca570f91a
+	github.com/alicebob/miniredis/v2 v2.8.0
 	github.com/emojify-app/cache v0.4.3
 	github.com/emojify-app/face-detection v0.1.9
 	github.com/go-redis/redis v6.15.1+incompatible
@@ -17,6 +18,7 @@ require (
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/rkt/rkt v1.30.0
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/image v0.0.0-20190501045829-6d32002ffd75
 	golang.org/x/net v0.0.0-20190301231341-16b79f2e4e95
 	golang.org/x/sys v0.0.0-20190308023053-584f3b12f43e // indirect
 	google.golang.org/grpc v1.19.0
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"errors"
+	"image"
+	"net/url"
+	"time"
+
+	"github.com/emojify-app/emojify/emojify"
+)
+
+// ErrNotFound is returned when a job does not exist in the store
+var ErrNotFound = errors.New("job not found")
+
+// Status of a job, values match the QueryStatus enum in the gRPC API
+type Status int
+
+const (
+	// StatusUnknown is used in filters to match any status
+	StatusUnknown Status = iota
+	// StatusQueued job is waiting on the queue
+	StatusQueued
+	// StatusFinished job has completed successfully
+	StatusFinished
+	// StatusProcessing job is being processed by a worker
+	StatusProcessing
+	// StatusFailed job could not be processed, Error contains the reason
+	StatusFailed
+)
+
+// Job is a record of an emojify request and its current state
+type Job struct {
+	// ID of the job, this is the same as the queue item ID
+	ID string
+	// URI of the image to process
+	URI string
+	// Owner is the caller who submitted the job
+	Owner string
+	// Options used to process the image
+	Options map[string]string
+	// Added is the time the job was created
+	Added time.Time
+	// Updated is the time of the last state change
+	Updated time.Time
+	// Completed is the time the job finished or failed
+	Completed time.Time
+	// Status of the job
+	Status Status
+	// Error is only set when the job has failed
+	Error string
+	// Reason is a code identifying the cause of the failure
+	Reason string
+	// Retryable is false when the job failed permanently and will fail
+	// again if it is resubmitted
+	Retryable bool
+	// FaceCount is the number of faces found in the image
+	FaceCount int
+	// CallbackURL is notified when the job finishes or fails
+	CallbackURL string
+	// PHash is the perceptual hash of the source image
+	PHash uint64
+	// InputFormat is the format of the source image, e.g. jpeg or webp
+	InputFormat string
+	// ContentType of the output image, e.g. image/png
+	ContentType string
+	// Bounds of the source image
+	Bounds image.Rectangle
+	// Faces found in the source image
+	Faces []emojify.Face
+	// AliasOf is the ID of a job with a near identical image, the output
+	// for this job is stored under that ID
+	AliasOf string
+}
+
+// OptionsKey returns a string which is the same for equal sets of options
+func OptionsKey(options map[string]string) string {
+	v := url.Values{}
+	for k, o := range options {
+		v.Set(k, o)
+	}
+
+	return v.Encode()
+}
+
+// similarityKey returns a string which is the same for jobs whose output
+// can be shared, the owner is included so the output of one owner is never
+// returned to another
+func similarityKey(owner string, options map[string]string) string {
+	return url.QueryEscape(owner) + "?" + OptionsKey(options)
+}
+
+// indexable returns true when the job can be used as the source for
+// near identical images
+func indexable(j *Job) bool {
+	return j.Status == StatusFinished && j.PHash != 0 && j.AliasOf == ""
+}
+
+// Filter defines the criteria for listing jobs, zero values match all jobs
+type Filter struct {
+	Owner       string
+	Status      Status
+	AddedAfter  time.Time
+	AddedBefore time.Time
+}
+
+// Match returns true when the job matches the filter
+func (f Filter) Match(j *Job) bool {
+	if f.Owner != "" && f.Owner != j.Owner {
+		return false
+	}
+
+	if f.Status != StatusUnknown && f.Status != j.Status {
+		return false
+	}
+
+	if !f.AddedAfter.IsZero() && j.Added.Before(f.AddedAfter) {
+		return false
+	}
+
+	if !f.AddedBefore.IsZero() && !j.Added.Before(f.AddedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// Store defines the interface for persisting job records
+type Store interface {
+	// Save creates or replaces a job record
+	Save(*Job) error
+	// Get a job by id, returns ErrNotFound when the job does not exist
+	Get(id string) (*Job, error)
+	// List jobs matching the filter ordered by most recently added,
+	// more is true when there are further results after offset+limit
+	List(f Filter, offset, limit int) (jobs []*Job, more bool, err error)
+	// FindSimilar returns a finished job with the same owner processed
+	// with the same options whose image hash is within maxDistance bits
+	// of hash, returns ErrNotFound when there is no similar job
+	FindSimilar(hash uint64, owner string, options map[string]string, maxDistance int) (*Job, error)
+}
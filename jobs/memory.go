@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/emojify-app/emojify/emojify"
+)
+
+// Memory is an in memory job store, records are lost when the process exits
+type Memory struct {
+	jobs map[string]*Job
+	mu   sync.RWMutex
+}
+
+// NewMemory creates a new in memory job store
+func NewMemory() *Memory {
+	return &Memory{jobs: map[string]*Job{}}
+}
+
+// Save creates or replaces a job record
+func (m *Memory) Save(j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := *j
+	m.jobs[j.ID] = &c
+
+	return nil
+}
+
+// Get a job by id
+func (m *Memory) Get(id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	c := *j
+	return &c, nil
+}
+
+// List jobs matching the filter ordered by most recently added
+func (m *Memory) List(f Filter, offset, limit int) ([]*Job, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matched := make([]*Job, 0)
+	for _, j := range m.jobs {
+		if f.Match(j) {
+			c := *j
+			matched = append(matched, &c)
+		}
+	}
+
+	sort.Slice(matched, func(a, b int) bool {
+		return matched[a].Added.After(matched[b].Added)
+	})
+
+	return page(matched, offset, limit)
+}
+
+// FindSimilar returns the closest finished job with the same owner and
+// options and a hash within maxDistance
+func (m *Memory) FindSimilar(hash uint64, owner string, options map[string]string, maxDistance int) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := similarityKey(owner, options)
+
+	var found *Job
+	best := maxDistance + 1
+	for _, j := range m.jobs {
+		if !indexable(j) || similarityKey(j.Owner, j.Options) != key {
+			continue
+		}
+
+		if d := emojify.HammingDistance(hash, j.PHash); d < best {
+			found = j
+			best = d
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+
+	c := *found
+	return &c, nil
+}
+
+// page returns the slice of jobs between offset and offset+limit
+func page(jobs []*Job, offset, limit int) ([]*Job, bool, error) {
+	if offset >= len(jobs) {
+		return []*Job{}, false, nil
+	}
+
+	end := offset + limit
+	if end >= len(jobs) {
+		return jobs[offset:], false, nil
+	}
+
+	return jobs[offset:end], true, nil
+}
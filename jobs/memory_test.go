@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMemory(t *testing.T) *Memory {
+	m := NewMemory()
+	now := time.Now()
+
+	m.Save(&Job{ID: "1", Owner: "a", Status: StatusFinished, Added: now.Add(-3 * time.Hour)})
+	m.Save(&Job{ID: "2", Owner: "a", Status: StatusFailed, Added: now.Add(-2 * time.Hour)})
+	m.Save(&Job{ID: "3", Owner: "b", Status: StatusFailed, Added: now.Add(-1 * time.Hour)})
+
+	return m
+}
+
+func TestMemoryGetReturnsErrNotFound(t *testing.T) {
+	m := setupMemory(t)
+
+	_, err := m.Get("nope")
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryListOrdersByMostRecent(t *testing.T) {
+	m := setupMemory(t)
+
+	js, more, err := m.List(Filter{}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Len(t, js, 3)
+	assert.Equal(t, "3", js[0].ID)
+	assert.Equal(t, "1", js[2].ID)
+}
+
+func TestMemoryListFiltersByOwnerAndStatus(t *testing.T) {
+	m := setupMemory(t)
+
+	js, _, err := m.List(Filter{Owner: "a", Status: StatusFailed}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestMemoryListFiltersByAddedRange(t *testing.T) {
+	m := setupMemory(t)
+	now := time.Now()
+
+	js, _, err := m.List(Filter{AddedAfter: now.Add(-150 * time.Minute), AddedBefore: now.Add(-90 * time.Minute)}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestMemoryListPages(t *testing.T) {
+	m := setupMemory(t)
+
+	js, more, err := m.List(Filter{}, 1, 1)
+
+	assert.Nil(t, err)
+	assert.True(t, more)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestMemoryFindSimilarReturnsClosestFinishedJob(t *testing.T) {
+	m := NewMemory()
+	m.Save(&Job{ID: "1", Status: StatusFinished, PHash: 0xff})
+	m.Save(&Job{ID: "2", Status: StatusFinished, PHash: 0xfe})
+	m.Save(&Job{ID: "3", Status: StatusFailed, PHash: 0xf0})
+	m.Save(&Job{ID: "4", Status: StatusFinished, PHash: 0xf0, AliasOf: "1"})
+
+	j, err := m.FindSimilar(0xf0, "", nil, 4)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2", j.ID)
+}
+
+func TestMemoryFindSimilarMatchesOptions(t *testing.T) {
+	m := NewMemory()
+	m.Save(&Job{ID: "1", Status: StatusFinished, PHash: 0xff, Options: map[string]string{"a": "1"}})
+
+	_, err := m.FindSimilar(0xff, "", nil, 4)
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryFindSimilarMatchesOwner(t *testing.T) {
+	m := NewMemory()
+	m.Save(&Job{ID: "1", Owner: "tenant1", Status: StatusFinished, PHash: 0xff})
+
+	_, err := m.FindSimilar(0xff, "tenant2", nil, 4)
+	assert.Equal(t, ErrNotFound, err)
+
+	j, err := m.FindSimilar(0xff, "tenant1", nil, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", j.ID)
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import "github.com/stretchr/testify/mock"
+
+// MockStore is a mock implementation of the Store interface for testing
+type MockStore struct {
+	mock.Mock
+}
+
+// Save is a mock implementation of the interface method
+func (m *MockStore) Save(j *Job) error {
+	args := m.Called(j)
+
+	return args.Error(0)
+}
+
+// Get is a mock implementation of the interface method
+func (m *MockStore) Get(id string) (*Job, error) {
+	args := m.Called(id)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Job), args.Error(1)
+}
+
+// FindSimilar is a mock implementation of the interface method
+func (m *MockStore) FindSimilar(hash uint64, owner string, options map[string]string, maxDistance int) (*Job, error) {
+	args := m.Called(hash, owner, options, maxDistance)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Job), args.Error(1)
+}
+
+// List is a mock implementation of the interface method
+func (m *MockStore) List(f Filter, offset, limit int) ([]*Job, bool, error) {
+	args := m.Called(f, offset, limit)
+
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+
+	return args.Get(0).([]*Job), args.Bool(1), args.Error(2)
+}
@@ -0,0 +1,235 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/emojify-app/emojify/emojify"
+	"github.com/go-redis/redis"
+)
+
+// listBatchSize is the number of index entries read at a time when listing
+const listBatchSize = 100
+
+// Redis is a job store backed by a Redis server
+//
+// Jobs are stored as JSON documents with an expiration, an ordered set
+// scored by the time the job was added is used as an index for listing.
+// A second ordered set is maintained for each owner. The perceptual
+// hashes of finished jobs are stored in a hash for each set of options.
+type Redis struct {
+	client     *redis.Client
+	prefix     string
+	expiration time.Duration
+}
+
+// NewRedis creates a new Redis job store, job records are removed after
+// the given retention period
+func NewRedis(addr, password string, db int, retention time.Duration) *Redis {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	return &Redis{
+		client:     client,
+		prefix:     "jobs",
+		expiration: retention,
+	}
+}
+
+// Save creates or replaces a job record
+func (r *Redis) Save(j *Job) error {
+	d, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("unable to marshal job to json: %s", err)
+	}
+
+	z := redis.Z{Score: float64(j.Added.UnixNano()), Member: j.ID}
+	expired := strconv.FormatInt(time.Now().Add(-r.expiration).UnixNano(), 10)
+
+	_, err = r.client.TxPipelined(func(p redis.Pipeliner) error {
+		p.Set(r.jobKey(j.ID), d, r.expiration)
+		p.ZAdd(r.indexKey(""), z)
+		p.ZRemRangeByScore(r.indexKey(""), "-inf", "("+expired)
+
+		if j.Owner != "" {
+			p.ZAdd(r.indexKey(j.Owner), z)
+			p.ZRemRangeByScore(r.indexKey(j.Owner), "-inf", "("+expired)
+			p.Expire(r.indexKey(j.Owner), r.expiration)
+		}
+
+		if indexable(j) {
+			p.HSet(r.hashKey(j.Owner, j.Options), j.ID, strconv.FormatUint(j.PHash, 16))
+			p.Expire(r.hashKey(j.Owner, j.Options), r.expiration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to save job: %s", err)
+	}
+
+	return nil
+}
+
+// Get a job by id
+func (r *Redis) Get(id string) (*Job, error) {
+	d, err := r.client.Get(r.jobKey(id)).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get job: %s", err)
+	}
+
+	j := &Job{}
+	err = json.Unmarshal([]byte(d), j)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal job: %s", err)
+	}
+
+	return j, nil
+}
+
+// List jobs matching the filter ordered by most recently added
+func (r *Redis) List(f Filter, offset, limit int) ([]*Job, bool, error) {
+	min := "-inf"
+	if !f.AddedAfter.IsZero() {
+		min = strconv.FormatInt(f.AddedAfter.UnixNano(), 10)
+	}
+
+	max := "+inf"
+	if !f.AddedBefore.IsZero() {
+		max = "(" + strconv.FormatInt(f.AddedBefore.UnixNano(), 10)
+	}
+
+	// the owner index is the most selective, status is filtered after
+	// the records have been fetched so the index can only be read from
+	// the offset when there is no status filter
+	start, skip := 0, offset
+	if f.Status == StatusUnknown {
+		start, skip = offset, 0
+	}
+
+	matched := make([]*Job, 0)
+	for {
+		ids, err := r.client.ZRevRangeByScore(r.indexKey(f.Owner), redis.ZRangeBy{
+			Min:    min,
+			Max:    max,
+			Offset: int64(start),
+			Count:  listBatchSize,
+		}).Result()
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to read job index: %s", err)
+		}
+
+		if len(ids) == 0 {
+			break
+		}
+
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = r.jobKey(id)
+		}
+
+		vals, err := r.client.MGet(keys...).Result()
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to get jobs: %s", err)
+		}
+
+		for _, v := range vals {
+			// records which have expired are no longer returned
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+
+			j := &Job{}
+			if err := json.Unmarshal([]byte(s), j); err != nil {
+				return nil, false, fmt.Errorf("unable to unmarshal job: %s", err)
+			}
+
+			if f.Match(j) {
+				matched = append(matched, j)
+			}
+		}
+
+		// stop reading once we have enough to know there is another page
+		if len(matched) > skip+limit || len(ids) < listBatchSize {
+			break
+		}
+
+		start += len(ids)
+	}
+
+	return page(matched, skip, limit)
+}
+
+// FindSimilar returns the closest finished job with the same owner and
+// options and a hash within maxDistance
+func (r *Redis) FindSimilar(hash uint64, owner string, options map[string]string, maxDistance int) (*Job, error) {
+	hashes, err := r.client.HGetAll(r.hashKey(owner, options)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read hash index: %s", err)
+	}
+
+	type candidate struct {
+		id       string
+		distance int
+	}
+
+	candidates := make([]candidate, 0)
+	for id, h := range hashes {
+		v, err := strconv.ParseUint(h, 16, 64)
+		if err != nil {
+			continue
+		}
+
+		if d := emojify.HammingDistance(hash, v); d <= maxDistance {
+			candidates = append(candidates, candidate{id, d})
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].distance < candidates[b].distance
+	})
+
+	for _, c := range candidates {
+		j, err := r.Get(c.id)
+		if err == ErrNotFound {
+			// the job record has expired, remove it from the index
+			r.client.HDel(r.hashKey(owner, options), c.id)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return j, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *Redis) hashKey(owner string, options map[string]string) string {
+	return r.prefix + ":phash:" + similarityKey(owner, options)
+}
+
+func (r *Redis) jobKey(id string) string {
+	return r.prefix + ":job:" + id
+}
+
+func (r *Redis) indexKey(owner string) string {
+	if owner == "" {
+		return r.prefix + ":index"
+	}
+
+	return r.prefix + ":owner:" + owner
+}
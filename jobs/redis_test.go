@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRedis(mr.Addr(), "", 0, 24*time.Hour)
+	now := time.Now()
+
+	r.Save(&Job{ID: "1", Owner: "a", Status: StatusFinished, Added: now.Add(-3 * time.Hour)})
+	r.Save(&Job{ID: "2", Owner: "a", Status: StatusFailed, Added: now.Add(-2 * time.Hour)})
+	r.Save(&Job{ID: "3", Owner: "b", Status: StatusFailed, Added: now.Add(-1 * time.Hour)})
+
+	return r, mr
+}
+
+func TestRedisGetReturnsErrNotFound(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	_, err := r.Get("nope")
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRedisListOrdersByMostRecent(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	js, more, err := r.List(Filter{}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Len(t, js, 3)
+	assert.Equal(t, "3", js[0].ID)
+	assert.Equal(t, "1", js[2].ID)
+}
+
+func TestRedisListFiltersByOwnerAndStatus(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	js, _, err := r.List(Filter{Owner: "a", Status: StatusFailed}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestRedisListFiltersByAddedRange(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+	now := time.Now()
+
+	js, _, err := r.List(Filter{AddedAfter: now.Add(-150 * time.Minute), AddedBefore: now.Add(-90 * time.Minute)}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestRedisListPages(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	js, more, err := r.List(Filter{}, 1, 1)
+
+	assert.Nil(t, err)
+	assert.True(t, more)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "2", js[0].ID)
+}
+
+func TestRedisListPagesAcrossBatches(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	r := NewRedis(mr.Addr(), "", 0, 24*time.Hour)
+	now := time.Now()
+	for i := 0; i < 250; i++ {
+		s := StatusFinished
+		if i%2 == 0 {
+			s = StatusFailed
+		}
+
+		r.Save(&Job{ID: strconv.Itoa(i), Status: s, Added: now.Add(time.Duration(-i) * time.Minute)})
+	}
+
+	js, more, err := r.List(Filter{}, 200, 20)
+	assert.Nil(t, err)
+	assert.True(t, more)
+	assert.Len(t, js, 20)
+	assert.Equal(t, "200", js[0].ID)
+
+	js, more, err = r.List(Filter{}, 240, 20)
+	assert.Nil(t, err)
+	assert.False(t, more)
+	assert.Len(t, js, 10)
+
+	js, more, err = r.List(Filter{Status: StatusFinished}, 110, 10)
+	assert.Nil(t, err)
+	assert.True(t, more)
+	assert.Len(t, js, 10)
+	assert.Equal(t, "221", js[0].ID)
+}
+
+func TestRedisListSkipsExpiredJobs(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	mr.Del(r.jobKey("2"))
+
+	js, _, err := r.List(Filter{Owner: "a"}, 0, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, js, 1)
+	assert.Equal(t, "1", js[0].ID)
+}
+
+func TestRedisFindSimilarReturnsClosestFinishedJob(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	r.Save(&Job{ID: "4", Status: StatusFinished, PHash: 0xff})
+	r.Save(&Job{ID: "5", Status: StatusFinished, PHash: 0xfe})
+	r.Save(&Job{ID: "6", Status: StatusFailed, PHash: 0xf0})
+	r.Save(&Job{ID: "7", Status: StatusFinished, PHash: 0xf0, AliasOf: "4"})
+
+	j, err := r.FindSimilar(0xf0, "", nil, 4)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "5", j.ID)
+}
+
+func TestRedisFindSimilarMatchesOwner(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	r.Save(&Job{ID: "4", Owner: "tenant1", Status: StatusFinished, PHash: 0xff})
+
+	_, err := r.FindSimilar(0xff, "tenant2", nil, 4)
+	assert.Equal(t, ErrNotFound, err)
+
+	j, err := r.FindSimilar(0xff, "tenant1", nil, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, "4", j.ID)
+}
+
+func TestRedisFindSimilarRemovesExpiredJobs(t *testing.T) {
+	r, mr := setupRedis(t)
+	defer mr.Close()
+
+	r.Save(&Job{ID: "4", Status: StatusFinished, PHash: 0xff})
+	mr.Del(r.jobKey("4"))
+
+	_, err := r.FindSimilar(0xff, "", nil, 4)
+
+	assert.Equal(t, ErrNotFound, err)
+	assert.False(t, mr.Exists(r.hashKey("", nil)))
+}
@@ -20,6 +20,9 @@ type Logger interface {
 	// gRPC Endpoint logging
 	Create(string) Finished
 	Query(string) Finished
+	GetImage(string) Finished
+	ListJobs(owner string) Finished
+	ListPacks() Finished
 
 	// Cache Operations
 	CacheExists(string) Finished
@@ -29,11 +32,16 @@ type Logger interface {
 	QueueGet(string) Finished
 	QueuePut(string) Finished
 
+	// Job Store Operations
+	JobSave(id string) Finished
+
 	// Emojify Worker
 	WorkerProcessQueueItem(*queue.Item) Finished
 	WorkerQueueStatus(items int)
 	WorkerFetchImage(uri string) Finished
 	WorkerInvalidImage(uri string, err error)
+	WorkerPermanentFailure(uri, reason string, err error)
+	WorkerDuplicateImage(uri, id string)
 	WorkerFindFaces(uri string) Finished
 	WorkerEmojify(uri string) Finished
 	WorkerImageEncodeError(uri string, err error)
@@ -110,6 +118,57 @@ func (i *Impl) Query(key string) Finished {
 
 }
 
+// GetImage logs timing information related to the gRPC GetImage method
+func (i *Impl) GetImage(key string) Finished {
+	st := time.Now()
+	i.l.Debug("GetImage called", "key", key)
+
+	return func(status int, err error) {
+		i.s.Timing(statsPrefix+".get_image", time.Now().Sub(st), getStatusTags(status), 1)
+
+		if err != nil {
+			i.l.Error("GetImage error", "key", key, "status", status, "error", err)
+			return
+		}
+
+		i.l.Debug("GetImage finished", "key", key, "status", status)
+	}
+}
+
+// ListJobs logs timing information related to the gRPC ListJobs method
+func (i *Impl) ListJobs(owner string) Finished {
+	st := time.Now()
+	i.l.Debug("ListJobs called", "owner", owner)
+
+	return func(status int, err error) {
+		i.s.Timing(statsPrefix+".list_jobs", time.Now().Sub(st), getStatusTags(status), 1)
+
+		if err != nil {
+			i.l.Error("ListJobs error", "owner", owner, "status", status, "error", err)
+			return
+		}
+
+		i.l.Debug("ListJobs finished", "owner", owner, "status", status)
+	}
+}
+
+// ListPacks logs timing information related to the gRPC ListPacks method
+func (i *Impl) ListPacks() Finished {
+	st := time.Now()
+	i.l.Debug("ListPacks called")
+
+	return func(status int, err error) {
+		i.s.Timing(statsPrefix+".list_packs", time.Now().Sub(st), getStatusTags(status), 1)
+
+		if err != nil {
+			i.l.Error("ListPacks error", "status", status, "error", err)
+			return
+		}
+
+		i.l.Debug("ListPacks finished", "status", status)
+	}
+}
+
 // CacheExists logs timing information related to Cache service exists method calls
 func (i *Impl) CacheExists(key string) Finished {
 	st := time.Now()
@@ -176,6 +235,23 @@ func (i *Impl) QueuePut(key string) Finished {
 	}
 }
 
+// JobSave logs timing information when a job record is written to the store
+func (i *Impl) JobSave(id string) Finished {
+	st := time.Now()
+	i.l.Debug("Job save called", "id", id)
+
+	return func(status int, err error) {
+		i.s.Timing(statsPrefix+"jobs.save", time.Now().Sub(st), getStatusTags(status), 1)
+
+		if err != nil {
+			i.l.Error("Job save error", "id", id, "status", status, "error", err)
+			return
+		}
+
+		i.l.Debug("Job save finished", "id", id, "status", status)
+	}
+}
+
 // WorkerProcessQueueItem logs information about the processing of a queue item
 func (i *Impl) WorkerProcessQueueItem(item *queue.Item) Finished {
 	st := time.Now()
@@ -212,6 +288,18 @@ func (i *Impl) WorkerInvalidImage(uri string, err error) {
 	i.s.Incr(statsPrefix+"worker.invalid_image", nil, 1)
 }
 
+// WorkerPermanentFailure logs information when an item fails and will not succeed if retried
+func (i *Impl) WorkerPermanentFailure(uri, reason string, err error) {
+	i.l.Error("Permanent failure processing item", "uri", uri, "reason", reason, "error", err)
+	i.s.Incr(statsPrefix+"worker.permanent_failure", []string{"reason:" + reason}, 1)
+}
+
+// WorkerDuplicateImage logs information when an image has already been processed at another url
+func (i *Impl) WorkerDuplicateImage(uri, id string) {
+	i.l.Debug("Found duplicate image", "uri", uri, "id", id)
+	i.s.Incr(statsPrefix+"worker.duplicate_image", nil, 1)
+}
+
 // WorkerFindFaces logs information related to the face lookup call
 func (i *Impl) WorkerFindFaces(uri string) Finished {
 	st := time.Now()
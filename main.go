@@ -1,21 +1,30 @@
 package main
 
 import (
+	"crypto"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/emojify-app/cache/protos/cache"
+	"github.com/emojify-app/emojify/auth"
 	"github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/jobs"
 	"github.com/emojify-app/emojify/logging"
 	"github.com/emojify-app/emojify/queue"
 	"github.com/emojify-app/emojify/server"
+	"github.com/emojify-app/emojify/tlsutil"
+	"github.com/emojify-app/emojify/webhooks"
 	"github.com/emojify-app/emojify/workers"
-	"github.com/emojify-app/face-detection/client"
+	"github.com/hashicorp/go-hclog"
 	"github.com/nicholasjackson/env"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var version = "dev"
@@ -23,20 +32,94 @@ var version = "dev"
 var envBindAddress = env.String("BIND_ADDRESS", false, "localhost", "Bind address for gRPC server, e.g. 127.0.0.1")
 var envBindPort = env.Integer("BIND_PORT", false, 9090, "Bind port for gRPC server e.g. 9090")
 
-var envHealthBindAddress = env.String("HEALTH_BIND_ADDRESS", false, "localhost", "Bind address for health endpoint, e.g. 127.0.0.1")
-var envHealthBindPort = env.Integer("HEALTH_BIND_PORT", false, 9091, "Bind port for health endpoint e.g. 9091")
+var envHealthBindAddress = env.String("HEALTH_BIND_ADDRESS", false, "localhost", "Bind address for the HTTP health endpoint and REST API, e.g. 127.0.0.1")
+var envHealthBindPort = env.Integer("HEALTH_BIND_PORT", false, 9091, "Bind port for the HTTP health endpoint and REST API e.g. 9091")
 
 var redisAddress = env.String("REDIS_ADDRESS", false, "localhost:6379", "Address for redis server")
 var redisPassword = env.String("REDIS_PASSWORD", false, "", "Password for redis server")
 var redisDB = env.Integer("REDIS_DB", false, 0, "Database for redis server")
 
+var jobStore = env.String("JOB_STORE", false, "redis", "Storage for job records [redis,memory]")
+var jobRetention = env.Duration("JOB_RETENTION", false, "168h", "Length of time job records are kept in the redis job store")
+
+var webhookSecret = env.String("WEBHOOK_SECRET", false, "", "Secret used to sign webhook payloads with HMAC-SHA256, requests with a callbackUrl are rejected when not set")
+var webhookAttempts = env.Integer("WEBHOOK_ATTEMPTS", false, 5, "Maximum number of attempts to deliver a webhook")
+var webhookBackoff = env.Duration("WEBHOOK_BACKOFF", false, "1s", "Delay before the first webhook retry, doubled for each subsequent attempt")
+var webhookTestMode = env.Bool("WEBHOOK_TEST_MODE", false, false, "Serve a webhook receiver at /webhooks/test on the health endpoint which logs deliveries, callbacks can be sent to the health endpoint")
+var webhookAllowPrivate = env.Bool("WEBHOOK_ALLOW_PRIVATE_IPS", false, false, "Allow callbacks to loopback, private and link local addresses")
+
+var tlsCertFile = env.String("TLS_CERT_FILE", false, "", "Certificate for the gRPC server, enables TLS when set")
+var tlsKeyFile = env.String("TLS_KEY_FILE", false, "", "Private key for the gRPC server certificate")
+var tlsClientCAFile = env.String("TLS_CLIENT_CA_FILE", false, "", "CA used to verify client certificates, enables mutual TLS when set")
+var tlsAllowedIdentities = env.String("TLS_ALLOWED_IDENTITIES", false, "", "Comma separated list of client certificate identities (CN, DNS, URI or email) allowed to connect, empty allows any client signed by the CA")
+var tlsReloadInterval = env.Duration("TLS_RELOAD_INTERVAL", false, "1m", "Interval to check certificate files for changes")
+
+var authAPIKeyFile = env.String("AUTH_API_KEY_FILE", false, "", "JSON file containing SHA-256 hashed API keys, enables API key authentication when set")
+var authJWTKeyFile = env.String("AUTH_JWT_KEY_FILE", false, "", "PEM public key used to verify JWTs, enables JWT authentication when set")
+var authJWKSFile = env.String("AUTH_JWKS_FILE", false, "", "JWKS file containing the keys used to verify JWTs, enables JWT authentication when set")
+var authJWTIssuer = env.String("AUTH_JWT_ISSUER", false, "", "Required issuer claim for JWTs")
+var authJWTAudience = env.String("AUTH_JWT_AUDIENCE", false, "", "Required audience claim for JWTs")
+
 var cacheAddress = env.String("CACHE_ADDRESS", false, "localhost:8000", "Address for cache server")
+var cacheTLSCAFile = env.String("CACHE_TLS_CA_FILE", false, "", "CA used to verify the cache server, enables TLS for the cache client when set")
+var cacheTLSCertFile = env.String("CACHE_TLS_CERT_FILE", false, "", "Client certificate presented to the cache server")
+var cacheTLSKeyFile = env.String("CACHE_TLS_KEY_FILE", false, "", "Private key for the cache client certificate")
+var cacheTLSServerName = env.String("CACHE_TLS_SERVER_NAME", false, "", "Name used to verify the cache server certificate, defaults to the host in CACHE_ADDRESS")
+
+var fetchSchemes = env.String("FETCH_ALLOWED_SCHEMES", false, "http,https", "Comma separated list of URL schemes which can be fetched [http,https,data,file,s3]")
+var fetchAllowDomains = env.String("FETCH_ALLOW_DOMAINS", false, "", "Comma separated list of domains images can be fetched from, empty allows all domains")
+var fetchDenyDomains = env.String("FETCH_DENY_DOMAINS", false, "", "Comma separated list of domains images can not be fetched from")
+var fetchAllowPrivate = env.Bool("FETCH_ALLOW_PRIVATE_IPS", false, false, "Allow fetching images from loopback, private and link local addresses")
+var fetchMaxRedirects = env.Integer("FETCH_MAX_REDIRECTS", false, 5, "Maximum number of redirects followed when fetching an image")
+
+var fetchMaxSize = env.Integer("FETCH_MAX_SIZE", false, emojify.MaxFileSize, "Maximum size in bytes of an image which can be downloaded")
+var fetchRetryAttempts = env.Integer("FETCH_RETRY_ATTEMPTS", false, 3, "Maximum number of attempts to download an image when the request fails with a transient error")
+var fetchRetryBackoff = env.Duration("FETCH_RETRY_BACKOFF", false, "500ms", "Delay before the first download retry, doubled for each subsequent attempt")
+var fetchCacheDir = env.String("FETCH_CACHE_DIR", false, "", "Directory used to cache downloaded images, cached images are revalidated using ETag and Last-Modified, empty disables the cache")
+var fetchCacheMaxSize = env.Integer("FETCH_CACHE_MAX_SIZE", false, 1<<30, "Maximum size in bytes of the download cache, the least recently used images are removed when it is larger, 0 is unlimited")
+var fetchHostConcurrency = env.Integer("FETCH_HOST_CONCURRENCY", false, 4, "Maximum number of concurrent downloads from a single host, 0 is unlimited")
+var fetchHostRate = env.Float("FETCH_HOST_RATE", false, 10, "Maximum number of requests per second to a single host, 0 is unlimited")
+var fetchFileRoot = env.String("FETCH_FILE_ROOT", false, "", "Directory file:// URLs are read from, file URLs are not supported when empty")
+var s3Endpoint = env.String("S3_ENDPOINT", false, "", "Endpoint for the S3 compatible object store used for s3:// URLs, e.g. https://s3.eu-west-1.amazonaws.com")
+var s3Region = env.String("S3_REGION", false, "us-east-1", "Region for the S3 compatible object store")
+var s3AccessKey = env.String("S3_ACCESS_KEY_ID", false, "", "Access key for the S3 compatible object store")
+var s3SecretKey = env.String("S3_SECRET_ACCESS_KEY", false, "", "Secret key for the S3 compatible object store")
+var s3Buckets = env.String("S3_BUCKETS", false, "", "Comma separated list of buckets s3:// URLs can fetch objects from, required when S3_ENDPOINT is set")
 
 var faceboxAddress = env.String("FACEBOX_ADDRESS", false, "localhost:8001", "Address for facebox server")
 
 var statsDAddress = env.String("STATSD_ADDRESS", false, "localhost:8125", "Address for statsd server")
 var logLevel = env.String("LOG_LEVEL", false, "info", "Level for log output [info,debug,trace,error]")
 
+var legacyIDLookup = env.Bool("LEGACY_ID_LOOKUP", false, true, "Resolve base64 URL IDs issued before content hashed IDs, disable once clients have migrated")
+
+var dedupDistance = env.Integer("DEDUP_DISTANCE", false, 4, "Maximum Hamming distance between perceptual hashes for images to be treated as duplicates, -1 disables")
+
+var gifMaxFrames = env.Integer("GIF_MAX_FRAMES", false, 100, "Maximum number of frames in an animated GIF, animations with more frames fail, 0 only processes the first frame")
+var gifMaxPixels = env.Integer("GIF_MAX_PIXELS", false, 50000000, "Maximum width × height × frames of an animated GIF, larger animations fail")
+var gifTrackFaces = env.Bool("GIF_TRACK_FACES", false, true, "Track faces between the frames of an animated GIF so each face keeps the same emoji")
+
+var jpegQuality = env.Integer("OUTPUT_JPEG_QUALITY", false, 85, "Quality from 1 to 100 of JPEG output when a request does not set the quality option")
+
+var emojiPadding = env.Float("EMOJI_PADDING", false, 1.2, "Size of an emoji relative to the face it covers, values above 1 cover the hair and chin")
+
+var emojiSelection = env.String("EMOJI_SELECTION", false, "random", "Strategy used to choose emoji when a request does not set one [random,seeded,fixed,round-robin,same,expression]")
+var faceMode = env.String("FACE_MODE", false, emojify.ModeEmoji, "Mode used to replace faces when a request does not set one [emoji,blur,pixelate,fill]")
+
+var faceMinSize = env.Integer("FACE_MIN_SIZE", false, 8, "Smallest width and height in pixels of a detected face, smaller faces are not replaced with emoji, redaction modes redact every face")
+var faceMinRelativeSize = env.Float("FACE_MIN_RELATIVE_SIZE", false, 0.01, "Smallest width and height of a detected face as a fraction of the shorter side of the image")
+var faceMaxOverlap = env.Float("FACE_MAX_OVERLAP", false, 0.5, "Largest intersection over union of two detected faces before the smaller is ignored as a duplicate, redaction modes merge the faces, 0 keeps overlapping faces")
+var faceMaxFaces = env.Integer("FACE_MAX_FACES", false, 0, "Largest number of faces replaced with emoji in an image, the largest faces are kept, redaction modes redact every face, 0 is unlimited")
+var faceClamp = env.Bool("FACE_CLAMP", false, false, "Limit detected faces to the bounds of the image")
+
+var expressionTableFile = env.String("EXPRESSION_TABLE_FILE", false, "", "JSON file mapping expression labels to emoji codepoints, e.g. {\"happy\": [\"1f600\"]}")
+var packsDir = env.String("PACKS_DIR", false, "", "Directory containing a subdirectory for each named emoji pack, ./images/ is used as the default pack when empty")
+var packsDefault = env.String("PACKS_DEFAULT", false, emojify.DefaultPack, "Pack used when a request or tenant does not choose one")
+var packsTenants = env.String("PACKS_TENANTS", false, "", "Comma separated list of tenant=pack pairs setting the pack used for a tenant's requests")
+var packsReloadInterval = env.Duration("PACKS_RELOAD_INTERVAL", false, "30s", "Interval to check the packs directory for changes")
+
+var expressionMinConfidence = env.Float("EXPRESSION_MIN_CONFIDENCE", false, emojify.DefaultMinConfidence, "Confidence below which the expression strategy chooses a random emoji")
+
 var help = flag.Bool("help", false, "--help to show help")
 
 func main() {
@@ -66,35 +149,312 @@ func main() {
 		os.Exit(1)
 	}
 
-	conn, err := grpc.Dial(*cacheAddress, grpc.WithInsecure())
+	var js jobs.Store
+	switch *jobStore {
+	case "redis":
+		js = jobs.NewRedis(*redisAddress, *redisPassword, *redisDB, *jobRetention)
+	case "memory":
+		js = jobs.NewMemory()
+	default:
+		l.Log().Error("Unknown job store", "store", *jobStore)
+		os.Exit(1)
+	}
+
+	cacheCreds := grpc.WithInsecure()
+	if *cacheTLSCAFile != "" || *cacheTLSCertFile != "" {
+		ccfg, err := tlsutil.NewClient(*cacheTLSCAFile, *cacheTLSCertFile, *cacheTLSKeyFile, l.Log().Named("cache_tls"))
+		if err != nil {
+			l.Log().Error("Unable to load cache TLS config", "error", err)
+			os.Exit(1)
+		}
+		go ccfg.Watch(*tlsReloadInterval, nil)
+
+		cacheCreds = grpc.WithTransportCredentials(credentials.NewTLS(ccfg.ClientTLS(serverName(*cacheAddress, *cacheTLSServerName))))
+	}
+
+	conn, err := grpc.Dial(*cacheAddress, cacheCreds)
 	if err != nil {
 		l.Log().Error("Unable to create gRPC client", err)
 		os.Exit(1)
 	}
 	cc := cache.NewCacheClient(conn)
 
-	f := emojify.NewFetcher()
-	fd := client.NewClient(*faceboxAddress)
+	policy := emojify.URLPolicy{
+		Schemes:      splitList(*fetchSchemes),
+		AllowDomains: splitList(*fetchAllowDomains),
+		DenyDomains:  splitList(*fetchDenyDomains),
+		AllowPrivate: *fetchAllowPrivate,
+		MaxRedirects: *fetchMaxRedirects,
+	}
+
+	f := emojify.NewFetcher(policy, int64(*fetchMaxSize))
+	f.SetRetry(*fetchRetryAttempts, *fetchRetryBackoff)
+	f.SetHostLimits(*fetchHostConcurrency, *fetchHostRate)
+
+	if *fetchCacheDir != "" {
+		dc, err := emojify.NewDiskCache(*fetchCacheDir, int64(*fetchCacheMaxSize))
+		if err != nil {
+			l.Log().Error("Unable to create fetch cache", "error", err)
+			os.Exit(1)
+		}
+
+		f.SetCache(dc)
+	}
+
+	f.RegisterScheme("data", &emojify.DataHandler{})
+
+	if *fetchFileRoot != "" {
+		fh, err := emojify.NewFileHandler(*fetchFileRoot)
+		if err != nil {
+			l.Log().Error("Unable to use file root", "error", err)
+			os.Exit(1)
+		}
+
+		f.RegisterScheme("file", fh)
+	}
+
+	if *s3Endpoint != "" {
+		sh, err := emojify.NewS3Handler(*s3Endpoint, *s3Region, *s3AccessKey, *s3SecretKey, splitList(*s3Buckets))
+		if err != nil {
+			l.Log().Error("Unable to create S3 handler", "error", err)
+			os.Exit(1)
+		}
+
+		f.RegisterScheme("s3", sh)
+	}
+	// decodes landmarks so emoji can be rotated to match tilted heads
+	fd := emojify.NewFaceDetect(*faceboxAddress)
 	e, err := emojify.NewEmojify("./images/", fd)
 	if err != nil {
 		l.Log().Error("Unable to load emojies", err)
 		os.Exit(1)
 	}
+	e.SetPadding(*emojiPadding)
 
-	w := workers.New(q, cc, l, f, e, 30*time.Second, 100*time.Millisecond)
-	go w.Start() // start the worker and process queue items
+	if *packsDir != "" {
+		// reloads which remove the default pack are rejected
+		packs, err := emojify.NewPacks(*packsDir, *packsDefault, l.Log().Named("packs"))
+		if err != nil {
+			l.Log().Error("Unable to load emoji packs", "error", err)
+			os.Exit(1)
+		}
 
-	http.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
-		rw.WriteHeader(http.StatusOK)
+		go packs.Watch(*packsReloadInterval, nil)
+		e.SetPacks(packs, *packsDefault)
+		l.Log().Info("Loaded emoji packs", "dir", *packsDir, "packs", packs.Names())
+	}
+	e.SetTenantPacks(splitPairs(*packsTenants))
+	e.SetSelection(*emojiSelection)
+	e.SetMode(*faceMode)
+	e.SetFaceFilter(emojify.FaceFilter{
+		MinSize:         *faceMinSize,
+		MinRelativeSize: *faceMinRelativeSize,
+		MaxOverlap:      *faceMaxOverlap,
+		MaxFaces:        *faceMaxFaces,
+		Clamp:           *faceClamp,
 	})
+
+	table := emojify.DefaultExpressionTable()
+	if *expressionTableFile != "" {
+		table, err = emojify.LoadExpressionTable(*expressionTableFile)
+		if err != nil {
+			l.Log().Error("Unable to load expression table", "error", err)
+			os.Exit(1)
+		}
+	}
+	e.RegisterSelector(emojify.SelectExpression, emojify.NewExpressionSelector(emojify.NewMouthClassifier(), table, *expressionMinConfidence))
+
+	if !e.HasSelector(*emojiSelection) {
+		l.Log().Error("Unknown emoji selection strategy", "selection", *emojiSelection)
+		os.Exit(1)
+	}
+
+	if *faceMode != "" && !emojify.ValidMode(*faceMode) {
+		l.Log().Error("Unknown face mode", "mode", *faceMode)
+		os.Exit(1)
+	}
+
+	// callbacks have their own policy so the test receiver can be reached
+	// without allowing images to be fetched from private addresses
+	callbackPolicy := emojify.URLPolicy{
+		Schemes:      []string{"http", "https"},
+		AllowPrivate: *webhookAllowPrivate,
+	}
+
+	if *webhookTestMode {
+		callbackPolicy.AllowAddresses = listenerAddresses(*envHealthBindAddress, *envHealthBindPort)
+	}
+
+	wh := webhooks.NewDispatcher(*webhookSecret, *webhookAttempts, *webhookBackoff, webhooks.NewMemoryLog(1000), l.Log().Named("webhooks"))
+	wh.SetDialer(callbackPolicy.Dialer())
+
+	w := workers.New(q, cc, js, l, f, e, wh, 30*time.Second, 100*time.Millisecond)
+	if *dedupDistance >= 0 {
+		w.SetDedupDistance(*dedupDistance)
+	}
+	w.SetAnimation(*gifMaxFrames, *gifMaxPixels, *gifTrackFaces)
+	w.SetJPEGQuality(*jpegQuality)
+	go w.Start() // start the worker and process queue items
+
+	s := server.New(q, cc, js, l)
+	s.SetLegacyIDLookup(*legacyIDLookup)
+	s.SetPacks(e)
+	s.SetSelectionValidator(e)
+	s.SetCallbackPolicy(callbackPolicy)
+
+	// unsigned callbacks can not be verified by the receiver so callbacks
+	// are only accepted when a secret is configured
+	s.SetCallbacks(*webhookSecret != "")
+	if *webhookSecret == "" {
+		l.Log().Warn("WEBHOOK_SECRET is not set, requests with a callbackUrl will be rejected")
+	}
+
+	opts := []grpc.ServerOption{}
+
+	// the REST gateway shares the listener with the health check
+	var gw http.Handler = server.NewGateway(s)
+
+	a, err := newAuth(l.Log().Named("auth"))
+	if err != nil {
+		l.Log().Error("Unable to load authentication keys", "error", err)
+		os.Exit(1)
+	}
+
+	if a != nil {
+		opts = append(opts, grpc.UnaryInterceptor(a.UnaryInterceptor()), grpc.StreamInterceptor(a.StreamInterceptor()))
+		gw = a.Handler(gw)
+		l.Log().Info("Authentication enabled")
+	}
+
+	http.Handle("/health", gw)
+	http.Handle("/v1/", gw)
+
+	if *webhookTestMode {
+		http.Handle("/webhooks/test", webhooks.NewReceiver(*webhookSecret, l.Log().Named("webhook_receiver")))
+	}
+
 	go http.ListenAndServe(fmt.Sprintf("%s:%d", *envHealthBindAddress, *envHealthBindPort), nil)
 
 	l.Log().Info("Binding gRPC to", "address", *envBindAddress, "port", *envBindPort)
 	l.Log().Info("Starting gRPC server")
 
-	err = server.Start(*envBindAddress, *envBindPort, l, cc, q)
+	if *tlsCertFile != "" {
+		scfg, err := tlsutil.NewServer(*tlsCertFile, *tlsKeyFile, *tlsClientCAFile, splitList(*tlsAllowedIdentities), l.Log().Named("tls"))
+		if err != nil {
+			l.Log().Error("Unable to load TLS config", "error", err)
+			os.Exit(1)
+		}
+		go scfg.Watch(*tlsReloadInterval, nil)
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(scfg.ServerTLS())))
+		l.Log().Info("TLS enabled", "mutual", *tlsClientCAFile != "")
+	}
+
+	err = server.Start(*envBindAddress, *envBindPort, s, opts...)
 	if err != nil {
 		l.Log().Error("Unable to start server", "error", err)
 		os.Exit(1)
 	}
 }
+
+// newAuth creates the authentication interceptors from the configured key
+// files, returns nil when authentication is not configured
+func newAuth(l hclog.Logger) (*server.Auth, error) {
+	var keys, tokens auth.Authenticator
+
+	if *authAPIKeyFile != "" {
+		k, err := auth.LoadAPIKeys(*authAPIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = k
+	}
+
+	var pk map[string]crypto.PublicKey
+	var err error
+
+	switch {
+	case *authJWKSFile != "":
+		pk, err = auth.LoadJWKS(*authJWKSFile)
+	case *authJWTKeyFile != "":
+		pk, err = auth.LoadPublicKey(*authJWTKeyFile)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pk != nil {
+		tokens = auth.NewJWT(pk, *authJWTIssuer, *authJWTAudience)
+	}
+
+	if keys == nil && tokens == nil {
+		return nil, nil
+	}
+
+	return server.NewAuth(keys, tokens, l), nil
+}
+
+// serverName returns the name used to verify a server certificate, the
+// host part of the address is used when no name is configured
+func serverName(address, name string) string {
+	if name != "" {
+		return name
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	return host
+}
+
+// listenerAddresses returns the ip:port addresses a listener bound to host
+// can be reached at, listeners on every interface are reached on loopback
+func listenerAddresses(host string, port int) []string {
+	ips := []net.IP{}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		ips = append(ips, ip)
+	} else if ip != nil || host == "" {
+		ips = append(ips, net.IPv4(127, 0, 0, 1), net.IPv6loopback)
+	} else if resolved, err := net.LookupIP(host); err == nil {
+		ips = append(ips, resolved...)
+	}
+
+	addrs := []string{}
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	}
+
+	return addrs
+}
+
+// splitList splits a comma separated list ignoring empty elements
+func splitList(s string) []string {
+	l := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+
+	return l
+}
+
+// splitPairs splits a comma separated list of key=value pairs ignoring
+// elements without a value
+func splitPairs(s string) map[string]string {
+	m := map[string]string{}
+	for _, v := range splitList(s) {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			continue
+		}
+
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return m
+}
@@ -3,14 +3,14 @@
 
 package emojify
 
-import proto "github.com/golang/protobuf/proto"
-import fmt "fmt"
-import math "math"
-import wrappers "github.com/golang/protobuf/ptypes/wrappers"
-
 import (
-	context "golang.org/x/net/context"
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	grpc "google.golang.org/grpc"
+	math "math"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -22,7 +22,7 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type HealthCheckResponse_ServingStatus int32
 
@@ -37,6 +37,7 @@ var HealthCheckResponse_ServingStatus_name = map[int32]string{
 	1: "SERVING",
 	2: "NOT_SERVING",
 }
+
 var HealthCheckResponse_ServingStatus_value = map[string]int32{
 	"UNKNOWN":     0,
 	"SERVING":     1,
@@ -46,8 +47,9 @@ var HealthCheckResponse_ServingStatus_value = map[string]int32{
 func (x HealthCheckResponse_ServingStatus) String() string {
 	return proto.EnumName(HealthCheckResponse_ServingStatus_name, int32(x))
 }
+
 func (HealthCheckResponse_ServingStatus) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{1, 0}
+	return fileDescriptor_3b77b7a348ba4eca, []int{1, 0}
 }
 
 type QueryStatus_QueryStatus int32
@@ -57,6 +59,7 @@ const (
 	QueryStatus_QUEUED     QueryStatus_QueryStatus = 1
 	QueryStatus_FINISHED   QueryStatus_QueryStatus = 2
 	QueryStatus_PROCESSING QueryStatus_QueryStatus = 3
+	QueryStatus_FAILED     QueryStatus_QueryStatus = 4
 )
 
 var QueryStatus_QueryStatus_name = map[int32]string{
@@ -64,19 +67,23 @@ var QueryStatus_QueryStatus_name = map[int32]string{
 	1: "QUEUED",
 	2: "FINISHED",
 	3: "PROCESSING",
+	4: "FAILED",
 }
+
 var QueryStatus_QueryStatus_value = map[string]int32{
 	"UNKNOWN":    0,
 	"QUEUED":     1,
 	"FINISHED":   2,
 	"PROCESSING": 3,
+	"FAILED":     4,
 }
 
 func (x QueryStatus_QueryStatus) String() string {
 	return proto.EnumName(QueryStatus_QueryStatus_name, int32(x))
 }
+
 func (QueryStatus_QueryStatus) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{2, 0}
+	return fileDescriptor_3b77b7a348ba4eca, []int{2, 0}
 }
 
 type HealthCheckRequest struct {
@@ -90,16 +97,17 @@ func (m *HealthCheckRequest) Reset()         { *m = HealthCheckRequest{} }
 func (m *HealthCheckRequest) String() string { return proto.CompactTextString(m) }
 func (*HealthCheckRequest) ProtoMessage()    {}
 func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{0}
+	return fileDescriptor_3b77b7a348ba4eca, []int{0}
 }
+
 func (m *HealthCheckRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_HealthCheckRequest.Unmarshal(m, b)
 }
 func (m *HealthCheckRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_HealthCheckRequest.Marshal(b, m, deterministic)
 }
-func (dst *HealthCheckRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_HealthCheckRequest.Merge(dst, src)
+func (m *HealthCheckRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HealthCheckRequest.Merge(m, src)
 }
 func (m *HealthCheckRequest) XXX_Size() int {
 	return xxx_messageInfo_HealthCheckRequest.Size(m)
@@ -128,16 +136,17 @@ func (m *HealthCheckResponse) Reset()         { *m = HealthCheckResponse{} }
 func (m *HealthCheckResponse) String() string { return proto.CompactTextString(m) }
 func (*HealthCheckResponse) ProtoMessage()    {}
 func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{1}
+	return fileDescriptor_3b77b7a348ba4eca, []int{1}
 }
+
 func (m *HealthCheckResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_HealthCheckResponse.Unmarshal(m, b)
 }
 func (m *HealthCheckResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_HealthCheckResponse.Marshal(b, m, deterministic)
 }
-func (dst *HealthCheckResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_HealthCheckResponse.Merge(dst, src)
+func (m *HealthCheckResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HealthCheckResponse.Merge(m, src)
 }
 func (m *HealthCheckResponse) XXX_Size() int {
 	return xxx_messageInfo_HealthCheckResponse.Size(m)
@@ -166,16 +175,17 @@ func (m *QueryStatus) Reset()         { *m = QueryStatus{} }
 func (m *QueryStatus) String() string { return proto.CompactTextString(m) }
 func (*QueryStatus) ProtoMessage()    {}
 func (*QueryStatus) Descriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{2}
+	return fileDescriptor_3b77b7a348ba4eca, []int{2}
 }
+
 func (m *QueryStatus) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_QueryStatus.Unmarshal(m, b)
 }
 func (m *QueryStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_QueryStatus.Marshal(b, m, deterministic)
 }
-func (dst *QueryStatus) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_QueryStatus.Merge(dst, src)
+func (m *QueryStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryStatus.Merge(m, src)
 }
 func (m *QueryStatus) XXX_Size() int {
 	return xxx_messageInfo_QueryStatus.Size(m)
@@ -194,29 +204,37 @@ func (m *QueryStatus) GetStatus() QueryStatus_QueryStatus {
 }
 
 type QueryItem struct {
-	Id                   string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	QueuePosition        int32        `protobuf:"varint,2,opt,name=queuePosition,proto3" json:"queuePosition,omitempty"`
-	QueueLength          int32        `protobuf:"varint,3,opt,name=queueLength,proto3" json:"queueLength,omitempty"`
-	Status               *QueryStatus `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	Id            string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	QueuePosition int32        `protobuf:"varint,2,opt,name=queuePosition,proto3" json:"queuePosition,omitempty"`
+	QueueLength   int32        `protobuf:"varint,3,opt,name=queueLength,proto3" json:"queueLength,omitempty"`
+	Status        *QueryStatus `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	// error is set when the status is FAILED
+	Error string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	// retryable is false when the request failed permanently and
+	// resubmitting the same request will fail again
+	Retryable bool `protobuf:"varint,6,opt,name=retryable,proto3" json:"retryable,omitempty"`
+	// reason is a code identifying the cause of a failure, e.g. TOO_LARGE
+	Reason               string   `protobuf:"bytes,7,opt,name=reason,proto3" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *QueryItem) Reset()         { *m = QueryItem{} }
 func (m *QueryItem) String() string { return proto.CompactTextString(m) }
 func (*QueryItem) ProtoMessage()    {}
 func (*QueryItem) Descriptor() ([]byte, []int) {
-	return fileDescriptor_emojify_66182d6044d336b3, []int{3}
+	return fileDescriptor_3b77b7a348ba4eca, []int{3}
 }
+
 func (m *QueryItem) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_QueryItem.Unmarshal(m, b)
 }
 func (m *QueryItem) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	return xxx_messageInfo_QueryItem.Marshal(b, m, deterministic)
 }
-func (dst *QueryItem) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_QueryItem.Merge(dst, src)
+func (m *QueryItem) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryItem.Merge(m, src)
 }
 func (m *QueryItem) XXX_Size() int {
 	return xxx_messageInfo_QueryItem.Size(m)
@@ -255,13 +273,661 @@ func (m *QueryItem) GetStatus() *QueryStatus {
 	return nil
 }
 
+func (m *QueryItem) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *QueryItem) GetRetryable() bool {
+	if m != nil {
+		return m.Retryable
+	}
+	return false
+}
+
+func (m *QueryItem) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+// CreateRequest is wire compatible with google.protobuf.StringValue
+// so older clients which only send the uri continue to work
+type CreateRequest struct {
+	Uri     string            `protobuf:"bytes,1,opt,name=uri,proto3" json:"uri,omitempty"`
+	Owner   string            `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	Options map[string]string `protobuf:"bytes,3,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// callbackUrl receives a signed POST of the final QueryItem when
+	// processing finishes or fails, requests with a callbackUrl for an item
+	// which is already queued or finished fail with ALREADY_EXISTS
+	CallbackUrl          string   `protobuf:"bytes,4,opt,name=callbackUrl,proto3" json:"callbackUrl,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateRequest) Reset()         { *m = CreateRequest{} }
+func (m *CreateRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateRequest) ProtoMessage()    {}
+func (*CreateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{4}
+}
+
+func (m *CreateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateRequest.Unmarshal(m, b)
+}
+func (m *CreateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateRequest.Merge(m, src)
+}
+func (m *CreateRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateRequest.Size(m)
+}
+func (m *CreateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateRequest proto.InternalMessageInfo
+
+func (m *CreateRequest) GetUri() string {
+	if m != nil {
+		return m.Uri
+	}
+	return ""
+}
+
+func (m *CreateRequest) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *CreateRequest) GetOptions() map[string]string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *CreateRequest) GetCallbackUrl() string {
+	if m != nil {
+		return m.CallbackUrl
+	}
+	return ""
+}
+
+type Job struct {
+	Id          string                  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Uri         string                  `protobuf:"bytes,2,opt,name=uri,proto3" json:"uri,omitempty"`
+	Owner       string                  `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	Options     map[string]string       `protobuf:"bytes,4,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Added       *timestamp.Timestamp    `protobuf:"bytes,5,opt,name=added,proto3" json:"added,omitempty"`
+	Updated     *timestamp.Timestamp    `protobuf:"bytes,6,opt,name=updated,proto3" json:"updated,omitempty"`
+	Completed   *timestamp.Timestamp    `protobuf:"bytes,7,opt,name=completed,proto3" json:"completed,omitempty"`
+	Status      QueryStatus_QueryStatus `protobuf:"varint,8,opt,name=status,proto3,enum=emojify.QueryStatus_QueryStatus" json:"status,omitempty"`
+	Error       string                  `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	FaceCount   int32                   `protobuf:"varint,10,opt,name=faceCount,proto3" json:"faceCount,omitempty"`
+	CallbackUrl string                  `protobuf:"bytes,11,opt,name=callbackUrl,proto3" json:"callbackUrl,omitempty"`
+	Retryable   bool                    `protobuf:"varint,12,opt,name=retryable,proto3" json:"retryable,omitempty"`
+	Reason      string                  `protobuf:"bytes,13,opt,name=reason,proto3" json:"reason,omitempty"`
+	// inputFormat is the format of the source image, e.g. jpeg or webp
+	InputFormat string `protobuf:"bytes,14,opt,name=inputFormat,proto3" json:"inputFormat,omitempty"`
+	// contentType of the output image, e.g. image/png
+	ContentType          string   `protobuf:"bytes,15,opt,name=contentType,proto3" json:"contentType,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Job) Reset()         { *m = Job{} }
+func (m *Job) String() string { return proto.CompactTextString(m) }
+func (*Job) ProtoMessage()    {}
+func (*Job) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{5}
+}
+
+func (m *Job) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Job.Unmarshal(m, b)
+}
+func (m *Job) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Job.Marshal(b, m, deterministic)
+}
+func (m *Job) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Job.Merge(m, src)
+}
+func (m *Job) XXX_Size() int {
+	return xxx_messageInfo_Job.Size(m)
+}
+func (m *Job) XXX_DiscardUnknown() {
+	xxx_messageInfo_Job.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Job proto.InternalMessageInfo
+
+func (m *Job) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Job) GetUri() string {
+	if m != nil {
+		return m.Uri
+	}
+	return ""
+}
+
+func (m *Job) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *Job) GetOptions() map[string]string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *Job) GetAdded() *timestamp.Timestamp {
+	if m != nil {
+		return m.Added
+	}
+	return nil
+}
+
+func (m *Job) GetUpdated() *timestamp.Timestamp {
+	if m != nil {
+		return m.Updated
+	}
+	return nil
+}
+
+func (m *Job) GetCompleted() *timestamp.Timestamp {
+	if m != nil {
+		return m.Completed
+	}
+	return nil
+}
+
+func (m *Job) GetStatus() QueryStatus_QueryStatus {
+	if m != nil {
+		return m.Status
+	}
+	return QueryStatus_UNKNOWN
+}
+
+func (m *Job) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *Job) GetFaceCount() int32 {
+	if m != nil {
+		return m.FaceCount
+	}
+	return 0
+}
+
+func (m *Job) GetCallbackUrl() string {
+	if m != nil {
+		return m.CallbackUrl
+	}
+	return ""
+}
+
+func (m *Job) GetRetryable() bool {
+	if m != nil {
+		return m.Retryable
+	}
+	return false
+}
+
+func (m *Job) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *Job) GetInputFormat() string {
+	if m != nil {
+		return m.InputFormat
+	}
+	return ""
+}
+
+func (m *Job) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+type Image struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	ContentType          string   `protobuf:"bytes,3,opt,name=contentType,proto3" json:"contentType,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Image) Reset()         { *m = Image{} }
+func (m *Image) String() string { return proto.CompactTextString(m) }
+func (*Image) ProtoMessage()    {}
+func (*Image) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{6}
+}
+
+func (m *Image) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Image.Unmarshal(m, b)
+}
+func (m *Image) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Image.Marshal(b, m, deterministic)
+}
+func (m *Image) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Image.Merge(m, src)
+}
+func (m *Image) XXX_Size() int {
+	return xxx_messageInfo_Image.Size(m)
+}
+func (m *Image) XXX_DiscardUnknown() {
+	xxx_messageInfo_Image.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Image proto.InternalMessageInfo
+
+func (m *Image) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Image) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Image) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+type ListJobsRequest struct {
+	// filters, empty values match all jobs
+	Owner                string                  `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	Status               QueryStatus_QueryStatus `protobuf:"varint,2,opt,name=status,proto3,enum=emojify.QueryStatus_QueryStatus" json:"status,omitempty"`
+	AddedAfter           *timestamp.Timestamp    `protobuf:"bytes,3,opt,name=addedAfter,proto3" json:"addedAfter,omitempty"`
+	AddedBefore          *timestamp.Timestamp    `protobuf:"bytes,4,opt,name=addedBefore,proto3" json:"addedBefore,omitempty"`
+	PageSize             int32                   `protobuf:"varint,5,opt,name=pageSize,proto3" json:"pageSize,omitempty"`
+	PageToken            string                  `protobuf:"bytes,6,opt,name=pageToken,proto3" json:"pageToken,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_unrecognized     []byte                  `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
+}
+
+func (m *ListJobsRequest) Reset()         { *m = ListJobsRequest{} }
+func (m *ListJobsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListJobsRequest) ProtoMessage()    {}
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{7}
+}
+
+func (m *ListJobsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListJobsRequest.Unmarshal(m, b)
+}
+func (m *ListJobsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListJobsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListJobsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListJobsRequest.Merge(m, src)
+}
+func (m *ListJobsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListJobsRequest.Size(m)
+}
+func (m *ListJobsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListJobsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListJobsRequest proto.InternalMessageInfo
+
+func (m *ListJobsRequest) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *ListJobsRequest) GetStatus() QueryStatus_QueryStatus {
+	if m != nil {
+		return m.Status
+	}
+	return QueryStatus_UNKNOWN
+}
+
+func (m *ListJobsRequest) GetAddedAfter() *timestamp.Timestamp {
+	if m != nil {
+		return m.AddedAfter
+	}
+	return nil
+}
+
+func (m *ListJobsRequest) GetAddedBefore() *timestamp.Timestamp {
+	if m != nil {
+		return m.AddedBefore
+	}
+	return nil
+}
+
+func (m *ListJobsRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListJobsRequest) GetPageToken() string {
+	if m != nil {
+		return m.PageToken
+	}
+	return ""
+}
+
+type ListJobsResponse struct {
+	Jobs                 []*Job   `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	NextPageToken        string   `protobuf:"bytes,2,opt,name=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListJobsResponse) Reset()         { *m = ListJobsResponse{} }
+func (m *ListJobsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListJobsResponse) ProtoMessage()    {}
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{8}
+}
+
+func (m *ListJobsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListJobsResponse.Unmarshal(m, b)
+}
+func (m *ListJobsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListJobsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListJobsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListJobsResponse.Merge(m, src)
+}
+func (m *ListJobsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListJobsResponse.Size(m)
+}
+func (m *ListJobsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListJobsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListJobsResponse proto.InternalMessageInfo
+
+func (m *ListJobsResponse) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+func (m *ListJobsResponse) GetNextPageToken() string {
+	if m != nil {
+		return m.NextPageToken
+	}
+	return ""
+}
+
+type ListPacksRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPacksRequest) Reset()         { *m = ListPacksRequest{} }
+func (m *ListPacksRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPacksRequest) ProtoMessage()    {}
+func (*ListPacksRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{9}
+}
+
+func (m *ListPacksRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPacksRequest.Unmarshal(m, b)
+}
+func (m *ListPacksRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPacksRequest.Marshal(b, m, deterministic)
+}
+func (m *ListPacksRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPacksRequest.Merge(m, src)
+}
+func (m *ListPacksRequest) XXX_Size() int {
+	return xxx_messageInfo_ListPacksRequest.Size(m)
+}
+func (m *ListPacksRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPacksRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPacksRequest proto.InternalMessageInfo
+
+// Pack is an emoji pack which requests can select with the pack option
+type Pack struct {
+	Name       string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	EmojiCount int32  `protobuf:"varint,2,opt,name=emojiCount,proto3" json:"emojiCount,omitempty"`
+	Licence    string `protobuf:"bytes,3,opt,name=licence,proto3" json:"licence,omitempty"`
+	// tags used by emoji in the pack, requests can limit selection to a tag
+	Tags []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// isDefault is true for the pack used when a request does not choose one
+	IsDefault            bool     `protobuf:"varint,5,opt,name=isDefault,proto3" json:"isDefault,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Pack) Reset()         { *m = Pack{} }
+func (m *Pack) String() string { return proto.CompactTextString(m) }
+func (*Pack) ProtoMessage()    {}
+func (*Pack) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{10}
+}
+
+func (m *Pack) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Pack.Unmarshal(m, b)
+}
+func (m *Pack) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Pack.Marshal(b, m, deterministic)
+}
+func (m *Pack) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Pack.Merge(m, src)
+}
+func (m *Pack) XXX_Size() int {
+	return xxx_messageInfo_Pack.Size(m)
+}
+func (m *Pack) XXX_DiscardUnknown() {
+	xxx_messageInfo_Pack.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Pack proto.InternalMessageInfo
+
+func (m *Pack) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Pack) GetEmojiCount() int32 {
+	if m != nil {
+		return m.EmojiCount
+	}
+	return 0
+}
+
+func (m *Pack) GetLicence() string {
+	if m != nil {
+		return m.Licence
+	}
+	return ""
+}
+
+func (m *Pack) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Pack) GetIsDefault() bool {
+	if m != nil {
+		return m.IsDefault
+	}
+	return false
+}
+
+type ListPacksResponse struct {
+	Packs                []*Pack  `protobuf:"bytes,1,rep,name=packs,proto3" json:"packs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPacksResponse) Reset()         { *m = ListPacksResponse{} }
+func (m *ListPacksResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPacksResponse) ProtoMessage()    {}
+func (*ListPacksResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_3b77b7a348ba4eca, []int{11}
+}
+
+func (m *ListPacksResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPacksResponse.Unmarshal(m, b)
+}
+func (m *ListPacksResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPacksResponse.Marshal(b, m, deterministic)
+}
+func (m *ListPacksResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPacksResponse.Merge(m, src)
+}
+func (m *ListPacksResponse) XXX_Size() int {
+	return xxx_messageInfo_ListPacksResponse.Size(m)
+}
+func (m *ListPacksResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPacksResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPacksResponse proto.InternalMessageInfo
+
+func (m *ListPacksResponse) GetPacks() []*Pack {
+	if m != nil {
+		return m.Packs
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("emojify.HealthCheckResponse_ServingStatus", HealthCheckResponse_ServingStatus_name, HealthCheckResponse_ServingStatus_value)
+	proto.RegisterEnum("emojify.QueryStatus_QueryStatus", QueryStatus_QueryStatus_name, QueryStatus_QueryStatus_value)
 	proto.RegisterType((*HealthCheckRequest)(nil), "emojify.HealthCheckRequest")
 	proto.RegisterType((*HealthCheckResponse)(nil), "emojify.HealthCheckResponse")
 	proto.RegisterType((*QueryStatus)(nil), "emojify.QueryStatus")
 	proto.RegisterType((*QueryItem)(nil), "emojify.QueryItem")
-	proto.RegisterEnum("emojify.HealthCheckResponse_ServingStatus", HealthCheckResponse_ServingStatus_name, HealthCheckResponse_ServingStatus_value)
-	proto.RegisterEnum("emojify.QueryStatus_QueryStatus", QueryStatus_QueryStatus_name, QueryStatus_QueryStatus_value)
+	proto.RegisterType((*CreateRequest)(nil), "emojify.CreateRequest")
+	proto.RegisterMapType((map[string]string)(nil), "emojify.CreateRequest.OptionsEntry")
+	proto.RegisterType((*Job)(nil), "emojify.Job")
+	proto.RegisterMapType((map[string]string)(nil), "emojify.Job.OptionsEntry")
+	proto.RegisterType((*Image)(nil), "emojify.Image")
+	proto.RegisterType((*ListJobsRequest)(nil), "emojify.ListJobsRequest")
+	proto.RegisterType((*ListJobsResponse)(nil), "emojify.ListJobsResponse")
+	proto.RegisterType((*ListPacksRequest)(nil), "emojify.ListPacksRequest")
+	proto.RegisterType((*Pack)(nil), "emojify.Pack")
+	proto.RegisterType((*ListPacksResponse)(nil), "emojify.ListPacksResponse")
+}
+
+func init() { proto.RegisterFile("emojify.proto", fileDescriptor_3b77b7a348ba4eca) }
+
+var fileDescriptor_3b77b7a348ba4eca = []byte{
+	// 1016 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x36, 0xf5, 0xcf, 0x91, 0xe5, 0xa8, 0xdb, 0x20, 0x60, 0x58, 0x23, 0x15, 0x98, 0x1e, 0x8c,
+	0xa2, 0x50, 0x0a, 0xa7, 0x28, 0x0c, 0xb5, 0x3d, 0xd8, 0x96, 0x9c, 0xc8, 0x75, 0x65, 0x85, 0xb2,
+	0x53, 0xa0, 0x97, 0x62, 0x25, 0x8d, 0x64, 0x46, 0x14, 0x97, 0x21, 0x97, 0x49, 0xd5, 0x07, 0x68,
+	0x9f, 0x20, 0x87, 0xbe, 0x57, 0x4f, 0x05, 0xfa, 0x2e, 0xc5, 0x2e, 0xff, 0x25, 0xd9, 0x46, 0xd0,
+	0xdb, 0xce, 0xb7, 0x33, 0xb3, 0xf3, 0xed, 0x7c, 0xb3, 0x0b, 0x0d, 0x5c, 0xb2, 0x37, 0xd6, 0x6c,
+	0xd5, 0x76, 0x3d, 0xc6, 0x19, 0xa9, 0x46, 0xa6, 0xfe, 0x64, 0xce, 0xd8, 0xdc, 0xc6, 0x67, 0x12,
+	0x1e, 0x07, 0xb3, 0x67, 0xef, 0x3d, 0xea, 0xba, 0xe8, 0xf9, 0xa1, 0xa3, 0xfe, 0xf9, 0xfa, 0x3e,
+	0xb7, 0x96, 0xe8, 0x73, 0xba, 0x74, 0x43, 0x07, 0xa3, 0x0d, 0xe4, 0x25, 0x52, 0x9b, 0xdf, 0x9c,
+	0xde, 0xe0, 0x64, 0x61, 0xe2, 0xdb, 0x00, 0x7d, 0x4e, 0x34, 0xa8, 0xfa, 0xe8, 0xbd, 0xb3, 0x26,
+	0xa8, 0x29, 0x2d, 0xe5, 0x40, 0x35, 0x63, 0xd3, 0xf8, 0xa0, 0xc0, 0xa7, 0xb9, 0x00, 0xdf, 0x65,
+	0x8e, 0x8f, 0xe4, 0x04, 0x2a, 0x3e, 0xa7, 0x3c, 0xf0, 0x65, 0xc0, 0xde, 0xe1, 0x97, 0xed, 0xb8,
+	0xe2, 0x2d, 0xde, 0xed, 0x91, 0xc8, 0xe6, 0xcc, 0x47, 0x32, 0xc2, 0x8c, 0x22, 0x8d, 0x0e, 0x34,
+	0x72, 0x1b, 0xa4, 0x0e, 0xd5, 0xeb, 0xc1, 0x8f, 0x83, 0xcb, 0x9f, 0x07, 0xcd, 0x1d, 0x61, 0x8c,
+	0x7a, 0xe6, 0xeb, 0xfe, 0xe0, 0x45, 0x53, 0x21, 0x0f, 0xa0, 0x3e, 0xb8, 0xbc, 0xfa, 0x35, 0x06,
+	0x0a, 0xc6, 0x5f, 0x0a, 0xd4, 0x5f, 0x05, 0xe8, 0xad, 0xa2, 0xd0, 0xa3, 0xb5, 0x7a, 0x5a, 0x49,
+	0x3d, 0x19, 0xaf, 0xec, 0x3a, 0xa9, 0x62, 0x98, 0x4f, 0x94, 0xab, 0x01, 0xa0, 0xf2, 0xea, 0xba,
+	0x77, 0xdd, 0xeb, 0x36, 0x15, 0xb2, 0x0b, 0xb5, 0xb3, 0xfe, 0xa0, 0x3f, 0x7a, 0xd9, 0xeb, 0x36,
+	0x0b, 0x64, 0x0f, 0x60, 0x68, 0x5e, 0x9e, 0xf6, 0x46, 0x23, 0x51, 0x4f, 0x51, 0x78, 0x9e, 0x1d,
+	0xf7, 0x2f, 0x7a, 0xdd, 0x66, 0xc9, 0xf8, 0x57, 0x01, 0x55, 0xa6, 0xec, 0x73, 0x5c, 0x92, 0x3d,
+	0x28, 0x58, 0xd3, 0xe8, 0x5a, 0x0b, 0xd6, 0x94, 0x7c, 0x01, 0x8d, 0xb7, 0x01, 0x06, 0x38, 0x64,
+	0xbe, 0xc5, 0x2d, 0xe6, 0x68, 0x85, 0x96, 0x72, 0x50, 0x36, 0xf3, 0x20, 0x69, 0x41, 0x5d, 0x02,
+	0x17, 0xe8, 0xcc, 0xf9, 0x8d, 0x56, 0x94, 0x3e, 0x59, 0x88, 0x7c, 0x95, 0x30, 0x2e, 0xb5, 0x94,
+	0x83, 0xfa, 0xe1, 0xc3, 0x6d, 0x8c, 0x63, 0x96, 0xe4, 0x21, 0x94, 0xd1, 0xf3, 0x98, 0xa7, 0x95,
+	0x65, 0x21, 0xa1, 0x41, 0xf6, 0x41, 0xf5, 0x90, 0x7b, 0x2b, 0x3a, 0xb6, 0x51, 0xab, 0xb4, 0x94,
+	0x83, 0x9a, 0x99, 0x02, 0xe4, 0x11, 0x54, 0x3c, 0xa4, 0x3e, 0x73, 0xb4, 0xaa, 0x0c, 0x8a, 0x2c,
+	0xe3, 0x6f, 0x05, 0x1a, 0xa7, 0x1e, 0x52, 0x8e, 0xb1, 0x7e, 0x9a, 0x50, 0x0c, 0x3c, 0x2b, 0x22,
+	0x29, 0x96, 0xe2, 0x3c, 0xf6, 0xde, 0x41, 0x4f, 0xb2, 0x53, 0xcd, 0xd0, 0x20, 0x3f, 0x40, 0x95,
+	0xb9, 0x82, 0x9f, 0xaf, 0x15, 0x5b, 0xc5, 0x83, 0xfa, 0xe1, 0xd3, 0xa4, 0xe8, 0x5c, 0xc2, 0xf6,
+	0x65, 0xe8, 0xd5, 0x73, 0xb8, 0xb7, 0x32, 0xe3, 0x18, 0x71, 0x29, 0x13, 0x6a, 0xdb, 0x63, 0x3a,
+	0x59, 0x5c, 0x7b, 0xb6, 0xe4, 0xad, 0x9a, 0x59, 0x48, 0xef, 0xc0, 0x6e, 0x36, 0x54, 0x14, 0xb6,
+	0xc0, 0x55, 0x5c, 0xd8, 0x02, 0x57, 0xa2, 0xb0, 0x77, 0xd4, 0x0e, 0x30, 0x2e, 0x4c, 0x1a, 0x9d,
+	0xc2, 0x91, 0x62, 0xfc, 0x53, 0x82, 0xe2, 0x39, 0x1b, 0x6f, 0x34, 0x2c, 0x22, 0x57, 0xd8, 0x42,
+	0xae, 0x98, 0x25, 0xf7, 0x3c, 0x25, 0x57, 0x92, 0xe4, 0x1e, 0x27, 0xe4, 0xce, 0xd9, 0xf8, 0x16,
+	0x4a, 0x5f, 0x43, 0x99, 0x4e, 0xa7, 0x38, 0x95, 0x7d, 0xa9, 0x1f, 0xea, 0xed, 0x70, 0x80, 0xdb,
+	0xf1, 0x00, 0xb7, 0xaf, 0xe2, 0x01, 0x36, 0x43, 0x47, 0xf2, 0x0d, 0x54, 0x03, 0x77, 0x4a, 0x39,
+	0x4e, 0xb5, 0xca, 0xbd, 0x31, 0xb1, 0x2b, 0x39, 0x02, 0x75, 0xc2, 0x96, 0xae, 0x8d, 0x22, 0xae,
+	0x7a, 0x6f, 0x5c, 0xea, 0x9c, 0x99, 0xac, 0xda, 0xc7, 0x4d, 0x56, 0xaa, 0x39, 0x75, 0x4d, 0x73,
+	0x33, 0x3a, 0xc1, 0x53, 0x16, 0x38, 0x5c, 0x03, 0xa9, 0xeb, 0x14, 0x58, 0x6f, 0x71, 0x7d, 0xa3,
+	0xc5, 0x79, 0xcd, 0xee, 0xde, 0xae, 0xd9, 0x46, 0x56, 0xb3, 0x22, 0xaf, 0xe5, 0xb8, 0x01, 0x3f,
+	0x63, 0xde, 0x92, 0x72, 0x6d, 0x2f, 0xcc, 0x9b, 0x81, 0xe4, 0xc9, 0xcc, 0xe1, 0xe8, 0xf0, 0xab,
+	0x95, 0x8b, 0xda, 0x83, 0xe8, 0xe4, 0x14, 0xfa, 0x5f, 0xe2, 0xfa, 0x09, 0xca, 0xfd, 0x25, 0x9d,
+	0xe3, 0x86, 0xba, 0x08, 0x94, 0xa6, 0x94, 0x53, 0x19, 0xb1, 0x6b, 0xca, 0xf5, 0x7a, 0x29, 0xc5,
+	0x8d, 0x52, 0x8c, 0x0f, 0x05, 0x78, 0x70, 0x61, 0xf9, 0xfc, 0x9c, 0x8d, 0xfd, 0x78, 0x08, 0x13,
+	0x55, 0x2a, 0x59, 0x55, 0xa6, 0xed, 0x2b, 0x7c, 0x64, 0xfb, 0x3a, 0x00, 0x52, 0x71, 0xc7, 0x33,
+	0x1e, 0x49, 0xfd, 0x6e, 0xcd, 0x64, 0xbc, 0xc9, 0xf7, 0x50, 0x97, 0xd6, 0x09, 0xce, 0x98, 0x87,
+	0x5a, 0xe9, 0xde, 0xe0, 0xac, 0x3b, 0xd1, 0xa1, 0xe6, 0xd2, 0x39, 0x8e, 0xac, 0xdf, 0x51, 0xce,
+	0x45, 0xd9, 0x4c, 0x6c, 0xd1, 0x7e, 0xb1, 0xbe, 0x62, 0x0b, 0x74, 0xe4, 0x00, 0xa8, 0x66, 0x0a,
+	0x18, 0xbf, 0x40, 0x33, 0xbd, 0x96, 0xe8, 0xab, 0x6a, 0x41, 0xe9, 0x0d, 0x1b, 0x8b, 0x8f, 0x41,
+	0x0c, 0xe5, 0x6e, 0x76, 0x28, 0x4d, 0xb9, 0x23, 0x9e, 0x64, 0x07, 0x7f, 0xe3, 0xc3, 0x24, 0x6f,
+	0xd8, 0xbe, 0x3c, 0x68, 0x90, 0x30, 0xf7, 0x90, 0x4e, 0x16, 0xf1, 0x9d, 0x1b, 0x7f, 0x28, 0x50,
+	0x12, 0x80, 0x68, 0xa3, 0x43, 0x97, 0xf1, 0xf7, 0x29, 0xd7, 0xe4, 0x09, 0x80, 0x3c, 0x2b, 0x94,
+	0x7a, 0xf8, 0xcc, 0x67, 0x10, 0xf1, 0xeb, 0xda, 0xd6, 0x04, 0x9d, 0x49, 0xdc, 0xe2, 0xd8, 0x14,
+	0xd9, 0x38, 0x9d, 0x87, 0xef, 0x88, 0x6a, 0xca, 0xb5, 0x20, 0x6e, 0xf9, 0x5d, 0x9c, 0xd1, 0xc0,
+	0xe6, 0xf2, 0x56, 0x6a, 0x66, 0x0a, 0x18, 0x47, 0xf0, 0x49, 0xa6, 0xb8, 0x88, 0xf9, 0x53, 0x28,
+	0xbb, 0x02, 0x88, 0xa8, 0x37, 0x12, 0xea, 0xc2, 0xcd, 0x0c, 0xf7, 0x0e, 0xff, 0x2c, 0x42, 0xb5,
+	0x17, 0xe2, 0xe4, 0x04, 0xca, 0xf2, 0xe3, 0x26, 0x9f, 0x6d, 0xff, 0xce, 0x25, 0x69, 0x7d, 0xff,
+	0xae, 0xbf, 0x9e, 0x7c, 0x0b, 0x95, 0xf0, 0x2d, 0x27, 0x8f, 0xb6, 0x3f, 0xee, 0x3a, 0xc9, 0x4b,
+	0x50, 0xfc, 0x92, 0xc6, 0x0e, 0xf9, 0x0e, 0xca, 0xd2, 0x24, 0xfb, 0x1b, 0x32, 0x19, 0x71, 0xcf,
+	0x72, 0xe6, 0xaf, 0xc5, 0x30, 0xdd, 0x12, 0xdc, 0x81, 0xda, 0x0b, 0xe4, 0xe1, 0x84, 0xdd, 0x1d,
+	0xbf, 0x97, 0xc4, 0x4b, 0x6f, 0x63, 0x87, 0x1c, 0x43, 0x2d, 0xd6, 0x0c, 0xd1, 0x92, 0xdd, 0xb5,
+	0xe9, 0xd2, 0x1f, 0x6f, 0xd9, 0x09, 0x19, 0x1b, 0x3b, 0xa4, 0x0b, 0x6a, 0x72, 0xfb, 0x24, 0xef,
+	0x99, 0x95, 0x8b, 0xae, 0x6f, 0xdb, 0x8a, 0xb3, 0x8c, 0x2b, 0xb2, 0xe0, 0xe7, 0xff, 0x0d, 0x00,
+	0x08, 0x74, 0x99, 0xc8, 0xfd, 0x09, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -277,8 +943,11 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type EmojifyClient interface {
 	Check(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
-	Create(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*QueryItem, error)
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*QueryItem, error)
 	Query(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*QueryItem, error)
+	GetImage(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Image, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	ListPacks(ctx context.Context, in *ListPacksRequest, opts ...grpc.CallOption) (*ListPacksResponse, error)
 }
 
 type emojifyClient struct {
@@ -298,7 +967,7 @@ func (c *emojifyClient) Check(ctx context.Context, in *HealthCheckRequest, opts
 	return out, nil
 }
 
-func (c *emojifyClient) Create(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*QueryItem, error) {
+func (c *emojifyClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*QueryItem, error) {
 	out := new(QueryItem)
 	err := c.cc.Invoke(ctx, "/emojify.Emojify/Create", in, out, opts...)
 	if err != nil {
@@ -316,11 +985,41 @@ func (c *emojifyClient) Query(ctx context.Context, in *wrappers.StringValue, opt
 	return out, nil
 }
 
+func (c *emojifyClient) GetImage(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Image, error) {
+	out := new(Image)
+	err := c.cc.Invoke(ctx, "/emojify.Emojify/GetImage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *emojifyClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, "/emojify.Emojify/ListJobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *emojifyClient) ListPacks(ctx context.Context, in *ListPacksRequest, opts ...grpc.CallOption) (*ListPacksResponse, error) {
+	out := new(ListPacksResponse)
+	err := c.cc.Invoke(ctx, "/emojify.Emojify/ListPacks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EmojifyServer is the server API for Emojify service.
 type EmojifyServer interface {
 	Check(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
-	Create(context.Context, *wrappers.StringValue) (*QueryItem, error)
+	Create(context.Context, *CreateRequest) (*QueryItem, error)
 	Query(context.Context, *wrappers.StringValue) (*QueryItem, error)
+	GetImage(context.Context, *wrappers.StringValue) (*Image, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	ListPacks(context.Context, *ListPacksRequest) (*ListPacksResponse, error)
 }
 
 func RegisterEmojifyServer(s *grpc.Server, srv EmojifyServer) {
@@ -346,7 +1045,7 @@ func _Emojify_Check_Handler(srv interface{}, ctx context.Context, dec func(inter
 }
 
 func _Emojify_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(wrappers.StringValue)
+	in := new(CreateRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -358,7 +1057,7 @@ func _Emojify_Create_Handler(srv interface{}, ctx context.Context, dec func(inte
 		FullMethod: "/emojify.Emojify/Create",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(EmojifyServer).Create(ctx, req.(*wrappers.StringValue))
+		return srv.(EmojifyServer).Create(ctx, req.(*CreateRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -381,6 +1080,60 @@ func _Emojify_Query_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Emojify_GetImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrappers.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmojifyServer).GetImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/emojify.Emojify/GetImage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmojifyServer).GetImage(ctx, req.(*wrappers.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Emojify_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmojifyServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/emojify.Emojify/ListJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmojifyServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Emojify_ListPacks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPacksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EmojifyServer).ListPacks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/emojify.Emojify/ListPacks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EmojifyServer).ListPacks(ctx, req.(*ListPacksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Emojify_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "emojify.Emojify",
 	HandlerType: (*EmojifyServer)(nil),
@@ -397,39 +1150,19 @@ var _Emojify_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Emojify_Query_Handler,
 		},
+		{
+			MethodName: "GetImage",
+			Handler:    _Emojify_GetImage_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _Emojify_ListJobs_Handler,
+		},
+		{
+			MethodName: "ListPacks",
+			Handler:    _Emojify_ListPacks_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "emojify.proto",
 }
-
-func init() { proto.RegisterFile("emojify.proto", fileDescriptor_emojify_66182d6044d336b3) }
-
-var fileDescriptor_emojify_66182d6044d336b3 = []byte{
-	// 411 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x52, 0x4d, 0x6f, 0xd3, 0x40,
-	0x10, 0xed, 0xba, 0xc4, 0xa6, 0x63, 0x12, 0xac, 0x81, 0x83, 0x15, 0x2a, 0x64, 0x59, 0x1c, 0x22,
-	0x84, 0x5c, 0x29, 0x5c, 0x10, 0x70, 0x6a, 0x62, 0xa8, 0x05, 0x72, 0x5a, 0x9b, 0x94, 0x23, 0x72,
-	0xdb, 0xa9, 0x63, 0x48, 0xbd, 0xae, 0x77, 0x0d, 0xca, 0x8f, 0xe0, 0xc0, 0x81, 0xff, 0xc4, 0xcf,
-	0x42, 0xde, 0xd8, 0x51, 0x8c, 0x22, 0x0e, 0xbd, 0xed, 0x7b, 0xfb, 0xde, 0xe8, 0xcd, 0x07, 0xf4,
-	0xe9, 0x86, 0x7f, 0xcd, 0xae, 0x57, 0x5e, 0x51, 0x72, 0xc9, 0xd1, 0x68, 0xe0, 0xf0, 0x69, 0xca,
-	0x79, 0xba, 0xa4, 0x23, 0x45, 0x5f, 0x54, 0xd7, 0x47, 0x3f, 0xca, 0xa4, 0x28, 0xa8, 0x14, 0x6b,
-	0xa1, 0xeb, 0x01, 0x9e, 0x50, 0xb2, 0x94, 0x8b, 0xc9, 0x82, 0x2e, 0xbf, 0x45, 0x74, 0x5b, 0x91,
-	0x90, 0x68, 0x83, 0x21, 0xa8, 0xfc, 0x9e, 0x5d, 0x92, 0xcd, 0x1c, 0x36, 0x3a, 0x88, 0x5a, 0xe8,
-	0xfe, 0x66, 0xf0, 0xa8, 0x63, 0x10, 0x05, 0xcf, 0x05, 0xe1, 0x31, 0xe8, 0x42, 0x26, 0xb2, 0x12,
-	0xca, 0x30, 0x18, 0x3f, 0xf7, 0xda, 0x40, 0x3b, 0xd4, 0x5e, 0x5c, 0x57, 0xcb, 0xd3, 0x58, 0x39,
-	0xa2, 0xc6, 0xe9, 0xbe, 0x86, 0x7e, 0xe7, 0x03, 0x4d, 0x30, 0xe6, 0xe1, 0x87, 0x70, 0xf6, 0x39,
-	0xb4, 0xf6, 0x6a, 0x10, 0xfb, 0xd1, 0x79, 0x10, 0xbe, 0xb7, 0x18, 0x3e, 0x04, 0x33, 0x9c, 0x7d,
-	0xfa, 0xd2, 0x12, 0x9a, 0xfb, 0x93, 0x81, 0x79, 0x56, 0x51, 0xb9, 0x6a, 0xac, 0xaf, 0xfe, 0xc9,
-	0xe3, 0x6c, 0xf2, 0x6c, 0xa9, 0xb6, 0xdf, 0x9b, 0x14, 0xd3, 0x6e, 0xa1, 0x4e, 0x06, 0x00, 0xfd,
-	0x6c, 0xee, 0xcf, 0xfd, 0xa9, 0xc5, 0xf0, 0x01, 0xdc, 0x7f, 0x17, 0x84, 0x41, 0x7c, 0xe2, 0x4f,
-	0x2d, 0x0d, 0x07, 0x00, 0xa7, 0xd1, 0x6c, 0xe2, 0xc7, 0x71, 0x9d, 0x67, 0xdf, 0xfd, 0xc5, 0xe0,
-	0x40, 0x95, 0x09, 0x24, 0xdd, 0xe0, 0x00, 0xb4, 0xec, 0xaa, 0x19, 0xa5, 0x96, 0x5d, 0xe1, 0x33,
-	0xe8, 0xdf, 0x56, 0x54, 0xd1, 0x29, 0x17, 0x99, 0xcc, 0x78, 0x6e, 0x6b, 0x0e, 0x1b, 0xf5, 0xa2,
-	0x2e, 0x89, 0x0e, 0x98, 0x8a, 0xf8, 0x48, 0x79, 0x2a, 0x17, 0xf6, 0xbe, 0xd2, 0x6c, 0x53, 0xf8,
-	0x62, 0xd3, 0xe5, 0x3d, 0x87, 0x8d, 0xcc, 0xf1, 0xe3, 0x5d, 0x5d, 0xb6, 0x9d, 0x8d, 0xff, 0x30,
-	0x30, 0xfc, 0xf5, 0x3f, 0x1e, 0x43, 0x4f, 0xad, 0x04, 0x9f, 0xec, 0x5e, 0x94, 0xba, 0x83, 0xe1,
-	0xe1, 0xff, 0xb6, 0x88, 0x6f, 0x41, 0x9f, 0x94, 0x94, 0x48, 0xc2, 0x43, 0x6f, 0x7d, 0x66, 0x5e,
-	0x7b, 0x66, 0x5e, 0x2c, 0xcb, 0x2c, 0x4f, 0xcf, 0x93, 0x65, 0x45, 0x43, 0xec, 0xa6, 0xaa, 0x27,
-	0xe2, 0xee, 0xe1, 0x1b, 0xe8, 0x29, 0x78, 0x17, 0xf3, 0x85, 0xae, 0xb4, 0x2f, 0xff, 0x06, 0x00,
-	0x00, 0xff, 0xff, 0xc6, 0xe0, 0xe5, 0x5f, 0xf7, 0x02, 0x00, 0x00,
-}
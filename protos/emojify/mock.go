@@ -24,7 +24,7 @@ func (m *ClientMock) Check(ctx context.Context, in *HealthCheckRequest, opts ...
 }
 
 // Create is a mock implementation of the Create interface method
-func (m *ClientMock) Create(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*QueryItem, error) {
+func (m *ClientMock) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*QueryItem, error) {
 	args := m.Called(ctx, in, opts)
 
 	if qi := args.Get(0); qi != nil {
@@ -44,3 +44,25 @@ func (m *ClientMock) Query(ctx context.Context, in *wrappers.StringValue, opts .
 
 	return nil, args.Error(1)
 }
+
+// GetImage is a mock implementation of the GetImage interface method
+func (m *ClientMock) GetImage(ctx context.Context, in *wrappers.StringValue, opts ...grpc.CallOption) (*Image, error) {
+	args := m.Called(ctx, in, opts)
+
+	if i := args.Get(0); i != nil {
+		return i.(*Image), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// ListJobs is a mock implementation of the ListJobs interface method
+func (m *ClientMock) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	args := m.Called(ctx, in, opts)
+
+	if r := args.Get(0); r != nil {
+		return r.(*ListJobsResponse), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
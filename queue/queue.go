@@ -8,6 +8,12 @@ type Item struct {
 	ID string
 	// URI of the item to process
 	URI string
+	// Owner is the caller who submitted the item
+	Owner string
+	// Options used to process the item
+	Options map[string]string
+	// CallbackURL is notified when processing finishes or fails
+	CallbackURL string
 	// Added to the queue at time
 	Added time.Time
 	// Complete at time
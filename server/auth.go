@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/emojify-app/emojify/auth"
+	"github.com/emojify-app/emojify/jobs"
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods can be called without credentials
+var publicMethods = map[string]bool{
+	"/emojify.Emojify/Check": true,
+}
+
+// Auth authenticates callers using an API key in the x-api-key header or
+// a JWT in the authorization header as a bearer token
+type Auth struct {
+	keys   auth.Authenticator
+	tokens auth.Authenticator
+	logger hclog.Logger
+}
+
+// NewAuth creates a new Auth, keys authenticates API keys and tokens
+// authenticates JWTs, either may be nil to disable that credential type
+func NewAuth(keys, tokens auth.Authenticator, l hclog.Logger) *Auth {
+	return &Auth{keys: keys, tokens: tokens, logger: l}
+}
+
+// UnaryInterceptor authenticates unary calls and adds the caller to the
+// request context
+func (a *Auth) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		ctx, err := a.authenticateContext(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor authenticates streaming calls and adds the caller to
+// the stream context
+func (a *Auth) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if publicMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		ctx, err := a.authenticateContext(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authStream{ss, ctx})
+	}
+}
+
+// Handler wraps the HTTP gateway so REST requests are authenticated with
+// the same credentials as gRPC calls, the health check is not authenticated
+func (a *Auth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		c, err := a.authenticate(r.Header.Get("x-api-key"), r.Header.Get("authorization"))
+		if err != nil {
+			a.logger.Debug("Authentication failed", "path", r.URL.Path, "error", err)
+			writeError(rw, status.Error(codes.Unauthenticated, "invalid or missing credentials"))
+			return
+		}
+
+		next.ServeHTTP(rw, r.WithContext(auth.NewContext(r.Context(), c)))
+	})
+}
+
+func (a *Auth) authenticateContext(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	c, err := a.authenticate(first(md, "x-api-key"), first(md, "authorization"))
+	if err != nil {
+		a.logger.Debug("Authentication failed", "method", method, "error", err)
+		return nil, grpc.Errorf(codes.Unauthenticated, "invalid or missing credentials")
+	}
+
+	return auth.NewContext(ctx, c), nil
+}
+
+// authenticate verifies the API key or bearer token, an API key is used
+// when both are present
+func (a *Auth) authenticate(key, authorization string) (*auth.Caller, error) {
+	if key != "" && a.keys != nil {
+		return a.keys.Authenticate(key)
+	}
+
+	if strings.HasPrefix(authorization, "Bearer ") && a.tokens != nil {
+		return a.tokens.Authenticate(strings.TrimSpace(authorization[len("Bearer "):]))
+	}
+
+	return nil, auth.ErrInvalidCredentials
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// authStream overrides the context of a server stream
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize returns an error unless the caller in the context owns the
+// job or is an admin, when the request has not been authenticated all
+// callers are allowed
+func authorize(ctx context.Context, owner string) error {
+	c, ok := auth.FromContext(ctx)
+	if !ok || c.IsAdmin() || c.ID == owner {
+		return nil
+	}
+
+	return grpc.Errorf(codes.PermissionDenied, "caller %s does not own this request", c.ID)
+}
+
+// authorizeJob returns an error unless the caller in the context owns the
+// job with the given id. The owner of an item without a job record is not
+// known, the record may have expired or the item may predate
+// authentication, so only admins can access it.
+func (e *Emojify) authorizeJob(ctx context.Context, id string) error {
+	c, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	j, err := e.jobs.Get(id)
+	if err == jobs.ErrNotFound {
+		if c.IsAdmin() {
+			return nil
+		}
+
+		return grpc.Errorf(codes.PermissionDenied, "caller %s does not own this request", c.ID)
+	}
+
+	if err != nil {
+		return grpc.Errorf(codes.Internal, "unable to get job: %s", err)
+	}
+
+	return authorize(ctx, j.Owner)
+}
+
+// owner returns the owner recorded for a new request, authenticated
+// callers own their requests, admins may create requests on behalf of
+// another owner
+func owner(ctx context.Context, requested string) string {
+	c, ok := auth.FromContext(ctx)
+	if !ok || (c.IsAdmin() && requested != "") {
+		return requested
+	}
+
+	return c.ID
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emojify-app/emojify/auth"
+	"github.com/emojify-app/emojify/jobs"
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/emojify-app/emojify/queue"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+var alice = &auth.Caller{ID: "alice"}
+var admin = &auth.Caller{ID: "root", Roles: []string{auth.RoleAdmin}}
+
+type fakeAuthenticator map[string]*auth.Caller
+
+func (f fakeAuthenticator) Authenticate(c string) (*auth.Caller, error) {
+	if caller, ok := f[c]; ok {
+		return caller, nil
+	}
+
+	return nil, auth.ErrInvalidCredentials
+}
+
+func setupAuth() *Auth {
+	return NewAuth(
+		fakeAuthenticator{"key1": alice},
+		fakeAuthenticator{"token1": admin},
+		hclog.NewNullLogger(),
+	)
+}
+
+func callUnary(a *Auth, ctx context.Context, method string) (*auth.Caller, error) {
+	var caller *auth.Caller
+	h := func(ctx context.Context, req interface{}) (interface{}, error) {
+		caller, _ = auth.FromContext(ctx)
+		return nil, nil
+	}
+
+	_, err := a.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, h)
+	return caller, err
+}
+
+func TestUnaryInterceptorAuthenticatesAPIKey(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+
+	c, err := callUnary(setupAuth(), ctx, "/emojify.Emojify/Create")
+
+	assert.Nil(t, err)
+	assert.Equal(t, alice, c)
+}
+
+func TestUnaryInterceptorAuthenticatesBearerToken(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token1"))
+
+	c, err := callUnary(setupAuth(), ctx, "/emojify.Emojify/Create")
+
+	assert.Nil(t, err)
+	assert.Equal(t, admin, c)
+}
+
+func TestUnaryInterceptorRejectsMissingCredentials(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "token1"))
+
+	_, err := callUnary(setupAuth(), ctx, "/emojify.Emojify/Create")
+
+	assert.Equal(t, codes.Unauthenticated, grpc.Code(err))
+}
+
+func TestUnaryInterceptorAllowsHealthCheck(t *testing.T) {
+	_, err := callUnary(setupAuth(), context.Background(), "/emojify.Emojify/Check")
+
+	assert.Nil(t, err)
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamInterceptorAddsCallerToStreamContext(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+
+	var caller *auth.Caller
+	h := func(srv interface{}, ss grpc.ServerStream) error {
+		caller, _ = auth.FromContext(ss.Context())
+		return nil
+	}
+
+	err := setupAuth().StreamInterceptor()(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/emojify.Emojify/Watch"}, h)
+
+	assert.Nil(t, err)
+	assert.Equal(t, alice, caller)
+}
+
+func TestHandlerRejectsUnauthenticatedRequests(t *testing.T) {
+	h := setupAuth().Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/emojify/abc", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestCreateRecordsCallerAsOwner(t *testing.T) {
+	e := setup(t, 0, 0)
+	ctx := auth.NewContext(context.Background(), alice)
+
+	i, err := e.Create(ctx, &emojify.CreateRequest{Uri: testURL, Owner: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ownedJobID(hashedID, "alice"), i.GetId())
+	mockQueue.AssertCalled(t, "Push", mock.MatchedBy(func(i *queue.Item) bool {
+		return i.Owner == "alice"
+	}))
+}
+
+func TestCreateAllowsAdminToSetOwner(t *testing.T) {
+	e := setup(t, 0, 0)
+	ctx := auth.NewContext(context.Background(), admin)
+
+	_, err := e.Create(ctx, &emojify.CreateRequest{Uri: testURL, Owner: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockQueue.AssertCalled(t, "Push", mock.MatchedBy(func(i *queue.Item) bool {
+		return i.Owner == "bob"
+	}))
+}
+
+func TestQueryDeniesCallerWhoDoesNotOwnJob(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, Owner: "bob"}, nil)
+
+	_, err := e.Query(auth.NewContext(context.Background(), alice), &wrappers.StringValue{Value: hashedID})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	_, err = e.GetImage(auth.NewContext(context.Background(), alice), &wrappers.StringValue{Value: hashedID})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
+
+func TestQueryAllowsAdmin(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, Owner: "bob"}, nil)
+
+	_, err := e.Query(auth.NewContext(context.Background(), admin), &wrappers.StringValue{Value: hashedID})
+
+	assert.Nil(t, err)
+}
+
+func TestQueryDeniesCallerWhenJobHasNoRecord(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.Query(auth.NewContext(context.Background(), alice), &wrappers.StringValue{Value: hashedID})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	_, err = e.GetImage(auth.NewContext(context.Background(), alice), &wrappers.StringValue{Value: hashedID})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+
+	_, err = e.Query(auth.NewContext(context.Background(), admin), &wrappers.StringValue{Value: hashedID})
+	assert.Nil(t, err)
+}
+
+func TestCreateFailsWhenJobCanNotBeSaved(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", mock.Anything).Return(nil, jobs.ErrNotFound)
+	mockJobs.On("Save", mock.Anything).Return(fmt.Errorf("store down"))
+
+	_, err := e.Create(auth.NewContext(context.Background(), alice), &emojify.CreateRequest{Uri: testURL})
+
+	assert.Equal(t, codes.Internal, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestListJobsRestrictsCallerToOwnJobs(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.On("List", jobs.Filter{Owner: "alice"}, 0, defaultPageSize).Return([]*jobs.Job{}, false, nil)
+	ctx := auth.NewContext(context.Background(), alice)
+
+	_, err := e.ListJobs(ctx, &emojify.ListJobsRequest{})
+	assert.Nil(t, err)
+
+	_, err = e.ListJobs(ctx, &emojify.ListJobsRequest{Owner: "bob"})
+	assert.Equal(t, codes.PermissionDenied, grpc.Code(err))
+}
@@ -2,12 +2,18 @@ package server
 
 import (
 	"context"
-	"encoding/base64"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/emojify-app/cache/protos/cache"
+	"github.com/emojify-app/emojify/auth"
+	emoji "github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/jobs"
 	"github.com/emojify-app/emojify/logging"
 	"github.com/emojify-app/emojify/protos/emojify"
 	"github.com/emojify-app/emojify/queue"
@@ -20,12 +26,63 @@ import (
 type Emojify struct {
 	workerQueue queue.Queue
 	cache       cache.CacheClient
+	jobs        jobs.Store
 	logger      logging.Logger
+	legacyIDs   bool
+	packs       PackLister
+	selection   SelectionValidator
+	callbacks   bool
+	// callbackPolicy limits the addresses callbacks can be sent to
+	callbackPolicy *emoji.URLPolicy
+}
+
+// PackLister returns the emoji packs loaded by the worker
+type PackLister interface {
+	ListPacks() []emoji.PackInfo
+	DefaultPack() string
+}
+
+// SelectionValidator checks the emoji chosen by the selection options of a
+// request exist
+type SelectionValidator interface {
+	ValidateSelection(options map[string]string, tenant string) error
 }
 
 // New creates a new Emojify implementation
-func New(q queue.Queue, cc cache.CacheClient, l logging.Logger) *Emojify {
-	return &Emojify{q, cc, l}
+func New(q queue.Queue, cc cache.CacheClient, js jobs.Store, l logging.Logger) *Emojify {
+	return &Emojify{workerQueue: q, cache: cc, jobs: js, logger: l}
+}
+
+// SetLegacyIDLookup enables resolution of the base64 encoded URL IDs used
+// before content hashed IDs were introduced, this should be enabled until
+// all clients and cached items have migrated
+func (e *Emojify) SetLegacyIDLookup(enabled bool) {
+	e.legacyIDs = enabled
+}
+
+// SetPacks enables listing of the emoji packs and validation of the pack
+// option when requests are created
+func (e *Emojify) SetPacks(p PackLister) {
+	e.packs = p
+}
+
+// SetSelectionValidator enables validation of the selection strategy,
+// codepoint and tag options when requests are created
+func (e *Emojify) SetSelectionValidator(v SelectionValidator) {
+	e.selection = v
+}
+
+// SetCallbacks enables the callbackUrl of requests, requests with a
+// callbackUrl are rejected unless callbacks are enabled
+func (e *Emojify) SetCallbacks(enabled bool) {
+	e.callbacks = enabled
+}
+
+// SetCallbackPolicy rejects requests with a callback URL whose host is an
+// address which is not allowed by the policy, the webhook dispatcher
+// checks the resolved address again when each callback is sent
+func (e *Emojify) SetCallbackPolicy(p emoji.URLPolicy) {
+	e.callbackPolicy = &p
 }
 
 // Check is a gRPC health check
@@ -45,10 +102,45 @@ func (e *Emojify) Check(context.Context, *emojify.HealthCheckRequest) (*emojify.
 }
 
 // Create an Emojify request to process an image
-func (e *Emojify) Create(ctx context.Context, uri *wrappers.StringValue) (*emojify.QueryItem, error) {
-	done := e.logger.Create(uri.GetValue())
+func (e *Emojify) Create(ctx context.Context, r *emojify.CreateRequest) (*emojify.QueryItem, error) {
+	done := e.logger.Create(r.GetUri())
+
+	if err := e.validateCallbackURL(r.GetCallbackUrl()); err != nil {
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid callbackUrl: %s", err)
+	}
 
-	id := base64.URLEncoding.EncodeToString([]byte(uri.GetValue()))
+	if err := emoji.ValidateOptions(r.GetOptions()); err != nil {
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid options: %s", err)
+	}
+
+	if p := r.GetOptions()[emoji.OptionPack]; p != "" && e.packs != nil && !hasPack(e.packs, p) {
+		err := fmt.Errorf("pack %q does not exist", p)
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid options: %s", err)
+	}
+
+	id, err := jobID(r.GetUri(), r.GetOptions())
+	if err != nil {
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid uri: %s", err)
+	}
+
+	// requests from authenticated callers are owned by the caller
+	o := owner(ctx, r.GetOwner())
+
+	// the owner chooses the pack when the request does not
+	if e.selection != nil {
+		if err := e.selection.ValidateSelection(r.GetOptions(), o); err != nil {
+			done(http.StatusBadRequest, err)
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid options: %s", err)
+		}
+	}
+	_, authenticated := auth.FromContext(ctx)
+	if authenticated {
+		id = ownedJobID(id, o)
+	}
 
 	// check the current queue and cache before adding
 	ei, err := e.checkQueueAndCache(id)
@@ -58,19 +150,53 @@ func (e *Emojify) Create(ctx context.Context, uri *wrappers.StringValue) (*emoji
 		done(http.StatusInternalServerError, err)
 	}
 
+	// items submitted before content hashed IDs were introduced are
+	// stored under the legacy ID, legacy items never had options
+	if ei == nil && err == nil && e.legacyIDs && len(r.GetOptions()) == 0 && !authenticated {
+		ei, err = e.checkQueueAndCache(legacyID(r.GetUri()))
+	}
+
 	// exists in either the cache or the queue, return
 	if ei != nil {
 		e.logger.Log().Debug("Found item in cache or queue", "item", ei)
 
+		// the callback of an existing item is set when it is created, a
+		// new callback would never be sent so the request is rejected
+		if r.GetCallbackUrl() != "" {
+			err := fmt.Errorf("item %s already exists", ei.GetId())
+			done(http.StatusConflict, err)
+			return nil, grpc.Errorf(codes.AlreadyExists, "unable to set callbackUrl: %s, query the item for its status", err)
+		}
+
 		done(http.StatusOK, nil)
 		return ei, nil
 	}
 
 	// create a new queueItem and add to the queue
 	qi := &queue.Item{
-		ID:    id,
-		Added: time.Now(),
-		URI:   uri.GetValue(),
+		ID:          id,
+		Added:       time.Now(),
+		URI:         r.GetUri(),
+		Owner:       o,
+		Options:     r.GetOptions(),
+		CallbackURL: r.GetCallbackUrl(),
+	}
+
+	// record the job before queueing it, the record holds the owner so
+	// the request must fail when it can not be saved
+	err = e.saveJob(&jobs.Job{
+		ID:          qi.ID,
+		URI:         qi.URI,
+		Owner:       qi.Owner,
+		Options:     qi.Options,
+		Added:       qi.Added,
+		Updated:     qi.Added,
+		Status:      jobs.StatusQueued,
+		CallbackURL: qi.CallbackURL,
+	})
+	if err != nil {
+		done(http.StatusInternalServerError, err)
+		return nil, grpc.Errorf(codes.Internal, "unable to save job: %s", err)
 	}
 
 	e.logger.Log().Debug("Create PUT")
@@ -108,7 +234,16 @@ func (e *Emojify) Create(ctx context.Context, uri *wrappers.StringValue) (*emoji
 func (e *Emojify) Query(ctx context.Context, id *wrappers.StringValue) (*emojify.QueryItem, error) {
 	done := e.logger.Query(id.GetValue())
 
+	if err := e.authorizeJob(ctx, id.GetValue()); err != nil {
+		done(http.StatusForbidden, err)
+		return nil, err
+	}
+
 	ei, err := e.checkQueueAndCache(id.GetValue())
+	if ei == nil && err == nil {
+		ei, err = e.checkLegacyID(id.GetValue())
+	}
+
 	if err != nil {
 		log.Println(err)
 		if grpc.Code(err) == codes.NotFound {
@@ -120,10 +255,92 @@ func (e *Emojify) Query(ctx context.Context, id *wrappers.StringValue) (*emojify
 		return ei, err
 	}
 
+	// not in the cache or the queue, the job record holds the status of
+	// items which have failed
+	if ei == nil {
+		ei = e.jobStatus(id.GetValue())
+	}
+
 	done(http.StatusOK, nil)
 	return ei, nil
 }
 
+// GetImage returns the emojified image for a finished request
+func (e *Emojify) GetImage(ctx context.Context, id *wrappers.StringValue) (*emojify.Image, error) {
+	done := e.logger.GetImage(id.GetValue())
+
+	if err := e.authorizeJob(ctx, id.GetValue()); err != nil {
+		done(http.StatusForbidden, err)
+		return nil, err
+	}
+
+	key := id.GetValue()
+	contentType := ""
+
+	// images which are aliases of another job are cached under the
+	// original id, legacy ids are cached under the hashed id
+	j, err := e.jobs.Get(key)
+	if err == nil {
+		contentType = j.ContentType
+	}
+
+	if err == nil && j.AliasOf != "" {
+		key = j.AliasOf
+	} else if uri, ok := legacyURI(key); ok && e.legacyIDs {
+		if exists, _ := e.cache.Exists(ctx, &wrappers.StringValue{Value: key}); !exists.GetValue() {
+			key, _ = jobID(uri, nil)
+		}
+	}
+
+	ci, err := e.cache.Get(ctx, &wrappers.StringValue{Value: key})
+	if err != nil {
+		if grpc.Code(err) == codes.NotFound {
+			done(http.StatusNotFound, err)
+			return nil, grpc.Errorf(codes.NotFound, "image %s not found", id.GetValue())
+		}
+
+		done(http.StatusInternalServerError, err)
+		return nil, grpc.Errorf(codes.Internal, "unable to get image from cache: %s", err)
+	}
+
+	// the content type of jobs which have expired is detected from the
+	// image, items processed before the type was recorded are JPEG
+	if contentType == "" {
+		contentType = http.DetectContentType(ci.GetData())
+		if !strings.HasPrefix(contentType, "image/") {
+			contentType = "image/jpeg"
+		}
+	}
+
+	done(http.StatusOK, nil)
+	return &emojify.Image{
+		Id:          id.GetValue(),
+		Data:        ci.GetData(),
+		ContentType: contentType,
+	}, nil
+}
+
+// checkLegacyID resolves a legacy ID to the content hashed ID for the
+// same URL and checks the queue and cache, returns nil when the ID is
+// not a legacy ID or legacy lookup is disabled
+func (e *Emojify) checkLegacyID(id string) (*emojify.QueryItem, error) {
+	if !e.legacyIDs {
+		return nil, nil
+	}
+
+	uri, ok := legacyURI(id)
+	if !ok {
+		return nil, nil
+	}
+
+	nid, err := jobID(uri, nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	return e.checkQueueAndCache(nid)
+}
+
 func (e *Emojify) checkQueueAndCache(id string) (*emojify.QueryItem, error) {
 	ei := &emojify.QueryItem{Id: id}
 
@@ -148,6 +365,13 @@ func (e *Emojify) checkQueueAndCache(id string) (*emojify.QueryItem, error) {
 		return ei, nil
 	}
 
+	// near identical images processed at another url are stored in the
+	// cache under the id of the original
+	if e.aliasCached(id) {
+		ei.Status = &emojify.QueryStatus{Status: emojify.QueryStatus_FINISHED}
+		return ei, nil
+	}
+
 	// check the item is not already on the queue do not return an error
 	// as the queue might not exist
 	qiDone := e.logger.QueueGet(id)
@@ -181,3 +405,51 @@ func (e *Emojify) checkQueueAndCache(id string) (*emojify.QueryItem, error) {
 	qiDone(http.StatusNotFound, nil)
 	return nil, nil
 }
+
+// validateCallbackURL checks the callback is an absolute http(s) url whose
+// host is allowed by the callback policy, an empty callback is valid and
+// disables notification
+func (e *Emojify) validateCallbackURL(callback string) error {
+	if callback == "" {
+		return nil
+	}
+
+	if !e.callbacks {
+		return fmt.Errorf("callbacks are not enabled")
+	}
+
+	u, err := url.Parse(callback)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("host must not be empty")
+	}
+
+	if e.callbackPolicy == nil {
+		return nil
+	}
+
+	// hosts which are names are checked when the callback is sent as
+	// they can resolve to a different address
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return e.callbackPolicy.CheckAddress("tcp", net.JoinHostPort(ip.String(), port), nil)
+	}
+
+	return nil
+}
@@ -2,9 +2,12 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/emojify-app/cache/protos/cache"
+	emoji "github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/jobs"
 	"github.com/emojify-app/emojify/logging"
 	"github.com/emojify-app/emojify/protos/emojify"
 	"github.com/emojify-app/emojify/queue"
@@ -17,9 +20,11 @@ import (
 
 var mockQueue *queue.MockQueue
 var mockCache *cache.ClientMock
+var mockJobs *jobs.MockStore
 
-var url = "http://abcde.com"
+var testURL = "http://abcde.com"
 var base64URL = "aHR0cDovL2FiY2RlLmNvbQ=="
+var hashedID = "37bc3d420709bd3c99e723e4a70ac7bbe3444bedeae2de5152800877f895506a"
 
 func setup(t *testing.T, pos, ql int) *Emojify {
 	mockQueue = &queue.MockQueue{}
@@ -30,9 +35,13 @@ func setup(t *testing.T, pos, ql int) *Emojify {
 	mockCache = &cache.ClientMock{}
 	mockCache.On("Exists", mock.Anything, mock.Anything, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
 
+	mockJobs = &jobs.MockStore{}
+	mockJobs.On("Save", mock.Anything).Return(nil)
+	mockJobs.On("Get", mock.Anything).Return(nil, jobs.ErrNotFound)
+
 	logger := logging.New("localhost:9125", "debug")
 
-	return New(mockQueue, mockCache, logger)
+	return New(mockQueue, mockCache, mockJobs, logger)
 }
 
 func TestHealthReturnsValidResponseWhenOK(t *testing.T) {
@@ -46,7 +55,7 @@ func TestHealthReturnsValidResponseWhenOK(t *testing.T) {
 
 func TestCreateAddsItemToTheQueueIfNotPresent(t *testing.T) {
 	e := setup(t, 0, 0)
-	id := &wrappers.StringValue{Value: url}
+	id := &emojify.CreateRequest{Uri: testURL}
 
 	i, err := e.Create(context.Background(), id)
 	if err != nil {
@@ -58,17 +67,31 @@ func TestCreateAddsItemToTheQueueIfNotPresent(t *testing.T) {
 
 	// check the item pushed to the queue
 	item := mockQueue.Calls[1].Arguments[0].(*queue.Item)
-	assert.Equal(t, url, item.URI)
+	assert.Equal(t, testURL, item.URI)
 
-	assert.Equal(t, base64URL, i.Id)
+	assert.Equal(t, hashedID, i.Id)
 	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_QUEUED}, i.GetStatus())
 }
 
+func TestCreateSavesQueuedJob(t *testing.T) {
+	e := setup(t, 0, 0)
+	id := &emojify.CreateRequest{Uri: testURL, Owner: "tenant1"}
+
+	_, err := e.Create(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.ID == hashedID && j.URI == testURL && j.Owner == "tenant1" && j.Status == jobs.StatusQueued
+	}))
+}
+
 func TestCreateContinuesWhenCacheError(t *testing.T) {
 	e := setup(t, 0, 0)
-	id := &wrappers.StringValue{Value: url}
+	id := &emojify.CreateRequest{Uri: testURL}
 	mockCache.ExpectedCalls = make([]*mock.Call, 0)
-	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: base64URL}, mock.Anything).Return(nil, grpc.Errorf(codes.Internal, "boom"))
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(nil, grpc.Errorf(codes.Internal, "boom"))
 
 	_, err := e.Create(context.Background(), id)
 
@@ -78,9 +101,9 @@ func TestCreateContinuesWhenCacheError(t *testing.T) {
 
 func TestCreateDoesNotAddItemToTheQueueIfInCache(t *testing.T) {
 	e := setup(t, 0, 0)
-	id := &wrappers.StringValue{Value: url}
+	id := &emojify.CreateRequest{Uri: testURL}
 	mockCache.ExpectedCalls = make([]*mock.Call, 0)
-	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: base64URL}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
 
 	i, err := e.Create(context.Background(), id)
 	if err != nil {
@@ -89,13 +112,33 @@ func TestCreateDoesNotAddItemToTheQueueIfInCache(t *testing.T) {
 
 	mockQueue.AssertNotCalled(t, "Position", mock.Anything)
 	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
-	assert.Equal(t, base64URL, i.Id)
+	assert.Equal(t, hashedID, i.Id)
 	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_FINISHED}, i.GetStatus())
 }
 
+func TestCreateReturnsAlreadyExistsForCallbackWhenInCacheOrQueue(t *testing.T) {
+	e := setup(t, 1, 2)
+	e.SetCallbacks(true)
+	id := &emojify.CreateRequest{Uri: testURL, CallbackUrl: "https://hooks.example.com/emojify"}
+
+	_, err := e.Create(context.Background(), id)
+	assert.Equal(t, codes.AlreadyExists, grpc.Code(err))
+
+	mockQueue.ExpectedCalls = make([]*mock.Call, 0)
+	mockQueue.On("Position", mock.Anything).Return(-1, 0, nil)
+	mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+
+	_, err = e.Create(context.Background(), id)
+	assert.Equal(t, codes.AlreadyExists, grpc.Code(err))
+
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+	mockJobs.AssertNotCalled(t, "Save", mock.Anything)
+}
+
 func TestCreateDoesNotAddItemToTheQueueIfPresent(t *testing.T) {
 	e := setup(t, 1, 2)
-	id := &wrappers.StringValue{Value: url}
+	id := &emojify.CreateRequest{Uri: testURL}
 
 	i, err := e.Create(context.Background(), id)
 	if err != nil {
@@ -104,7 +147,7 @@ func TestCreateDoesNotAddItemToTheQueueIfPresent(t *testing.T) {
 
 	mockQueue.AssertCalled(t, "Position", mock.Anything)
 	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
-	assert.Equal(t, base64URL, i.Id)
+	assert.Equal(t, hashedID, i.Id)
 	assert.Equal(t, int32(1), i.QueuePosition)
 	assert.Equal(t, int32(2), i.QueueLength)
 	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_QUEUED}, i.GetStatus())
@@ -112,7 +155,7 @@ func TestCreateDoesNotAddItemToTheQueueIfPresent(t *testing.T) {
 
 func TestQueryReturnsItemIfOnQueue(t *testing.T) {
 	e := setup(t, 4, 4)
-	id := &wrappers.StringValue{Value: base64URL}
+	id := &wrappers.StringValue{Value: hashedID}
 
 	i, err := e.Query(context.Background(), id)
 	if err != nil {
@@ -127,7 +170,7 @@ func TestQueryReturnsItemIfOnQueue(t *testing.T) {
 
 func TestQueryReturnsItemIfInCache(t *testing.T) {
 	e := setup(t, 0, 0)
-	id := &wrappers.StringValue{Value: base64URL}
+	id := &wrappers.StringValue{Value: hashedID}
 	mockCache.ExpectedCalls = make([]*mock.Call, 0)
 	mockCache.On("Exists", mock.Anything, id, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
 
@@ -142,7 +185,7 @@ func TestQueryReturnsItemIfInCache(t *testing.T) {
 
 func TestQueryRequeuesIfQueueError(t *testing.T) {
 	e := setup(t, 4, 4)
-	id := &wrappers.StringValue{Value: base64URL}
+	id := &wrappers.StringValue{Value: hashedID}
 	mockQueue.ExpectedCalls = make([]*mock.Call, 0)
 	mockQueue.On("Position", mock.Anything).Return(0, 0, grpc.Errorf(codes.Internal, "boom"))
 
@@ -151,3 +194,228 @@ func TestQueryRequeuesIfQueueError(t *testing.T) {
 	assert.Nil(t, err)
 	//assert.Equal(t, codes.Internal, grpc.Code(err))
 }
+
+func TestQueryReturnsFailedJob(t *testing.T) {
+	e := setup(t, 0, 0)
+	id := &wrappers.StringValue{Value: hashedID}
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, Status: jobs.StatusFailed, Error: "boom"}, nil)
+
+	i, err := e.Query(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_FAILED}, i.GetStatus())
+	assert.Equal(t, "boom", i.GetError())
+}
+
+func TestListJobsAppliesFilterAndPaging(t *testing.T) {
+	e := setup(t, 0, 0)
+	f := jobs.Filter{Owner: "tenant1", Status: jobs.StatusFailed}
+	mockJobs.On("List", f, 10, 5).Return([]*jobs.Job{&jobs.Job{ID: "abc"}}, true, nil)
+
+	resp, err := e.ListJobs(context.Background(), &emojify.ListJobsRequest{
+		Owner:     "tenant1",
+		Status:    emojify.QueryStatus_FAILED,
+		PageSize:  5,
+		PageToken: "10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, resp.GetJobs(), 1)
+	assert.Equal(t, "abc", resp.GetJobs()[0].GetId())
+	assert.Equal(t, "11", resp.GetNextPageToken())
+}
+
+func TestListJobsReturnsInvalidArgumentForBadPageToken(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.ListJobs(context.Background(), &emojify.ListJobsRequest{PageToken: "abc"})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+}
+
+func TestCreateReturnsInvalidArgumentForBadCallbackURL(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetCallbacks(true)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: "ftp://abc"})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestCreateReturnsInvalidArgumentForPrivateCallbackAddress(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetCallbacks(true)
+	e.SetCallbackPolicy(emoji.DefaultURLPolicy())
+
+	for _, cb := range []string{"http://127.0.0.1:8080/", "http://localhost/hook", "http://169.254.169.254/latest", "https://[::1]/", "http://10.0.0.1/"} {
+		_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: cb})
+
+		assert.Equal(t, codes.InvalidArgument, grpc.Code(err), cb)
+	}
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: "https://hooks.example.com/emojify"})
+	assert.Nil(t, err)
+}
+
+func TestCreateAllowsCallbackToAllowedPrivateAddress(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetCallbacks(true)
+	p := emoji.DefaultURLPolicy()
+	p.AllowAddresses = []string{"127.0.0.1:9091"}
+	e.SetCallbackPolicy(p)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: "http://localhost:9091/webhooks/test"})
+	assert.Nil(t, err)
+
+	_, err = e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: "http://localhost:9092/webhooks/test"})
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+}
+
+func TestCreateReturnsInvalidArgumentForCallbackWhenNotEnabled(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, CallbackUrl: "https://hooks.example.com/emojify"})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+type testSelection struct{ err error }
+
+func (s testSelection) ValidateSelection(options map[string]string, tenant string) error {
+	return s.err
+}
+
+func TestCreateReturnsInvalidArgumentForUnknownSelection(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetSelectionValidator(testSelection{fmt.Errorf("emoji %q does not exist", "1f4a9")})
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, Options: map[string]string{"codepoint": "1f4a9"}})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestCreateReturnsInvalidArgumentForRelativeURI(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: "abcde.com"})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+}
+
+func TestCreateReturnsLegacyItemIfInCache(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetLegacyIDLookup(true)
+	mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: base64URL}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+
+	i, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+	assert.Equal(t, base64URL, i.Id)
+	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_FINISHED}, i.GetStatus())
+}
+
+func TestQueryResolvesLegacyIDToHashedID(t *testing.T) {
+	e := setup(t, 0, 0)
+	e.SetLegacyIDLookup(true)
+	mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: base64URL}, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+
+	i, err := e.Query(context.Background(), &wrappers.StringValue{Value: base64URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, hashedID, i.GetId())
+	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_FINISHED}, i.GetStatus())
+}
+
+func TestQueryDoesNotResolveLegacyIDWhenDisabled(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	mockCache.On("Exists", mock.Anything, mock.Anything, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
+
+	e.Query(context.Background(), &wrappers.StringValue{Value: base64URL})
+
+	mockCache.AssertNotCalled(t, "Exists", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything)
+}
+
+func TestQueryReturnsFinishedForAliasOfCachedItem(t *testing.T) {
+	e := setup(t, 0, 0)
+	id := &wrappers.StringValue{Value: hashedID}
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, Status: jobs.StatusFinished, AliasOf: "orig"}, nil)
+	mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	mockCache.On("Exists", mock.Anything, id, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
+	mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: "orig"}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+
+	i, err := e.Query(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, hashedID, i.GetId())
+	assert.Equal(t, &emojify.QueryStatus{Status: emojify.QueryStatus_FINISHED}, i.GetStatus())
+	mockQueue.AssertNotCalled(t, "Position", mock.Anything)
+}
+
+func TestGetImageReturnsOriginalForAlias(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, AliasOf: "orig"}, nil)
+	mockCache.On("Get", mock.Anything, &wrappers.StringValue{Value: "orig"}, mock.Anything).Return(&cache.CacheItem{Id: "orig", Data: []byte("jpg")}, nil)
+
+	i, err := e.GetImage(context.Background(), &wrappers.StringValue{Value: hashedID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, hashedID, i.GetId())
+	assert.Equal(t, []byte("jpg"), i.GetData())
+}
+
+func TestCreateReturnsInvalidArgumentForUnsupportedFormat(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, Options: map[string]string{"format": "bmp"}})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestCreateReturnsInvalidArgumentForUnknownOption(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, Options: map[string]string{"nonce": "1"}})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestGetImageReturnsContentTypeOfJob(t *testing.T) {
+	e := setup(t, 0, 0)
+	mockJobs.ExpectedCalls = make([]*mock.Call, 0)
+	mockJobs.On("Get", hashedID).Return(&jobs.Job{ID: hashedID, ContentType: "image/png"}, nil)
+	mockCache.On("Get", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&cache.CacheItem{Id: hashedID, Data: []byte("png")}, nil)
+
+	i, err := e.GetImage(context.Background(), &wrappers.StringValue{Value: hashedID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "image/png", i.GetContentType())
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRequestSize is the largest request body accepted by the gateway
+const maxRequestSize = 1 << 20
+
+// Gateway is a http.Handler which exposes the Emojify API as HTTP/JSON
+//
+//	POST /v1/emojify              create a request from a JSON CreateRequest
+//	GET  /v1/emojify/{id}         query the status of a request
+//	GET  /v1/emojify/{id}/image   download the emojified image
+//	GET  /health                  health check
+type Gateway struct {
+	emojify *Emojify
+}
+
+// errorResponse is the JSON body returned for all errors
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewGateway creates a new HTTP gateway for the Emojify server
+func NewGateway(e *Emojify) *Gateway {
+	return &Gateway{e}
+}
+
+// ServeHTTP routes the request to the Emojify server method
+func (g *Gateway) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		g.health(rw, r)
+		return
+	}
+
+	if r.URL.Path == "/v1/emojify" || r.URL.Path == "/v1/emojify/" {
+		if r.Method != http.MethodPost {
+			writeErrorCode(rw, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed")
+			return
+		}
+
+		g.create(rw, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/emojify/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/v1/emojify/") || parts[0] == "" || len(parts) > 2 {
+		writeError(rw, status.Error(codes.NotFound, "not found"))
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeErrorCode(rw, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed")
+		return
+	}
+
+	id := &wrappers.StringValue{Value: parts[0]}
+
+	switch {
+	case len(parts) == 1:
+		g.query(rw, r, id)
+	case parts[1] == "image":
+		g.image(rw, r, id)
+	default:
+		writeError(rw, status.Error(codes.NotFound, "not found"))
+	}
+}
+
+func (g *Gateway) health(rw http.ResponseWriter, r *http.Request) {
+	resp, err := g.emojify.Check(r.Context(), &emojify.HealthCheckRequest{})
+	if err != nil {
+		writeError(rw, status.Error(codes.Unavailable, err.Error()))
+		return
+	}
+
+	writeMessage(rw, http.StatusOK, resp)
+}
+
+func (g *Gateway) create(rw http.ResponseWriter, r *http.Request) {
+	cr := &emojify.CreateRequest{}
+
+	err := jsonpb.Unmarshal(http.MaxBytesReader(rw, r.Body, maxRequestSize), cr)
+	if err != nil {
+		writeError(rw, status.Errorf(codes.InvalidArgument, "invalid request body: %s", err))
+		return
+	}
+
+	qi, err := g.emojify.Create(r.Context(), cr)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	// new work is accepted for processing, existing results are returned as is
+	code := http.StatusOK
+	if qi.GetStatus().GetStatus() == emojify.QueryStatus_QUEUED {
+		code = http.StatusAccepted
+	}
+
+	writeMessage(rw, code, qi)
+}
+
+func (g *Gateway) query(rw http.ResponseWriter, r *http.Request, id *wrappers.StringValue) {
+	qi, err := g.emojify.Query(r.Context(), id)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	if qi == nil {
+		writeError(rw, status.Errorf(codes.NotFound, "%s not found", id.GetValue()))
+		return
+	}
+
+	writeMessage(rw, http.StatusOK, qi)
+}
+
+func (g *Gateway) image(rw http.ResponseWriter, r *http.Request, id *wrappers.StringValue) {
+	img, err := g.emojify.GetImage(r.Context(), id)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", img.GetContentType())
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(img.GetData())
+}
+
+func writeMessage(rw http.ResponseWriter, code int, m proto.Message) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+
+	ma := jsonpb.Marshaler{}
+	ma.Marshal(rw, m)
+}
+
+// writeError writes a JSON error with the HTTP status derived from the
+// gRPC status code of the error
+func writeError(rw http.ResponseWriter, err error) {
+	c := grpc.Code(err)
+
+	writeErrorCode(rw, httpStatus(c), strings.ToUpper(toSnake(c.String())), status.Convert(err).Message())
+}
+
+func writeErrorCode(rw http.ResponseWriter, httpCode int, code, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(httpCode)
+
+	json.NewEncoder(rw).Encode(errorResponse{
+		Error: errorDetail{Code: code, Message: message},
+	})
+}
+
+// httpStatus maps gRPC status codes to HTTP status codes
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	}
+
+	return http.StatusInternalServerError
+}
+
+// toSnake converts a CamelCase gRPC code name to SNAKE_CASE
+func toSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emojify-app/cache/protos/cache"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func setupGateway(t *testing.T, pos, ql int) *Gateway {
+	return NewGateway(setup(t, pos, ql))
+}
+
+func doRequest(g *Gateway, method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+
+	g.ServeHTTP(rr, r)
+
+	return rr
+}
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) errorDetail {
+	er := errorResponse{}
+	err := json.Unmarshal(rr.Body.Bytes(), &er)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return er.Error
+}
+
+func TestGatewayCreateReturnsAccepted(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodPost, "/v1/emojify", `{"uri": "http://abcde.com"}`)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Contains(t, rr.Body.String(), hashedID)
+	assert.Contains(t, rr.Body.String(), `"QUEUED"`)
+}
+
+func TestGatewayCreateReturnsBadRequestForInvalidJSON(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodPost, "/v1/emojify", `{"uri": `)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "INVALID_ARGUMENT", decodeError(t, rr).Code)
+}
+
+func TestGatewayCreateReturnsBadRequestForInvalidURI(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodPost, "/v1/emojify", `{"uri": "abcde"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGatewayCreateReturnsMethodNotAllowed(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify", "")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "METHOD_NOT_ALLOWED", decodeError(t, rr).Code)
+}
+
+func TestGatewayQueryReturnsItem(t *testing.T) {
+	g := setupGateway(t, 2, 3)
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify/"+hashedID, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"queuePosition":2`)
+}
+
+func TestGatewayQueryReturnsNotFound(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify/"+hashedID, "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "NOT_FOUND", decodeError(t, rr).Code)
+}
+
+func TestGatewayImageReturnsData(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+	mockCache.On("Get", mock.Anything, &wrappers.StringValue{Value: hashedID}, mock.Anything).Return(&cache.CacheItem{Id: hashedID, Data: []byte("jpg")}, nil)
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify/"+hashedID+"/image", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "jpg", rr.Body.String())
+}
+
+func TestGatewayImageReturnsNotFound(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, grpc.Errorf(codes.NotFound, "nope"))
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify/"+hashedID+"/image", "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGatewayUnknownPathReturnsNotFound(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodGet, "/v1/emojify/abc/def", "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGatewayHealthReturnsOK(t *testing.T) {
+	g := setupGateway(t, 0, 0)
+
+	rr := doRequest(g, http.MethodGet, "/health", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "SERVING")
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var idPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// canonicalURL normalises a URL so that trivially different forms of
+// the same address produce the same job ID. The scheme and host are
+// lower cased, default ports and fragments are removed, an empty path
+// becomes / and query parameters are sorted by key. Opaque URLs such as
+// data: URIs only have the scheme lower cased.
+func canonicalURL(uri string) (string, error) {
+	uri = strings.TrimSpace(uri)
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "" {
+		return "", fmt.Errorf("url must be absolute")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	if u.Opaque != "" && u.Scheme == "data" {
+		return u.Scheme + uri[len(u.Scheme):], nil
+	}
+
+	// file URLs refer to the local host and do not need one
+	if u.Opaque != "" || (u.Host == "" && u.Scheme != "file") {
+		return "", fmt.Errorf("url must be absolute")
+	}
+	u.Host = strings.ToLower(u.Host)
+
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	u.Fragment = ""
+	u.RawQuery = u.Query().Encode()
+
+	return u.String(), nil
+}
+
+// jobID returns the SHA-256 of the canonical URL and the processing options
+func jobID(uri string, options map[string]string) (string, error) {
+	c, err := canonicalURL(uri)
+	if err != nil {
+		return "", err
+	}
+
+	o := url.Values{}
+	for k, v := range options {
+		o.Set(k, v)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c))
+	h.Write([]byte{0})
+	h.Write([]byte(o.Encode()))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// legacyID returns the ID used before content hashing was introduced
+func legacyID(uri string) string {
+	return base64.URLEncoding.EncodeToString([]byte(uri))
+}
+
+// legacyURI decodes a legacy ID back to the URI it was generated from,
+// ok is false when the ID is not a legacy ID
+func legacyURI(id string) (uri string, ok bool) {
+	if idPattern.MatchString(id) {
+		return "", false
+	}
+
+	d, err := base64.URLEncoding.DecodeString(id)
+	if err != nil {
+		return "", false
+	}
+
+	if _, err := canonicalURL(string(d)); err != nil {
+		return "", false
+	}
+
+	return string(d), true
+}
+
+// ownedJobID scopes a job ID to the owner so authenticated callers who
+// submit the same URL each have their own job
+func ownedJobID(id, owner string) string {
+	h := sha256.New()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(owner))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalURLNormalisesEquivalentURLs(t *testing.T) {
+	urls := []string{
+		"http://abcde.com/a.jpg?x=1&y=2",
+		"HTTP://ABCDE.com/a.jpg?y=2&x=1",
+		"http://abcde.com:80/a.jpg?x=1&y=2#face",
+		" http://abcde.com/a.jpg?y=2&x=1 ",
+	}
+
+	for _, u := range urls {
+		c, err := canonicalURL(u)
+
+		assert.Nil(t, err, u)
+		assert.Equal(t, "http://abcde.com/a.jpg?x=1&y=2", c, u)
+	}
+}
+
+func TestCanonicalURLAddsRootPath(t *testing.T) {
+	c, err := canonicalURL("https://abcde.com:443")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://abcde.com/", c)
+}
+
+func TestCanonicalURLKeepsPathCase(t *testing.T) {
+	c, err := canonicalURL("http://abcde.com/A.jpg")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://abcde.com/A.jpg", c)
+}
+
+func TestCanonicalURLAcceptsDataAndFileURLs(t *testing.T) {
+	c, err := canonicalURL("DATA:image/png;base64,iVBORw0KGgo=")
+	assert.Nil(t, err)
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", c)
+
+	c, err = canonicalURL("file:///images/a.jpg")
+	assert.Nil(t, err)
+	assert.Equal(t, "file:///images/a.jpg", c)
+
+	_, err = canonicalURL("localhost:8080/a.jpg")
+	assert.Error(t, err)
+}
+
+func TestCanonicalURLReturnsErrorForRelativeURL(t *testing.T) {
+	_, err := canonicalURL("abcde.com/a.jpg")
+
+	assert.Error(t, err)
+}
+
+func TestJobIDIsStableForEquivalentURLs(t *testing.T) {
+	a, _ := jobID("http://abcde.com?x=1&y=2", map[string]string{"a": "1", "b": "2"})
+	b, _ := jobID("http://ABCDE.com/?y=2&x=1", map[string]string{"b": "2", "a": "1"})
+
+	assert.Equal(t, a, b)
+	assert.Len(t, a, 64)
+}
+
+func TestJobIDChangesWithOptions(t *testing.T) {
+	a, _ := jobID(testURL, nil)
+	b, _ := jobID(testURL, map[string]string{"a": "1"})
+
+	assert.NotEqual(t, a, b)
+}
+
+func TestLegacyURIDecodesLegacyIDs(t *testing.T) {
+	u, ok := legacyURI(base64URL)
+
+	assert.True(t, ok)
+	assert.Equal(t, testURL, u)
+}
+
+func TestLegacyURIIgnoresHashedIDs(t *testing.T) {
+	id, _ := jobID(testURL, nil)
+
+	_, ok := legacyURI(id)
+
+	assert.False(t, ok)
+}
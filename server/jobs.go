@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emojify-app/emojify/auth"
+	"github.com/emojify-app/emojify/jobs"
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultPageSize is used when ListJobs is called without a page size
+const defaultPageSize = 50
+
+// maxPageSize is the largest number of jobs returned by a single call to ListJobs
+const maxPageSize = 500
+
+// ListJobs returns a page of jobs matching the filter in the request
+func (e *Emojify) ListJobs(ctx context.Context, r *emojify.ListJobsRequest) (*emojify.ListJobsResponse, error) {
+	done := e.logger.ListJobs(r.GetOwner())
+
+	f := jobs.Filter{
+		Owner:  r.GetOwner(),
+		Status: jobs.Status(r.GetStatus()),
+	}
+
+	// listing jobs for other owners is restricted to admins
+	if c, ok := auth.FromContext(ctx); ok && !c.IsAdmin() {
+		if f.Owner != "" && f.Owner != c.ID {
+			err := authorize(ctx, f.Owner)
+			done(http.StatusForbidden, err)
+			return nil, err
+		}
+
+		f.Owner = c.ID
+	}
+
+	var err error
+	if f.AddedAfter, err = fromTimestamp(r.GetAddedAfter()); err != nil {
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid addedAfter: %s", err)
+	}
+
+	if f.AddedBefore, err = fromTimestamp(r.GetAddedBefore()); err != nil {
+		done(http.StatusBadRequest, err)
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid addedBefore: %s", err)
+	}
+
+	size := int(r.GetPageSize())
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+
+	// the page token is the offset of the next result
+	offset := 0
+	if r.GetPageToken() != "" {
+		offset, err = strconv.Atoi(r.GetPageToken())
+		if err != nil || offset < 0 {
+			done(http.StatusBadRequest, err)
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid pageToken")
+		}
+	}
+
+	js, more, err := e.jobs.List(f, offset, size)
+	if err != nil {
+		done(http.StatusInternalServerError, err)
+		return nil, grpc.Errorf(codes.Internal, "unable to list jobs: %s", err)
+	}
+
+	resp := &emojify.ListJobsResponse{}
+	for _, j := range js {
+		resp.Jobs = append(resp.Jobs, jobToProto(j))
+	}
+
+	if more {
+		resp.NextPageToken = strconv.Itoa(offset + len(js))
+	}
+
+	done(http.StatusOK, nil)
+	return resp, nil
+}
+
+// saveJob writes the job to the store
+func (e *Emojify) saveJob(j *jobs.Job) error {
+	done := e.logger.JobSave(j.ID)
+
+	err := e.jobs.Save(j)
+	if err != nil {
+		done(http.StatusInternalServerError, err)
+		return err
+	}
+
+	done(http.StatusOK, nil)
+	return nil
+}
+
+// jobStatus returns a QueryItem built from the job record, or nil
+// if there is no record of a failed job
+func (e *Emojify) jobStatus(id string) *emojify.QueryItem {
+	j, err := e.jobs.Get(id)
+	if err != nil {
+		if err != jobs.ErrNotFound {
+			e.logger.Log().Error("Unable to get job", "id", id, "error", err)
+		}
+
+		return nil
+	}
+
+	if j.Status != jobs.StatusFailed {
+		return nil
+	}
+
+	return &emojify.QueryItem{
+		Id:        id,
+		Status:    &emojify.QueryStatus{Status: emojify.QueryStatus_FAILED},
+		Error:     j.Error,
+		Retryable: j.Retryable,
+		Reason:    j.Reason,
+	}
+}
+
+// aliasCached returns true when the job is an alias of another job
+// whose output is in the cache
+func (e *Emojify) aliasCached(id string) bool {
+	j, err := e.jobs.Get(id)
+	if err != nil || j.AliasOf == "" {
+		return false
+	}
+
+	ok, err := e.cache.Exists(context.Background(), &wrappers.StringValue{Value: j.AliasOf})
+	if err != nil {
+		e.logger.Log().Error("Unable to check cache for alias", "id", id, "alias", j.AliasOf, "error", err)
+		return false
+	}
+
+	return ok.GetValue()
+}
+
+func jobToProto(j *jobs.Job) *emojify.Job {
+	return &emojify.Job{
+		Id:          j.ID,
+		Uri:         j.URI,
+		Owner:       j.Owner,
+		Options:     j.Options,
+		Added:       toTimestamp(j.Added),
+		Updated:     toTimestamp(j.Updated),
+		Completed:   toTimestamp(j.Completed),
+		Status:      emojify.QueryStatus_QueryStatus(j.Status),
+		Error:       j.Error,
+		FaceCount:   int32(j.FaceCount),
+		CallbackUrl: j.CallbackURL,
+		Retryable:   j.Retryable,
+		Reason:      j.Reason,
+		InputFormat: j.InputFormat,
+		ContentType: j.ContentType,
+	}
+}
+
+func toTimestamp(t time.Time) *timestamp.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	ts, _ := ptypes.TimestampProto(t)
+	return ts
+}
+
+func fromTimestamp(ts *timestamp.Timestamp) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, nil
+	}
+
+	return ptypes.Timestamp(ts)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/emojify-app/emojify/protos/emojify"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ListPacks returns the emoji packs which requests can select
+func (e *Emojify) ListPacks(ctx context.Context, r *emojify.ListPacksRequest) (*emojify.ListPacksResponse, error) {
+	done := e.logger.ListPacks()
+
+	if e.packs == nil {
+		err := grpc.Errorf(codes.Unimplemented, "emoji packs are not available")
+		done(http.StatusNotImplemented, err)
+		return nil, err
+	}
+
+	resp := &emojify.ListPacksResponse{}
+	for _, p := range e.packs.ListPacks() {
+		resp.Packs = append(resp.Packs, &emojify.Pack{
+			Name:       p.Name,
+			EmojiCount: int32(p.EmojiCount),
+			Licence:    p.Licence,
+			Tags:       p.Tags,
+			IsDefault:  p.Name == e.packs.DefaultPack(),
+		})
+	}
+
+	done(http.StatusOK, nil)
+	return resp, nil
+}
+
+func hasPack(l PackLister, name string) bool {
+	for _, p := range l.ListPacks() {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
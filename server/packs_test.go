@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	emoji "github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testPacks []emoji.PackInfo
+
+func (p testPacks) ListPacks() []emoji.PackInfo { return p }
+func (p testPacks) DefaultPack() string         { return emoji.DefaultPack }
+
+func setupPacks(t *testing.T) *Emojify {
+	e := setup(t, 0, 0)
+	e.SetPacks(testPacks{
+		{Name: emoji.DefaultPack, EmojiCount: 67, Tags: []string{"happy"}},
+		{Name: "winter", EmojiCount: 12, Licence: "CC0-1.0", Tags: []string{}},
+	})
+
+	return e
+}
+
+func TestListPacksReturnsLoadedPacks(t *testing.T) {
+	e := setupPacks(t)
+
+	resp, err := e.ListPacks(context.Background(), &emojify.ListPacksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, resp.GetPacks(), 2)
+	assert.Equal(t, &emojify.Pack{Name: emoji.DefaultPack, EmojiCount: 67, Tags: []string{"happy"}, IsDefault: true}, resp.GetPacks()[0])
+	assert.Equal(t, "CC0-1.0", resp.GetPacks()[1].GetLicence())
+	assert.False(t, resp.GetPacks()[1].GetIsDefault())
+}
+
+func TestListPacksReturnsUnimplementedWithoutPacks(t *testing.T) {
+	e := setup(t, 0, 0)
+
+	_, err := e.ListPacks(context.Background(), &emojify.ListPacksRequest{})
+
+	assert.Equal(t, codes.Unimplemented, grpc.Code(err))
+}
+
+func TestCreateReturnsInvalidArgumentForUnknownPack(t *testing.T) {
+	e := setupPacks(t)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, Options: map[string]string{"pack": "summer"}})
+
+	assert.Equal(t, codes.InvalidArgument, grpc.Code(err))
+	mockQueue.AssertNotCalled(t, "Push", mock.Anything)
+}
+
+func TestCreateAcceptsKnownPack(t *testing.T) {
+	e := setupPacks(t)
+
+	_, err := e.Create(context.Background(), &emojify.CreateRequest{Uri: testURL, Options: map[string]string{"pack": "winter"}})
+
+	assert.Nil(t, err)
+}
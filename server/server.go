@@ -4,10 +4,7 @@ import (
 	"fmt"
 	"net"
 
-	"github.com/emojify-app/cache/protos/cache"
-	"github.com/emojify-app/emojify/logging"
 	"github.com/emojify-app/emojify/protos/emojify"
-	"github.com/emojify-app/emojify/queue"
 	"google.golang.org/grpc"
 )
 
@@ -15,10 +12,11 @@ var lis net.Listener
 
 var grpcServer *grpc.Server
 
-// Start a new instance of the server
-func Start(address string, port int, l logging.Logger, c cache.CacheClient, q queue.Queue) error {
-	grpcServer = grpc.NewServer()
-	emojify.RegisterEmojifyServer(grpcServer, &Emojify{q, c, l})
+// Start a new instance of the server, options such as transport credentials
+// are passed to the gRPC server
+func Start(address string, port int, e *Emojify, opts ...grpc.ServerOption) error {
+	grpcServer = grpc.NewServer(opts...)
+	emojify.RegisterEmojifyServer(grpcServer, e)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", address, port))
 	if err != nil {
@@ -0,0 +1,287 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Config holds certificates and certificate authorities loaded from disk,
+// the files are reloaded when they change so certificates can be rotated
+// without restarting the process
+type Config struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	allowed  map[string]bool
+	logger   hclog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+}
+
+// NewServer creates a Config for a server, when clientCAFile is set clients
+// must present a certificate signed by the CA, when allowed is not empty the
+// client certificate must also contain one of the allowed identities
+func NewServer(certFile, keyFile, clientCAFile string, allowed []string, l hclog.Logger) (*Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("certificate and key are required")
+	}
+
+	return newConfig(certFile, keyFile, clientCAFile, allowed, l)
+}
+
+// NewClient creates a Config for a client, when caFile is empty the system
+// roots are used to verify the server, certFile and keyFile are optional
+// and only required when the server requires client certificates
+func NewClient(caFile, certFile, keyFile string, l hclog.Logger) (*Config, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("certificate and key must both be set")
+	}
+
+	return newConfig(certFile, keyFile, caFile, nil, l)
+}
+
+func newConfig(certFile, keyFile, caFile string, allowed []string, l hclog.Logger) (*Config, error) {
+	c := &Config{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		allowed:  map[string]bool{},
+		logger:   l,
+		modTime:  map[string]time.Time{},
+	}
+
+	for _, a := range allowed {
+		c.allowed[a] = true
+	}
+
+	_, err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload reads the certificate files from disk if they have been modified
+// since they were last loaded, returns true when files were reloaded
+func (c *Config) Reload() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reloaded := false
+
+	if c.certFile != "" && (c.changed(c.certFile) || c.changed(c.keyFile)) {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return false, fmt.Errorf("unable to load key pair: %s", err)
+		}
+
+		c.cert = &cert
+		c.touch(c.certFile)
+		c.touch(c.keyFile)
+		reloaded = true
+	}
+
+	if c.caFile != "" && c.changed(c.caFile) {
+		d, err := ioutil.ReadFile(c.caFile)
+		if err != nil {
+			return false, fmt.Errorf("unable to read CA file: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(d) {
+			return false, fmt.Errorf("no certificates found in CA file %s", c.caFile)
+		}
+
+		c.pool = pool
+		c.touch(c.caFile)
+		reloaded = true
+	}
+
+	return reloaded, nil
+}
+
+// Watch polls the certificate files every interval and reloads them when
+// they change, it blocks until stop is closed
+func (c *Config) Watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ok, err := c.Reload()
+			if err != nil {
+				// keep serving the previous certificates until the files are valid
+				c.logger.Error("Unable to reload TLS certificates", "error", err)
+				continue
+			}
+
+			if ok {
+				c.logger.Info("Reloaded TLS certificates", "cert", c.certFile, "ca", c.caFile)
+			}
+		}
+	}
+}
+
+// ServerTLS returns a tls.Config for a server, each handshake uses the
+// most recently loaded certificates
+func (c *Config) ServerTLS() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.serverTLS(), nil
+		},
+	}
+}
+
+func (c *Config) serverTLS() *tls.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*c.cert},
+	}
+
+	if c.pool != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = c.pool
+		cfg.VerifyPeerCertificate = c.verifyIdentity
+	}
+
+	return cfg
+}
+
+// ClientTLS returns a tls.Config for a client connecting to serverName,
+// each handshake uses the most recently loaded certificates. The server
+// certificate must be valid for serverName, handshakes fail when it is empty.
+func (c *Config) ClientTLS(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if c.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			return c.cert, nil
+		}
+	}
+
+	// the default verification uses a fixed pool of roots, to allow the CA
+	// to be reloaded the chain is verified against the current pool instead
+	if c.caFile != "" {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return c.verifyServer(serverName, raw)
+		}
+	}
+
+	return cfg
+}
+
+// Identities returns the identities contained in a certificate, the
+// common name, DNS names, URIs and email addresses
+func Identities(cert *x509.Certificate) []string {
+	ids := []string{}
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+
+	ids = append(ids, cert.DNSNames...)
+	ids = append(ids, cert.EmailAddresses...)
+
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+
+	return ids
+}
+
+// verifyIdentity checks the verified client certificate contains an allowed identity
+func (c *Config) verifyIdentity(_ [][]byte, chains [][]*x509.Certificate) error {
+	if len(c.allowed) == 0 {
+		return nil
+	}
+
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return fmt.Errorf("no verified client certificate")
+	}
+
+	for _, id := range Identities(chains[0][0]) {
+		if c.allowed[id] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client certificate identity is not allowed")
+}
+
+func (c *Config) verifyServer(serverName string, raw [][]byte) error {
+	// an empty DNSName would skip the hostname check
+	if serverName == "" {
+		return fmt.Errorf("a server name is required to verify the server certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(raw))
+	for i, r := range raw {
+		cert, err := x509.ParseCertificate(r)
+		if err != nil {
+			return fmt.Errorf("unable to parse server certificate: %s", err)
+		}
+
+		certs[i] = cert
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+
+	c.mu.RLock()
+	pool := c.pool
+	c.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func (c *Config) changed(file string) bool {
+	fi, err := os.Stat(file)
+	if err != nil {
+		// let the load return the error
+		return true
+	}
+
+	return !fi.ModTime().Equal(c.modTime[file])
+}
+
+func (c *Config) touch(file string) {
+	if fi, err := os.Stat(file); err == nil {
+		c.modTime[file] = fi.ModTime()
+	}
+}
@@ -0,0 +1,244 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newCA(t *testing.T) *testCA {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	ca := &testCA{cert, key, dir}
+	writePEM(t, ca.path("ca.pem"), "CERTIFICATE", der)
+
+	return ca
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue writes a certificate and key signed by the CA to name.pem and name-key.pem
+func (ca *testCA) issue(t *testing.T, name, cn string, serial int64) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kd, _ := x509.MarshalECPrivateKey(key)
+	writePEM(t, ca.path(name+".pem"), "CERTIFICATE", der)
+	writePEM(t, ca.path(name+"-key.pem"), "EC PRIVATE KEY", kd)
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	d := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := ioutil.WriteFile(path, d, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects a client and server over the loopback interface and
+// returns the server certificate seen by the client
+func handshake(server, client *tls.Config) (*x509.Certificate, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer c.Close()
+
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		errs <- tls.Server(c, server).Handshake()
+	}()
+
+	cc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+
+	cc.SetDeadline(time.Now().Add(5 * time.Second))
+	c := tls.Client(cc, client)
+	err = c.Handshake()
+	serr := <-errs
+
+	if err != nil {
+		return nil, err
+	}
+
+	if serr != nil {
+		return nil, serr
+	}
+
+	return c.ConnectionState().PeerCertificates[0], nil
+}
+
+func setup(t *testing.T, allowed []string) (*testCA, *Config) {
+	ca := newCA(t)
+	ca.issue(t, "server", "localhost", 2)
+	ca.issue(t, "worker", "worker", 3)
+	ca.issue(t, "intruder", "intruder", 4)
+
+	s, err := NewServer(ca.path("server.pem"), ca.path("server-key.pem"), ca.path("ca.pem"), allowed, hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ca, s
+}
+
+func client(t *testing.T, ca *testCA, name string) *tls.Config {
+	c, err := NewClient(ca.path("ca.pem"), ca.path(name+".pem"), ca.path(name+"-key.pem"), hclog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c.ClientTLS("localhost")
+}
+
+func TestMutualTLSAcceptsAllowedIdentity(t *testing.T) {
+	ca, s := setup(t, []string{"worker"})
+	defer os.RemoveAll(ca.dir)
+
+	cert, err := handshake(s.ServerTLS(), client(t, ca, "worker"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost", cert.Subject.CommonName)
+}
+
+func TestMutualTLSRejectsUnknownIdentity(t *testing.T) {
+	ca, s := setup(t, []string{"worker"})
+	defer os.RemoveAll(ca.dir)
+
+	_, err := handshake(s.ServerTLS(), client(t, ca, "intruder"))
+
+	assert.Error(t, err)
+}
+
+func TestMutualTLSRejectsClientWithoutCertificate(t *testing.T) {
+	ca, s := setup(t, nil)
+	defer os.RemoveAll(ca.dir)
+
+	c, _ := NewClient(ca.path("ca.pem"), "", "", hclog.Default())
+	_, err := handshake(s.ServerTLS(), c.ClientTLS("localhost"))
+
+	assert.Error(t, err)
+}
+
+func TestClientRejectsServerWithWrongName(t *testing.T) {
+	ca, s := setup(t, nil)
+	defer os.RemoveAll(ca.dir)
+
+	c, _ := NewClient(ca.path("ca.pem"), ca.path("worker.pem"), ca.path("worker-key.pem"), hclog.Default())
+	_, err := handshake(s.ServerTLS(), c.ClientTLS("cache.internal"))
+
+	assert.Error(t, err)
+}
+
+func TestClientRejectsServerWithoutServerName(t *testing.T) {
+	ca, s := setup(t, nil)
+	defer os.RemoveAll(ca.dir)
+
+	c, _ := NewClient(ca.path("ca.pem"), ca.path("worker.pem"), ca.path("worker-key.pem"), hclog.Default())
+	cfg := c.ClientTLS("")
+	// the server name is normally set from the dialed address by grpc
+	cfg.ServerName = "localhost"
+
+	_, err := handshake(s.ServerTLS(), cfg)
+
+	assert.Error(t, err)
+}
+
+func TestReloadServesNewCertificate(t *testing.T) {
+	ca, s := setup(t, nil)
+	defer os.RemoveAll(ca.dir)
+
+	ca.issue(t, "server", "localhost", 99)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(ca.path("server.pem"), future, future)
+	os.Chtimes(ca.path("server-key.pem"), future, future)
+
+	ok, err := s.Reload()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	cert, err := handshake(s.ServerTLS(), client(t, ca, "worker"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(99), cert.SerialNumber.Int64())
+}
+
+func TestReloadKeepsPreviousCertificateOnError(t *testing.T) {
+	ca, s := setup(t, nil)
+	defer os.RemoveAll(ca.dir)
+
+	ioutil.WriteFile(ca.path("server.pem"), []byte("nope"), 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(ca.path("server.pem"), future, future)
+
+	_, err := s.Reload()
+	assert.Error(t, err)
+
+	_, err = handshake(s.ServerTLS(), client(t, ca, "worker"))
+	assert.Nil(t, err)
+}
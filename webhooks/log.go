@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"sync"
+	"time"
+)
+
+// Delivery is a record of a single attempt to deliver a webhook
+type Delivery struct {
+	// ID of the job the webhook relates to
+	ID string
+	// URL the payload was sent to
+	URL string
+	// Attempt number starting at 1
+	Attempt int
+	// StatusCode returned by the receiver, 0 when no response was received
+	StatusCode int
+	// Error is empty when the delivery succeeded
+	Error string
+	// Time of the attempt
+	Time time.Time
+}
+
+// DeliveryLog defines an interface for recording webhook delivery attempts
+type DeliveryLog interface {
+	Record(Delivery)
+	// Deliveries returns the recorded attempts for a job
+	Deliveries(id string) []Delivery
+}
+
+// MemoryLog keeps the most recent delivery attempts in memory
+type MemoryLog struct {
+	size       int
+	deliveries []Delivery
+	mu         sync.Mutex
+}
+
+// NewMemoryLog creates a log which retains the last size attempts
+func NewMemoryLog(size int) *MemoryLog {
+	return &MemoryLog{size: size}
+}
+
+// Record adds a delivery attempt to the log, removing the oldest entry
+// when the log is full
+func (m *MemoryLog) Record(d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries = append(m.deliveries, d)
+	if len(m.deliveries) > m.size {
+		m.deliveries = m.deliveries[len(m.deliveries)-m.size:]
+	}
+}
+
+// Deliveries returns the recorded attempts for a job
+func (m *MemoryLog) Deliveries(id string) []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ds := make([]Delivery, 0)
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			ds = append(ds, d)
+		}
+	}
+
+	return ds
+}
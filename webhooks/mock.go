@@ -0,0 +1,16 @@
+package webhooks
+
+import (
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDispatcher is a mock implementation of the Dispatcher interface
+type MockDispatcher struct {
+	mock.Mock
+}
+
+// Dispatch is a mock implementation of the interface method
+func (m *MockDispatcher) Dispatch(url string, qi *emojify.QueryItem) {
+	m.Called(url, qi)
+}
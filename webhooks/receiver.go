@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hashicorp/go-hclog"
+)
+
+// receivedSize is the number of delivered items a Receiver retains
+const receivedSize = 100
+
+// Receiver is a http.Handler which accepts and verifies webhooks, it is
+// used to test webhook delivery without an external service
+type Receiver struct {
+	secret   []byte
+	logger   hclog.Logger
+	size     int
+	received []*emojify.QueryItem
+	mu       sync.Mutex
+}
+
+// NewReceiver creates a new Receiver which verifies payloads with secret,
+// only the most recent items are retained
+func NewReceiver(secret string, l hclog.Logger) *Receiver {
+	return &Receiver{secret: []byte(secret), logger: l, size: receivedSize}
+}
+
+// ServeHTTP handles a webhook delivery
+func (r *Receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !Verify(r.secret, req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader), body) {
+		r.logger.Error("Webhook signature invalid")
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	qi := &emojify.QueryItem{}
+	err = jsonpb.Unmarshal(bytes.NewReader(body), qi)
+	if err != nil {
+		r.logger.Error("Unable to unmarshal webhook", "error", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.logger.Info("Webhook received", "id", qi.GetId(), "status", qi.GetStatus().GetStatus(), "error", qi.GetError())
+
+	r.mu.Lock()
+	r.received = append(r.received, qi)
+	if len(r.received) > r.size {
+		r.received = r.received[len(r.received)-r.size:]
+	}
+	r.mu.Unlock()
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// Received returns the most recent items which have been successfully delivered
+func (r *Receiver) Received() []*emojify.QueryItem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*emojify.QueryItem{}, r.received...)
+}
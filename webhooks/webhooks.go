@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hashicorp/go-hclog"
+)
+
+// SignatureHeader contains the hex encoded HMAC-SHA256 of the payload
+const SignatureHeader = "X-Emojify-Signature"
+
+// TimestampHeader contains the unix time the payload was signed, the
+// timestamp is included in the signature to prevent replay
+const TimestampHeader = "X-Emojify-Timestamp"
+
+// TimestampTolerance is the maximum difference between the signed timestamp
+// and the current time before a payload is rejected as a replay
+const TimestampTolerance = 5 * time.Minute
+
+// Dispatcher defines an interface for notifying callers that a job has completed
+type Dispatcher interface {
+	// Dispatch sends the item to the callback url in the background
+	Dispatch(url string, qi *emojify.QueryItem)
+}
+
+// HTTPDispatcher POSTs signed QueryItems to callback URLs, retrying
+// failed deliveries with an exponential backoff
+type HTTPDispatcher struct {
+	httpClient  *http.Client
+	secret      []byte
+	maxAttempts int
+	backoff     time.Duration
+	log         DeliveryLog
+	logger      hclog.Logger
+}
+
+// NewDispatcher creates a new HTTPDispatcher, payloads are signed with
+// secret and failed deliveries are attempted up to maxAttempts times
+// waiting backoff, 2*backoff, 4*backoff... between each attempt
+func NewDispatcher(secret string, maxAttempts int, backoff time.Duration, dl DeliveryLog, l hclog.Logger) *HTTPDispatcher {
+	c := &http.Client{
+		Timeout: 10 * time.Second,
+		// redirects are not followed, a callback must accept the POST
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &HTTPDispatcher{
+		httpClient:  c,
+		secret:      []byte(secret),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		log:         dl,
+		logger:      l,
+	}
+}
+
+// SetDialer sets the dialer used to connect to callback URLs, the dialer
+// checks the address of every connection so callbacks can not reach
+// private addresses
+func (d *HTTPDispatcher) SetDialer(dialer *net.Dialer) {
+	d.httpClient.Transport = &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// Dispatch sends the item to the callback url in the background
+func (d *HTTPDispatcher) Dispatch(url string, qi *emojify.QueryItem) {
+	go func() {
+		err := d.Deliver(url, qi)
+		if err != nil {
+			d.logger.Error("Unable to deliver webhook", "id", qi.GetId(), "url", url, "error", err)
+		}
+	}()
+}
+
+// Deliver sends the item to the callback url blocking until the delivery
+// succeeds or all attempts have been exhausted
+func (d *HTTPDispatcher) Deliver(url string, qi *emojify.QueryItem) error {
+	m := jsonpb.Marshaler{}
+	body, err := m.MarshalToString(qi)
+	if err != nil {
+		return fmt.Errorf("unable to marshal item: %s", err)
+	}
+
+	delay := d.backoff
+	for attempt := 1; ; attempt++ {
+		status, retry, err := d.post(url, []byte(body))
+
+		d.log.Record(Delivery{
+			ID:         qi.GetId(),
+			URL:        url,
+			Attempt:    attempt,
+			StatusCode: status,
+			Error:      errorString(err),
+			Time:       time.Now(),
+		})
+
+		if err == nil {
+			d.logger.Debug("Webhook delivered", "id", qi.GetId(), "url", url, "attempt", attempt)
+			return nil
+		}
+
+		if !retry || attempt >= d.maxAttempts {
+			return err
+		}
+
+		d.logger.Debug("Webhook delivery failed, retrying", "id", qi.GetId(), "url", url, "attempt", attempt, "error", err)
+
+		time.Sleep(delay)
+		delay = delay * 2
+	}
+}
+
+// post sends a single request, retry is true when the failure is transient
+func (d *HTTPDispatcher) post(url string, body []byte) (status int, retry bool, err error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(d.secret, ts, body))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	// server errors and rate limits may succeed later, other client
+	// errors will not
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return resp.StatusCode, retry, fmt.Errorf("callback returned status %d", resp.StatusCode)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header value matches the timestamp and body
+// and that the timestamp is within TimestampTolerance of the current time
+func Verify(secret []byte, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(ts, 0))
+	if age > TimestampTolerance || age < -TimestampTolerance {
+		return false
+	}
+
+	expected := "sha256=" + Sign(secret, timestamp, body)
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	emoji "github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/protos/emojify"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = "s3cret"
+
+var item = &emojify.QueryItem{
+	Id:     "abc123",
+	Status: &emojify.QueryStatus{Status: emojify.QueryStatus_FAILED},
+	Error:  "unable to fetch image",
+}
+
+func setup(t *testing.T, failures int) (*HTTPDispatcher, *Receiver, *MemoryLog, *httptest.Server) {
+	l := hclog.Default()
+	r := NewReceiver(secret, l)
+	dl := NewMemoryLog(10)
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		count++
+		if count <= failures {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		r.ServeHTTP(rw, req)
+	}))
+
+	return NewDispatcher(secret, 3, 1*time.Millisecond, dl, l), r, dl, ts
+}
+
+func TestDeliverSendsSignedItem(t *testing.T) {
+	d, r, dl, ts := setup(t, 0)
+	defer ts.Close()
+
+	err := d.Deliver(ts.URL, item)
+
+	assert.Nil(t, err)
+	assert.Len(t, r.Received(), 1)
+	assert.Equal(t, "abc123", r.Received()[0].GetId())
+	assert.Equal(t, emojify.QueryStatus_FAILED, r.Received()[0].GetStatus().GetStatus())
+	assert.Equal(t, "unable to fetch image", r.Received()[0].GetError())
+	assert.Len(t, dl.Deliveries("abc123"), 1)
+}
+
+func TestDeliverRetriesServerErrors(t *testing.T) {
+	d, r, dl, ts := setup(t, 2)
+	defer ts.Close()
+
+	err := d.Deliver(ts.URL, item)
+
+	assert.Nil(t, err)
+	assert.Len(t, r.Received(), 1)
+
+	ds := dl.Deliveries("abc123")
+	assert.Len(t, ds, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, ds[0].StatusCode)
+	assert.Equal(t, http.StatusOK, ds[2].StatusCode)
+	assert.Equal(t, "", ds[2].Error)
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	d, r, dl, ts := setup(t, 5)
+	defer ts.Close()
+
+	err := d.Deliver(ts.URL, item)
+
+	assert.Error(t, err)
+	assert.Len(t, r.Received(), 0)
+	assert.Len(t, dl.Deliveries("abc123"), 3)
+}
+
+func TestDeliverDoesNotRetryClientErrors(t *testing.T) {
+	d, _, dl, ts := setup(t, 0)
+	defer ts.Close()
+	d.secret = []byte("wrong")
+
+	err := d.Deliver(ts.URL, item)
+
+	assert.Error(t, err)
+	assert.Len(t, dl.Deliveries("abc123"), 1)
+	assert.Equal(t, http.StatusUnauthorized, dl.Deliveries("abc123")[0].StatusCode)
+}
+
+func TestVerifyRejectsTamperedBody(t *testing.T) {
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	sig := "sha256=" + Sign([]byte(secret), ts, []byte("abc"))
+
+	assert.True(t, Verify([]byte(secret), ts, sig, []byte("abc")))
+	assert.False(t, Verify([]byte(secret), ts, sig, []byte("abd")))
+	assert.False(t, Verify([]byte(secret), strconv.FormatInt(now+1, 10), sig, []byte("abc")))
+}
+
+func TestVerifyRejectsTimestampOutsideTolerance(t *testing.T) {
+	for _, d := range []time.Duration{-TimestampTolerance - time.Minute, TimestampTolerance + time.Minute} {
+		ts := strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+		sig := "sha256=" + Sign([]byte(secret), ts, []byte("abc"))
+
+		assert.False(t, Verify([]byte(secret), ts, sig, []byte("abc")), d.String())
+	}
+
+	sig := "sha256=" + Sign([]byte(secret), "abc", []byte("abc"))
+	assert.False(t, Verify([]byte(secret), "abc", sig, []byte("abc")))
+}
+
+func TestReceiverRetainsMostRecent(t *testing.T) {
+	d, r, _, ts := setup(t, 0)
+	defer ts.Close()
+	r.size = 2
+
+	for _, id := range []string{"a", "b", "c"} {
+		err := d.Deliver(ts.URL, &emojify.QueryItem{Id: id})
+		assert.Nil(t, err)
+	}
+
+	if assert.Len(t, r.Received(), 2) {
+		assert.Equal(t, "b", r.Received()[0].GetId())
+		assert.Equal(t, "c", r.Received()[1].GetId())
+	}
+}
+
+func TestMemoryLogRetainsMostRecent(t *testing.T) {
+	dl := NewMemoryLog(2)
+
+	dl.Record(Delivery{ID: "a", Attempt: 1})
+	dl.Record(Delivery{ID: "a", Attempt: 2})
+	dl.Record(Delivery{ID: "a", Attempt: 3})
+
+	ds := dl.Deliveries("a")
+	assert.Len(t, ds, 2)
+	assert.Equal(t, 2, ds[0].Attempt)
+}
+
+func TestDeliverUsesDialerToCheckAddress(t *testing.T) {
+	d, r, _, ts := setup(t, 0)
+	defer ts.Close()
+
+	d.SetDialer(&net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("address %s is not allowed", address)
+	}})
+
+	err := d.Deliver(ts.URL, item)
+
+	assert.Error(t, err)
+	assert.Empty(t, r.Received())
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	d, r, _, ts := setup(t, 0)
+	defer ts.Close()
+
+	rs := httptest.NewServer(http.RedirectHandler(ts.URL, http.StatusTemporaryRedirect))
+	defer rs.Close()
+
+	err := d.Deliver(rs.URL, item)
+
+	assert.Error(t, err)
+	assert.Empty(t, r.Received())
+}
+
+func TestDeliverToTestReceiverAllowedByPolicy(t *testing.T) {
+	l := hclog.Default()
+	r := NewReceiver(secret, l)
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhooks/test", r)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	d := NewDispatcher(secret, 1, time.Millisecond, NewMemoryLog(10), l)
+	d.SetDialer(emoji.DefaultURLPolicy().Dialer())
+
+	err := d.Deliver(ts.URL+"/webhooks/test", item)
+	assert.Error(t, err, "private addresses should be blocked")
+
+	p := emoji.DefaultURLPolicy()
+	p.AllowAddresses = []string{ts.Listener.Addr().String()}
+	d.SetDialer(p.Dialer())
+
+	err = d.Deliver(ts.URL+"/webhooks/test", item)
+	assert.Nil(t, err)
+	if assert.Len(t, r.Received(), 1) {
+		assert.Equal(t, "abc123", r.Received()[0].GetId())
+	}
+}
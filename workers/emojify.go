@@ -11,8 +11,11 @@ import (
 
 	"github.com/emojify-app/cache/protos/cache"
 	"github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/jobs"
 	"github.com/emojify-app/emojify/logging"
+	api "github.com/emojify-app/emojify/protos/emojify"
 	"github.com/emojify-app/emojify/queue"
+	"github.com/emojify-app/emojify/webhooks"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -22,26 +25,95 @@ import (
 type Emojify struct {
 	queue       queue.Queue
 	cache       cache.CacheClient
+	jobs        jobs.Store
 	logger      logging.Logger
 	fetcher     emojify.Fetcher
 	emojifier   emojify.Emojify
+	webhooks    webhooks.Dispatcher
 	errorDelay  time.Duration
 	normalDelay time.Duration
 	currentItem *queue.Item
+	// de-duplicate images processed at other urls, images are the same
+	// when the Hamming distance between their hashes is <= dedupDistance
+	dedup         bool
+	dedupDistance int
+	// emojify animated GIFs frame by frame, animations with more than
+	// maxFrames frames or maxPixels pixels over all the frames fail, when
+	// maxFrames is zero only the first frame is processed
+	maxFrames  int
+	maxPixels  int
+	trackFaces bool
+	// jpegQuality is used when a request does not set the quality option
+	jpegQuality int
+}
+
+// Reasons reported when processing fails, errors from the fetcher report
+// their own reason
+const (
+	ReasonCacheError          = "CACHE_ERROR"
+	ReasonFetchFailed         = "FETCH_FAILED"
+	ReasonFaceDetectionFailed = "FACE_DETECTION_FAILED"
+	ReasonEmojifyFailed       = "EMOJIFY_FAILED"
+)
+
+// processingError records the reason processing failed along with the error
+type processingError struct {
+	reason    string
+	retryable bool
+	err       error
+}
+
+func (p *processingError) Error() string {
+	return p.err.Error()
+}
+
+// result holds the details recorded against a job when processing finishes
+type result struct {
+	hash        uint64
+	format      string
+	contentType string
+	bounds      image.Rectangle
+	faces       []emojify.Face
+	aliasOf     string
 }
 
 // New returns a new Emojify worker
-func New(q queue.Queue, c cache.CacheClient, l logging.Logger, f emojify.Fetcher, e emojify.Emojify, ed, nd time.Duration) *Emojify {
+func New(q queue.Queue, c cache.CacheClient, js jobs.Store, l logging.Logger, f emojify.Fetcher, e emojify.Emojify, wh webhooks.Dispatcher, ed, nd time.Duration) *Emojify {
 	return &Emojify{
 		queue:       q,
 		cache:       c,
+		jobs:        js,
 		logger:      l,
 		fetcher:     f,
 		emojifier:   e,
+		webhooks:    wh,
 		errorDelay:  ed,
 		normalDelay: nd}
 }
 
+// SetDedupDistance enables de-duplication of images which have already
+// been processed at a different URL, images are considered the same when
+// the Hamming distance between their perceptual hashes is at most d
+func (e *Emojify) SetDedupDistance(d int) {
+	e.dedup = true
+	e.dedupDistance = d
+}
+
+// SetAnimation enables frame by frame processing of animated GIFs with up
+// to maxFrames frames and maxPixels pixels over all the frames, when track
+// is true a face keeps the same emoji in every frame
+func (e *Emojify) SetAnimation(maxFrames, maxPixels int, track bool) {
+	e.maxFrames = maxFrames
+	e.maxPixels = maxPixels
+	e.trackFaces = track
+}
+
+// SetJPEGQuality sets the quality of JPEG output for requests which do
+// not set the quality option
+func (e *Emojify) SetJPEGQuality(q int) {
+	e.jpegQuality = q
+}
+
 // Start processing items on the queue
 func (e *Emojify) Start() {
 	l := e.logger.Log().Named("worker")
@@ -51,75 +123,89 @@ func (e *Emojify) Start() {
 		l.Debug("Worker processing queue item", "item", qi)
 
 		done := e.logger.WorkerProcessQueueItem(qi.Item)
+		e.updateJob(qi.Item, jobs.StatusProcessing, nil, nil)
+
 		// check the cache
 		ok, err := e.checkCache(qi.Item.ID)
 		if err != nil {
-			done(http.StatusInternalServerError, err)
-
-			// set the error and signal complete
-			qi.Error = err
-			qi.Done <- qi
+			e.fail(qi, done, ReasonCacheError, err)
 			continue
 		}
 
 		// if we have a cached item do not re-process
 		if ok {
 			l.Debug("Found cached item", "item", qi.Item)
-			done(http.StatusOK, nil)
-
-			// signal complete
-			qi.Done <- qi
+			e.finish(qi, done, nil)
 			continue
 		}
 
 		// fetch the image
 		f, img, err := e.fetchImage(qi.Item.URI)
 		if err != nil {
-			done(http.StatusInternalServerError, err)
-
-			// set the error and signal complete
-			qi.Error = err
-			qi.Done <- qi
+			e.fail(qi, done, ReasonFetchFailed, err)
 			continue
 		}
 
-		// find faces in the image
-		faces, err := e.findFaces(qi.Item.URI, f)
+		res := &result{bounds: img.Bounds(), format: emojify.Format(f)}
+		format := emojify.OutputFormat(qi.Item.Options[emojify.OptionFormat], res.format, img)
+		res.contentType = emojify.ContentType(format)
+
+		// animations are processed frame by frame when the output is a GIF
+		anim, err := e.decodeAnimation(qi.Item.URI, f, res.format, format)
 		if err != nil {
-			done(http.StatusInternalServerError, err)
+			e.fail(qi, done, ReasonFetchFailed, err)
+			continue
+		}
 
-			// set the error and signal complete
-			qi.Error = err
-			qi.Done <- qi
+		if anim != nil {
+			e.processAnimation(qi, done, f, anim, res)
 			continue
 		}
 
+		o := emojify.NewOptions(qi.Item.Options, qi.Item.ID, qi.Item.Owner)
+
+		// check if the same image has been processed at another url
+		orig := e.findDuplicate(qi.Item, img, res)
+
+		// the output of the original can be used when the images are the
+		// same size and the output is the same format, output which
+		// depends on the seed is unique to each job
+		if orig != nil && orig.Bounds == res.bounds && orig.ContentType == res.contentType &&
+			!e.emojifier.Seeded(o) && e.isCached(orig.ID) {
+			res.faces = orig.Faces
+			res.aliasOf = orig.ID
+			res.contentType = orig.ContentType
+
+			e.finish(qi, done, res)
+			continue
+		}
+
+		// find faces in the image, reusing the faces from a duplicate
+		if orig != nil {
+			res.faces = emojify.ScaleFaces(orig.Faces, orig.Bounds, res.bounds)
+		} else {
+			res.faces, err = e.findFaces(qi.Item.URI, f, img)
+			if err != nil {
+				e.fail(qi, done, ReasonFaceDetectionFailed, err)
+				continue
+			}
+		}
+
 		// process the image and replace faces with emoji
-		data, err := e.processImage(qi.Item.URI, faces, img)
+		data, err := e.processImage(qi.Item.URI, res.faces, img, o, format, e.quality(qi.Item.Options))
 		if err != nil {
-			done(http.StatusInternalServerError, err)
-
-			// set the error and signal complete
-			qi.Error = err
-			qi.Done <- qi
+			e.fail(qi, done, ReasonEmojifyFailed, err)
 			continue
 		}
 
 		// save the cache
 		err = e.saveCache(qi.Item.URI, qi.Item.ID, data)
 		if err != nil {
-			done(http.StatusInternalServerError, err)
-
-			// set the error and signal complete
-			qi.Error = err
-			qi.Done <- qi
+			e.fail(qi, done, ReasonCacheError, err)
 			continue
 		}
 
-		done(http.StatusOK, nil)
-
-		// signal complete
-		qi.Done <- qi
+		e.finish(qi, done, res)
 	}
 }
 
@@ -128,6 +214,148 @@ func (e *Emojify) Stop() {
 
 }
 
+// finish records the result against the job, notifies the callback and
+// signals the queue that processing has completed, cached items which are
+// not re-processed have no result
+func (e *Emojify) finish(qi queue.PopResponse, done logging.Finished, res *result) {
+	done(http.StatusOK, nil)
+	e.updateJob(qi.Item, jobs.StatusFinished, res, nil)
+	e.notify(qi.Item, api.QueryStatus_FINISHED, nil)
+
+	// signal complete
+	qi.Done <- qi
+}
+
+// fail records the error against the job and signals the queue that
+// processing has completed
+func (e *Emojify) fail(qi queue.PopResponse, done logging.Finished, reason string, err error) {
+	pe := &processingError{reason: reason, retryable: !emojify.IsPermanent(err), err: err}
+	if r := emojify.FailureReason(err); r != "" {
+		pe.reason = r
+	}
+
+	// permanent failures are caused by the request, not the service
+	if !pe.retryable {
+		e.logger.WorkerPermanentFailure(qi.Item.URI, pe.reason, err)
+		done(http.StatusBadRequest, err)
+	} else {
+		done(http.StatusInternalServerError, err)
+	}
+
+	e.updateJob(qi.Item, jobs.StatusFailed, nil, pe)
+	e.notify(qi.Item, api.QueryStatus_FAILED, pe)
+
+	// set the error and signal complete
+	qi.Error = err
+	qi.Done <- qi
+}
+
+// updateJob records a state change for the job, errors are logged and
+// do not stop processing of the item
+func (e *Emojify) updateJob(i *queue.Item, s jobs.Status, res *result, jobErr *processingError) {
+	done := e.logger.JobSave(i.ID)
+
+	j, err := e.jobs.Get(i.ID)
+	if err == jobs.ErrNotFound {
+		// items queued before the job was recorded
+		j = &jobs.Job{ID: i.ID, URI: i.URI, Owner: i.Owner, Options: i.Options, Added: i.Added}
+		err = nil
+	}
+
+	if err != nil {
+		done(http.StatusInternalServerError, err)
+		return
+	}
+
+	j.Status = s
+	j.Updated = time.Now()
+	j.Error = ""
+	j.Reason = ""
+	j.Retryable = false
+
+	// cached items are not re-processed, keep the previous result
+	if res != nil {
+		j.FaceCount = len(res.faces)
+		j.Faces = res.faces
+		j.Bounds = res.bounds
+		j.InputFormat = res.format
+		j.ContentType = res.contentType
+		j.PHash = res.hash
+		j.AliasOf = res.aliasOf
+	}
+
+	if jobErr != nil {
+		j.Error = jobErr.Error()
+		j.Reason = jobErr.reason
+		j.Retryable = jobErr.retryable
+	}
+
+	if s == jobs.StatusFinished || s == jobs.StatusFailed {
+		j.Completed = j.Updated
+	}
+
+	err = e.jobs.Save(j)
+	if err != nil {
+		done(http.StatusInternalServerError, err)
+		return
+	}
+
+	done(http.StatusOK, nil)
+}
+
+// notify sends the final status of the item to its callback url
+func (e *Emojify) notify(i *queue.Item, s api.QueryStatus_QueryStatus, err *processingError) {
+	if i.CallbackURL == "" {
+		return
+	}
+
+	qi := &api.QueryItem{
+		Id:     i.ID,
+		Status: &api.QueryStatus{Status: s},
+	}
+
+	if err != nil {
+		qi.Error = err.Error()
+		qi.Reason = err.reason
+		qi.Retryable = err.retryable
+	}
+
+	e.webhooks.Dispatch(i.CallbackURL, qi)
+}
+
+// findDuplicate returns a finished job for a near identical image with
+// the same owner processed with the same options, or nil if there is none
+func (e *Emojify) findDuplicate(i *queue.Item, img image.Image, res *result) *jobs.Job {
+	if !e.dedup {
+		return nil
+	}
+
+	res.hash = emojify.PerceptualHash(img)
+
+	j, err := e.jobs.FindSimilar(res.hash, i.Owner, i.Options, e.dedupDistance)
+	if err != nil {
+		if err != jobs.ErrNotFound {
+			e.logger.Log().Error("Unable to search for duplicate images", "error", err)
+		}
+
+		return nil
+	}
+
+	// never alias a job to itself, this happens when an item is re-queued
+	if j.ID == i.ID {
+		return nil
+	}
+
+	e.logger.WorkerDuplicateImage(i.URI, j.ID)
+	return j
+}
+
+// isCached returns true if the output for the key exists in the cache
+func (e *Emojify) isCached(key string) bool {
+	ok, err := e.checkCache(key)
+	return ok && err == nil
+}
+
 func (e *Emojify) checkCache(key string) (bool, error) {
 	done := e.logger.CacheExists(key)
 
@@ -165,7 +393,21 @@ func (e *Emojify) fetchImage(uri string) (io.ReadSeeker, image.Image, error) {
 	return f, img, nil
 }
 
-func (e *Emojify) findFaces(uri string, r io.ReadSeeker) ([]image.Rectangle, error) {
+func (e *Emojify) findFaces(uri string, r io.ReadSeeker, img image.Image) ([]emojify.Face, error) {
+	// the decoded image has been rotated to match its EXIF orientation,
+	// face detection must use the rotated image so the faces match, other
+	// formats are converted as face detection only accepts jpeg and png
+	format := emojify.Format(r)
+	if emojify.Orientation(r) > 1 || (format != "" && format != "jpeg" && format != "png") {
+		out := new(bytes.Buffer)
+		if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 90}); err != nil {
+			e.logger.WorkerImageEncodeError(uri, err)
+			return nil, err
+		}
+
+		r = bytes.NewReader(out.Bytes())
+	}
+
 	done := e.logger.WorkerFindFaces(uri)
 
 	f, err := e.emojifier.GetFaces(r)
@@ -178,10 +420,75 @@ func (e *Emojify) findFaces(uri string, r io.ReadSeeker) ([]image.Rectangle, err
 	return f, nil
 }
 
-func (e *Emojify) processImage(uri string, faces []image.Rectangle, img image.Image) ([]byte, error) {
+func (e *Emojify) decodeAnimation(uri string, r io.ReadSeeker, input, output string) (*emojify.Animation, error) {
+	if e.maxFrames == 0 || input != emojify.FormatGIF || output != emojify.FormatGIF {
+		return nil, nil
+	}
+
+	a, err := emojify.DecodeAnimation(r, e.maxFrames, e.maxPixels)
+	if err != nil {
+		e.logger.WorkerInvalidImage(uri, err)
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// processAnimation finds the faces in every frame of the animation and
+// replaces them with emoji, the frame delays, disposal and loop count are
+// kept from the original
+func (e *Emojify) processAnimation(qi queue.PopResponse, done logging.Finished, r io.ReadSeeker, a *emojify.Animation, res *result) {
+	faces := make([][]emojify.Face, len(a.Frames))
+	for i, f := range a.Frames {
+		ff, err := e.findFaces(qi.Item.URI, r, f)
+		if err != nil {
+			e.fail(qi, done, ReasonFaceDetectionFailed, err)
+			return
+		}
+
+		// record the frame with the most faces against the job
+		faces[i] = ff
+		if len(ff) > len(res.faces) {
+			res.faces = ff
+		}
+	}
+
+	ed := e.logger.WorkerEmojify(qi.Item.URI)
+
+	o := emojify.NewOptions(qi.Item.Options, qi.Item.ID, qi.Item.Owner)
+	o.Track = e.trackFaces
+
+	frames, err := e.emojifier.EmojimiseFrames(a.Frames, faces, o)
+	if err != nil {
+		ed(http.StatusInternalServerError, err)
+		e.fail(qi, done, ReasonEmojifyFailed, err)
+		return
+	}
+
+	ed(http.StatusOK, nil)
+
+	a.Frames = frames
+	out := new(bytes.Buffer)
+	err = emojify.EncodeAnimation(out, a)
+	if err != nil {
+		e.logger.WorkerImageEncodeError(qi.Item.URI, err)
+		e.fail(qi, done, ReasonEmojifyFailed, err)
+		return
+	}
+
+	err = e.saveCache(qi.Item.URI, qi.Item.ID, out.Bytes())
+	if err != nil {
+		e.fail(qi, done, ReasonCacheError, err)
+		return
+	}
+
+	e.finish(qi, done, res)
+}
+
+func (e *Emojify) processImage(uri string, faces []emojify.Face, img image.Image, o emojify.Options, format string, quality int) ([]byte, error) {
 	done := e.logger.WorkerEmojify(uri)
 
-	i, err := e.emojifier.Emojimise(img, faces)
+	i, err := e.emojifier.Emojimise(img, faces, o)
 	if err != nil {
 		done(http.StatusInternalServerError, err)
 		return nil, err
@@ -189,9 +496,10 @@ func (e *Emojify) processImage(uri string, faces []image.Rectangle, img image.Im
 
 	done(http.StatusOK, nil)
 
-	// save the image
+	// save the image, the output is encoded from the pixels so metadata
+	// from the source such as the EXIF orientation is not copied
 	out := new(bytes.Buffer)
-	err = jpeg.Encode(out, i, &jpeg.Options{Quality: 60})
+	err = emojify.Encode(out, i, format, quality)
 	if err != nil {
 		e.logger.WorkerImageEncodeError(uri, err)
 		return nil, err
@@ -213,3 +521,17 @@ func (e *Emojify) saveCache(uri, key string, data []byte) error {
 	done(http.StatusOK, nil)
 	return nil
 }
+
+// quality returns the JPEG quality for the request, options are validated
+// when the request is created
+func (e *Emojify) quality(options map[string]string) int {
+	if q, err := emojify.Quality(options[emojify.OptionQuality]); err == nil {
+		return q
+	}
+
+	if e.jpegQuality > 0 {
+		return e.jpegQuality
+	}
+
+	return jpeg.DefaultQuality
+}
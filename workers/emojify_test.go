@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/gif"
+	"io/ioutil"
 	"testing"
 	"time"
 
 	"github.com/emojify-app/cache/protos/cache"
 	"github.com/emojify-app/emojify/emojify"
+	"github.com/emojify-app/emojify/jobs"
 	"github.com/emojify-app/emojify/logging"
+	api "github.com/emojify-app/emojify/protos/emojify"
 	"github.com/emojify-app/emojify/queue"
+	"github.com/emojify-app/emojify/webhooks"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/stretchr/testify/mock"
 )
@@ -22,10 +27,12 @@ type testData struct {
 	qi               queue.PopResponse
 	mockQueue        *queue.MockQueue
 	mockCache        *cache.ClientMock
+	mockJobs         *jobs.MockStore
 	mockFetcher      *emojify.MockFetcher
 	mockEmojify      *emojify.MockEmojify
+	mockWebhooks     *webhooks.MockDispatcher
 	mockReader       *bytes.Reader
-	mockFaces        []image.Rectangle
+	mockFaces        []emojify.Face
 	mockImage        image.Image
 	mockEmojifyImage image.Image
 }
@@ -35,8 +42,9 @@ func setup(t *testing.T, timeout time.Duration) *testData {
 	td.popChan = make(chan queue.PopResponse)
 	td.qi = queue.PopResponse{
 		Item: &queue.Item{
-			ID:  "abc123",
-			URI: "https://something",
+			ID:          "abc123",
+			URI:         "https://something",
+			CallbackURL: "https://callback",
 		},
 		Error: nil,
 	}
@@ -45,7 +53,7 @@ func setup(t *testing.T, timeout time.Duration) *testData {
 	td.mockQueue.On("Pop").Return(td.popChan)
 
 	td.mockReader = bytes.NewReader([]byte("abc"))
-	td.mockFaces = []image.Rectangle{image.Rect(0, 0, 10, 10)}
+	td.mockFaces = emojify.Rects(image.Rect(0, 0, 10, 10))
 	td.mockImage = image.NewUniform(color.Black)
 	td.mockEmojifyImage = image.NewRGBA64(image.Rect(0, 0, 400, 400))
 
@@ -53,22 +61,31 @@ func setup(t *testing.T, timeout time.Duration) *testData {
 	td.mockCache.On("Exists", mock.Anything, mock.Anything, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
 	td.mockCache.On("Put", mock.Anything, mock.Anything, mock.Anything).Return(&wrappers.StringValue{Value: "abc"}, nil)
 
+	td.mockJobs = &jobs.MockStore{}
+	td.mockJobs.On("Get", mock.Anything).Return(&jobs.Job{ID: "abc123"}, nil)
+	td.mockJobs.On("Save", mock.Anything).Return(nil)
+
 	td.mockFetcher = &emojify.MockFetcher{}
 	td.mockFetcher.On("FetchImage", mock.Anything).Return(td.mockReader, nil)
 	td.mockFetcher.On("ReaderToImage", td.mockReader).Return(td.mockImage, nil)
 
 	td.mockEmojify = &emojify.MockEmojify{}
 	td.mockEmojify.On("GetFaces", td.mockReader).Return(td.mockFaces, nil)
-	td.mockEmojify.On("Emojimise", td.mockImage, td.mockFaces).Return(td.mockEmojifyImage, nil)
+	td.mockEmojify.On("Emojimise", td.mockImage, td.mockFaces, mock.Anything).Return(td.mockEmojifyImage, nil)
+
+	td.mockWebhooks = &webhooks.MockDispatcher{}
+	td.mockWebhooks.On("Dispatch", mock.Anything, mock.Anything)
 
 	logger := logging.New("localhost:9125", "debug")
 
 	td.emo = &Emojify{
 		queue:       td.mockQueue,
 		cache:       td.mockCache,
+		jobs:        td.mockJobs,
 		logger:      logger,
 		fetcher:     td.mockFetcher,
 		emojifier:   td.mockEmojify,
+		webhooks:    td.mockWebhooks,
 		errorDelay:  1 * time.Millisecond,
 		normalDelay: 1 * time.Millisecond}
 	go td.emo.Start() // start the app
@@ -116,6 +133,59 @@ func TestStartWithFetchErrorDoesNotFindFaces(t *testing.T) {
 	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
 }
 
+func TestStartWithFetchErrorSetsJobFailed(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	td.mockFetcher.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockFetcher.On("FetchImage", mock.Anything).Return(nil, fmt.Errorf("abc"))
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFailed && j.Error == "abc" && !j.Completed.IsZero()
+	}))
+}
+
+func TestStartWithFetchErrorSendsFailedWebhook(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	td.mockFetcher.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockFetcher.On("FetchImage", mock.Anything).Return(nil, fmt.Errorf("abc"))
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockWebhooks.AssertCalled(t, "Dispatch", "https://callback", &api.QueryItem{
+		Id:        "abc123",
+		Status:    &api.QueryStatus{Status: api.QueryStatus_FAILED},
+		Error:     "abc",
+		Reason:    ReasonFetchFailed,
+		Retryable: true,
+	})
+}
+
+func TestStartWithPermanentFetchErrorSetsJobNotRetryable(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	err := &emojify.PermanentError{Reason: emojify.ReasonURLBlocked, Err: fmt.Errorf("blocked")}
+	td.mockFetcher.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockFetcher.On("FetchImage", mock.Anything).Return(nil, err)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFailed && j.Error == "blocked" && j.Reason == emojify.ReasonURLBlocked && !j.Retryable
+	}))
+	td.mockWebhooks.AssertCalled(t, "Dispatch", "https://callback", &api.QueryItem{
+		Id:     "abc123",
+		Status: &api.QueryStatus{Status: api.QueryStatus_FAILED},
+		Error:  "blocked",
+		Reason: emojify.ReasonURLBlocked,
+	})
+}
+
 func TestStartWithInvalidImageDoesNotFindFaces(t *testing.T) {
 	td := setup(t, 10*time.Millisecond)
 
@@ -129,17 +199,31 @@ func TestStartWithInvalidImageDoesNotFindFaces(t *testing.T) {
 	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
 }
 
+func TestStartWithFaceDetectionErrorSetsReason(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	td.mockEmojify.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockEmojify.On("GetFaces", mock.Anything).Return(nil, fmt.Errorf("facebox down"))
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFailed && j.Reason == ReasonFaceDetectionFailed && j.Retryable
+	}))
+}
+
 func TestStartWithInvalidEmojimiseDoesNotSetCache(t *testing.T) {
 	td := setup(t, 10*time.Millisecond)
 
 	td.mockEmojify.ExpectedCalls = make([]*mock.Call, 0)
 	td.mockEmojify.On("GetFaces", td.mockReader).Return(td.mockFaces, nil)
-	td.mockEmojify.On("Emojimise", td.mockImage, td.mockFaces).Return(nil, fmt.Errorf("boom"))
+	td.mockEmojify.On("Emojimise", td.mockImage, td.mockFaces, mock.Anything).Return(nil, fmt.Errorf("boom"))
 
 	td.popChan <- td.qi
 	time.Sleep(1000 * time.Millisecond)
 
-	td.mockEmojify.AssertCalled(t, "Emojimise", td.mockImage, td.mockFaces)
+	td.mockEmojify.AssertCalled(t, "Emojimise", td.mockImage, td.mockFaces, mock.Anything)
 	td.mockCache.AssertNotCalled(t, "Put", mock.Anything)
 }
 
@@ -153,6 +237,179 @@ func TestStartProcessesItem(t *testing.T) {
 	td.mockCache.AssertCalled(t, "Exists", mock.Anything, id, mock.Anything)
 	td.mockFetcher.AssertCalled(t, "FetchImage", td.qi.Item.URI)
 	td.mockEmojify.AssertCalled(t, "GetFaces", td.mockReader)
-	td.mockEmojify.AssertCalled(t, "Emojimise", td.mockImage, td.mockFaces)
+	td.mockEmojify.AssertCalled(t, "Emojimise", td.mockImage, td.mockFaces, mock.Anything)
+	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStartProcessesItemSetsJobFinished(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFinished && j.FaceCount == 1
+	}))
+}
+
+func TestStartProcessesItemSendsFinishedWebhook(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockWebhooks.AssertCalled(t, "Dispatch", "https://callback", &api.QueryItem{
+		Id:     "abc123",
+		Status: &api.QueryStatus{Status: api.QueryStatus_FINISHED},
+	})
+}
+
+func setupDuplicate(t *testing.T, origBounds image.Rectangle, contentType string) *testData {
+	td := setup(t, 10*time.Millisecond)
+	td.emo.SetDedupDistance(4)
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	td.mockFetcher.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockFetcher.On("FetchImage", mock.Anything).Return(td.mockReader, nil)
+	td.mockFetcher.On("ReaderToImage", td.mockReader).Return(img, nil)
+
+	td.mockEmojify.On("Emojimise", img, mock.Anything, mock.Anything).Return(td.mockEmojifyImage, nil)
+	td.mockEmojify.On("Seeded", mock.Anything).Return(false)
+
+	td.mockCache.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: "abc123"}, mock.Anything).Return(&wrappers.BoolValue{Value: false}, nil)
+	td.mockCache.On("Exists", mock.Anything, &wrappers.StringValue{Value: "orig"}, mock.Anything).Return(&wrappers.BoolValue{Value: true}, nil)
+	td.mockCache.On("Put", mock.Anything, mock.Anything, mock.Anything).Return(&wrappers.StringValue{Value: "abc"}, nil)
+
+	orig := &jobs.Job{ID: "orig", Bounds: origBounds, ContentType: contentType, Faces: emojify.Rects(image.Rect(0, 0, 20, 20))}
+	td.mockJobs.On("FindSimilar", mock.Anything, mock.Anything, mock.Anything, 4).Return(orig, nil)
+
+	return td
+}
+
+func TestStartWithDuplicateImageAliasesCachedOutput(t *testing.T) {
+	td := setupDuplicate(t, image.Rect(0, 0, 100, 100), "image/png")
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
+	td.mockCache.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFinished && j.AliasOf == "orig" && j.FaceCount == 1
+	}))
+}
+
+func TestStartWithResizedDuplicateImageReusesScaledFaces(t *testing.T) {
+	td := setupDuplicate(t, image.Rect(0, 0, 200, 200), "image/png")
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
+	td.mockEmojify.AssertCalled(t, "Emojimise", mock.Anything, emojify.Rects(image.Rect(0, 0, 10, 10)), mock.Anything)
+	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStartWithDuplicateInAnotherFormatDoesNotAlias(t *testing.T) {
+	td := setupDuplicate(t, image.Rect(0, 0, 100, 100), "image/jpeg")
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
 	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	td.mockJobs.AssertNotCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.AliasOf == "orig"
+	}))
+}
+
+func TestStartWithDuplicateAndSeededSelectionDoesNotAlias(t *testing.T) {
+	td := setupDuplicate(t, image.Rect(0, 0, 100, 100), "image/png")
+	td.mockEmojify.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockEmojify.On("Emojimise", mock.Anything, mock.Anything, mock.Anything).Return(td.mockEmojifyImage, nil)
+	td.mockEmojify.On("Seeded", mock.Anything).Return(true)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
+	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	td.mockJobs.AssertNotCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.AliasOf == "orig"
+	}))
+}
+
+func setupAnimation(t *testing.T) *testData {
+	td := setup(t, 10*time.Millisecond)
+	td.emo.SetAnimation(10, 1<<24, true)
+
+	d, err := ioutil.ReadFile("../emojify/testdata/image.gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(d)
+	td.mockFetcher.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockFetcher.On("FetchImage", mock.Anything).Return(r, nil)
+	td.mockFetcher.On("ReaderToImage", r).Return(image.NewRGBA(image.Rect(0, 0, 32, 24)), nil)
+
+	frames := []image.Image{image.NewRGBA(image.Rect(0, 0, 32, 24)), image.NewRGBA(image.Rect(0, 0, 32, 24))}
+	td.mockEmojify.ExpectedCalls = make([]*mock.Call, 0)
+	td.mockEmojify.On("GetFaces", mock.Anything).Return(td.mockFaces, nil)
+	td.mockEmojify.On("EmojimiseFrames", mock.Anything, mock.Anything, mock.Anything).Return(frames, nil)
+
+	return td
+}
+
+func TestStartWithAnimationEmojifiesEveryFrame(t *testing.T) {
+	td := setupAnimation(t)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertNumberOfCalls(t, "GetFaces", 2)
+	td.mockEmojify.AssertCalled(t, "EmojimiseFrames", mock.Anything, [][]emojify.Face{td.mockFaces, td.mockFaces}, emojify.Options{Seed: "abc123", Track: true})
+	td.mockEmojify.AssertNotCalled(t, "Emojimise", mock.Anything, mock.Anything, mock.Anything)
+	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.MatchedBy(func(ci *cache.CacheItem) bool {
+		g, err := gif.DecodeAll(bytes.NewReader(ci.Data))
+		return err == nil && len(g.Image) == 2 && g.Delay[1] == 20
+	}), mock.Anything)
+}
+
+func TestStartWithTooManyFramesSetsJobFailed(t *testing.T) {
+	td := setupAnimation(t)
+	td.emo.SetAnimation(1, 1<<24, true)
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertNotCalled(t, "GetFaces", mock.Anything)
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFailed && j.Reason == emojify.ReasonTooManyFrames && !j.Retryable
+	}))
+}
+
+func TestStartWithFormatOptionEncodesOutput(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+	td.qi.Item.Options = map[string]string{emojify.OptionFormat: emojify.FormatPNG}
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockCache.AssertCalled(t, "Put", mock.Anything, mock.MatchedBy(func(ci *cache.CacheItem) bool {
+		return emojify.Format(bytes.NewReader(ci.Data)) == emojify.FormatPNG
+	}), mock.Anything)
+	td.mockJobs.AssertCalled(t, "Save", mock.MatchedBy(func(j *jobs.Job) bool {
+		return j.Status == jobs.StatusFinished && j.ContentType == "image/png"
+	}))
+}
+
+func TestStartPassesSelectionOptions(t *testing.T) {
+	td := setup(t, 10*time.Millisecond)
+	td.qi.Item.Options = map[string]string{emojify.OptionSelection: emojify.SelectFixed, emojify.OptionCodepoint: "1f600"}
+
+	td.popChan <- td.qi
+	time.Sleep(1000 * time.Millisecond)
+
+	td.mockEmojify.AssertCalled(t, "Emojimise", td.mockImage, td.mockFaces, emojify.Options{Selection: "fixed", Codepoint: "1f600", Seed: "abc123"})
 }